@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+// serveControl provides a simple line based control interface on the given address. It understands the following commands:
+//
+//	set <variable> <value>	set a CW macro variable
+//	get <variable>		get the value of a CW macro variable
+//	vars			list all CW macro variables
+//	resync			repaint all LEDs
+func serveControl(ctx context.Context, address string, leds Resyncer) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	log.Printf("Control interface listening on %s", listener.Addr())
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleControlConnection(conn, leds)
+		}
+	}()
+
+	return nil
+}
+
+// Resyncer repaints the LEDs of the MIDI devices.
+type Resyncer interface {
+	Resync()
+}
+
+func handleControlConnection(conn net.Conn, leds Resyncer) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		err := executeControlCommand(conn, leds, line)
+		if err != nil {
+			fmt.Fprintf(conn, "ERR %v\n", err)
+		} else {
+			fmt.Fprintln(conn, "OK")
+		}
+	}
+}
+
+func executeControlCommand(w io.Writer, leds Resyncer, line string) error {
+	command, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+
+	switch strings.ToLower(command) {
+	case "set":
+		name, value, _ := strings.Cut(args, " ")
+		return ctrl.MacroVariables.Set(name, value)
+	case "get":
+		value, ok := ctrl.MacroVariables.Get(args)
+		if !ok {
+			return fmt.Errorf("unknown variable %s", args)
+		}
+		fmt.Fprintln(w, value)
+		return nil
+	case "vars":
+		for _, name := range ctrl.MacroVariables.Names() {
+			value, _ := ctrl.MacroVariables.Get(name)
+			fmt.Fprintf(w, "%s=%s\n", name, value)
+		}
+		return nil
+	case "resync":
+		leds.Resync()
+		return nil
+	default:
+		return fmt.Errorf("unknown command %s", command)
+	}
+}
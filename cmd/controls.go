@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/ftl/tci/client"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+type Indicator interface {
+	Close()
+}
+
+type Button interface {
+	Pressed()
+}
+
+type ReleasableButton interface {
+	Button
+	Released()
+}
+
+type ValueControl interface {
+	Changed(int)
+	Close()
+}
+
+type FineValueControl interface {
+	ValueControl
+	ChangedFine(int)
+}
+
+// controls contains the controllers of all mappings, indexed by the device and the MIDI key they listen to.
+// When the configuration is reloaded, the controls are replaced as a whole.
+type controls struct {
+	buttons  map[deviceKey]Button
+	potis    map[deviceKey]ValueControl
+	encoders map[deviceKey]ValueControl
+	faders   map[deviceKey]*ctrl.FaderLED
+	mappings []*mappedControl
+}
+
+func newControls() *controls {
+	return &controls{
+		buttons:  make(map[deviceKey]Button),
+		potis:    make(map[deviceKey]ValueControl),
+		encoders: make(map[deviceKey]ValueControl),
+		faders:   make(map[deviceKey]*ctrl.FaderLED),
+	}
+}
+
+// activeControls are the controls that receive the incoming MIDI messages.
+var activeControls atomic.Pointer[controls]
+
+func init() {
+	activeControls.Store(newControls())
+}
+
+func currentControls() *controls {
+	return activeControls.Load()
+}
+
+func (c *controls) add(control *mappedControl) {
+	key := deviceKey{device: control.device.name, key: control.mapping.MidiKey()}
+	if control.fader != nil {
+		c.faders[key] = control.fader
+		touchKey, ok := control.fader.TouchKey()
+		if ok {
+			c.buttons[deviceKey{device: control.device.name, key: touchKey}] = control.fader
+		}
+	}
+
+	switch control.controlType {
+	case ctrl.ButtonControl:
+		c.buttons[key] = control.controller.(Button)
+	case ctrl.PotiControl:
+		c.potis[key] = control.controller.(ValueControl)
+	case ctrl.EncoderControl:
+		c.encoders[key] = control.controller.(ValueControl)
+	}
+	c.mappings = append(c.mappings, control)
+}
+
+// Close closes the controllers of all mappings.
+func (c *controls) Close() {
+	for _, control := range c.mappings {
+		control.close()
+	}
+}
+
+// applyMappings creates the controls for the given mappings and activates them. Controllers of mappings that did not
+// change are kept with their current state, the controllers of removed or changed mappings are retired.
+func applyMappings(mappings []ctrl.Mapping, midiDevices devices, tciClient *client.Client) {
+	previous := currentControls()
+	reusable := make(map[string][]*mappedControl)
+	for _, control := range previous.mappings {
+		reusable[control.id] = append(reusable[control.id], control)
+	}
+
+	type slot struct {
+		mapping ctrl.Mapping
+		device  *device
+		control *mappedControl
+	}
+	slots := make([]slot, 0, len(mappings))
+	kept := make(map[*mappedControl]bool)
+	for _, mapping := range mappings {
+		device, ok := midiDevices.Get(mapping.Device)
+		if !ok {
+			log.Printf("Cannot create %s: unknown device %s", mapping.Type, mapping.Device)
+			continue
+		}
+		s := slot{mapping: mapping, device: device}
+		id := mappingID(device, mapping)
+		if candidates := reusable[id]; len(candidates) > 0 {
+			s.control = candidates[0]
+			reusable[id] = candidates[1:]
+			kept[s.control] = true
+		}
+		slots = append(slots, s)
+	}
+
+	// retire the removed controls first, so they do not overwrite the LEDs of their successors
+	used := make(map[deviceKey]bool)
+	for _, s := range slots {
+		used[deviceKey{device: s.device.name, key: s.mapping.MidiKey()}] = true
+	}
+	for _, control := range previous.mappings {
+		if !kept[control] {
+			control.retire(used)
+		}
+	}
+
+	next := newControls()
+	var added []*mappedControl
+	for _, s := range slots {
+		if s.control == nil {
+			control, err := newMappedControl(s.mapping, s.device, tciClient)
+			if err != nil {
+				log.Printf("Cannot create %s: %v", s.mapping.Type, err)
+				continue
+			}
+			s.control = control
+			added = append(added, control)
+		}
+		next.add(s.control)
+	}
+	tciEvents.activate(next, added)
+}
+
+// mappedControl is the controller of a single mapping. It receives the TCI notifications through tciEvents as long as
+// it belongs to the active controls. The LED of a retired controller is switched off for good.
+type mappedControl struct {
+	id          string
+	mapping     ctrl.Mapping
+	device      *device
+	controller  any
+	controlType ctrl.ControlType
+	led         *switchableLED
+	fader       *ctrl.FaderLED
+}
+
+func newMappedControl(mapping ctrl.Mapping, device *device, tciClient *client.Client) (*mappedControl, error) {
+	definition, ok := ctrl.Definitions[mapping.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown mapping type %s", mapping.Type)
+	}
+
+	result := &mappedControl{
+		id:      mappingID(device, mapping),
+		mapping: mapping,
+		device:  device,
+		led:     &switchableLED{LED: device.leds},
+	}
+	mappingLED, err := ctrl.MappingLED(mapping, result.led)
+	if err != nil {
+		return nil, err
+	}
+	result.fader, _ = mappingLED.(*ctrl.FaderLED)
+
+	result.controller, result.controlType, err = definition.Factory(mapping, mappingLED, tciClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if device.surface != nil && result.isValueControl() {
+		device.surface.Assign(mapping.MidiKey(), string(mapping.Type))
+	}
+	return result, nil
+}
+
+// mappingID identifies a mapping on its device. Two mappings with the same ID create the same controller.
+func mappingID(device *device, mapping ctrl.Mapping) string {
+	mapping.Device = ""
+	data, _ := json.Marshal(mapping)
+	return device.name + ":" + string(data)
+}
+
+func (c *mappedControl) isValueControl() bool {
+	return c.controlType == ctrl.PotiControl || c.controlType == ctrl.EncoderControl
+}
+
+func (c *mappedControl) close() {
+	switch c.controlType {
+	case ctrl.PotiControl, ctrl.EncoderControl:
+		c.controller.(ValueControl).Close()
+	case ctrl.IndicatorControl:
+		c.controller.(Indicator).Close()
+	case ctrl.ButtonControl:
+		if button, ok := c.controller.(Indicator); ok {
+			button.Close()
+		}
+	}
+}
+
+// retire closes the controller and clears its LED and its label on the scribble strip, unless another mapping
+// still uses the same key.
+func (c *mappedControl) retire(used map[deviceKey]bool) {
+	c.led.switchOff()
+	c.close()
+
+	key := c.mapping.MidiKey()
+	if used[deviceKey{device: c.device.name, key: key}] {
+		return
+	}
+	switch {
+	case c.isValueControl() && c.device.surface != nil:
+		c.device.surface.Assign(key, "")
+	case c.controlType == ctrl.ButtonControl || c.controlType == ctrl.IndicatorControl:
+		c.device.leds.SetOn(key, false)
+	}
+}
+
+// switchableLED forwards to the LED of the device until it is switched off.
+type switchableLED struct {
+	ctrl.LED
+	off atomic.Bool
+}
+
+func (l *switchableLED) switchOff() {
+	l.off.Store(true)
+}
+
+func (l *switchableLED) SetOn(key ctrl.MidiKey, on bool) {
+	if !l.off.Load() {
+		l.LED.SetOn(key, on)
+	}
+}
+
+func (l *switchableLED) SetFlashing(key ctrl.MidiKey, on bool) {
+	if !l.off.Load() {
+		l.LED.SetFlashing(key, on)
+	}
+}
+
+func (l *switchableLED) SetValue(key ctrl.MidiKey, value uint8) {
+	if !l.off.Load() {
+		l.LED.SetValue(key, value)
+	}
+}
+
+func (l *switchableLED) SetFineValue(key ctrl.MidiKey, value uint16) {
+	if !l.off.Load() {
+		l.LED.SetFineValue(key, value)
+	}
+}
+
+func (l *switchableLED) SetColor(key ctrl.MidiKey, color ctrl.Color) {
+	if !l.off.Load() {
+		l.LED.SetColor(key, color)
+	}
+}
+
+func (l *switchableLED) SetAnimation(key ctrl.MidiKey, animation ctrl.Animation) {
+	if !l.off.Load() {
+		l.LED.SetAnimation(key, animation)
+	}
+}
+
+func (l *switchableLED) SetText(key ctrl.MidiKey, text string) {
+	if !l.off.Load() {
+		l.LED.SetText(key, text)
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/gomidi/midi"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/led"
+)
+
+type recordingWriter struct {
+	mutex    sync.Mutex
+	channel  uint8
+	messages []string
+}
+
+func (w *recordingWriter) Channel() uint8      { return w.channel }
+func (w *recordingWriter) SetChannel(no uint8) { w.channel = no }
+func (w *recordingWriter) Write(msg midi.Message) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.messages = append(w.messages, msg.String())
+	return nil
+}
+
+// offlineTCIClient returns a TCI client that never connects, the controllers only register with it.
+func offlineTCIClient(t *testing.T) *client.Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	host := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+	return client.KeepOpen(host, time.Second, false)
+}
+
+func TestApplyMappings(t *testing.T) {
+	activeControls.Store(newControls())
+	defer activeControls.Store(newControls())
+	tciEvents = newTCIDispatcher()
+	defer func() { tciEvents = newTCIDispatcher() }()
+
+	w := &recordingWriter{}
+	profile, err := led.BuiltinProfile("dj2go2")
+	require.NoError(t, err)
+	testDevice := &device{leds: led.NewController(w, profile)}
+	midiDevices := devices{testDevice}
+	tciClient := offlineTCIClient(t)
+
+	button := func(mappingType ctrl.MappingType, key int8) ctrl.Mapping {
+		return ctrl.Mapping{Type: mappingType, Key: key}
+	}
+	applyMappings([]ctrl.Mapping{
+		button(ctrl.MOXMapping, 1),
+		button(ctrl.TuneMapping, 2),
+		button(ctrl.MuteMapping, 3),
+		button(ctrl.MuteMapping, 3),
+		button(ctrl.TuneMapping, 5),
+	}, midiDevices, tciClient)
+	first := currentControls().mappings
+	require.Len(t, first, 5)
+
+	// the controllers of the next configuration start with the current state
+	tciEvents.SetMute(false)
+
+	applyMappings([]ctrl.Mapping{
+		button(ctrl.MuteMapping, 3),
+		button(ctrl.MOXMapping, 1),
+		button(ctrl.TuneMapping, 4),
+		{Type: ctrl.TuneMapping, Key: 5, TRX: 1},
+		button(ctrl.MuteMapping, 6),
+	}, midiDevices, tciClient)
+	second := currentControls().mappings
+	require.Len(t, second, 5)
+
+	assert.Same(t, first[2], second[0], "the first of the duplicate mappings is kept")
+	assert.Same(t, first[0], second[1], "an unchanged mapping is kept")
+	assert.NotSame(t, first[1], second[2], "a changed mapping gets a new controller")
+	assert.Same(t, first[0].controller, currentControls().buttons[deviceKey{key: ctrl.MidiKey{Key: 1}}])
+	for _, control := range second {
+		assert.False(t, control.led.off.Load(), "the LED of %s is switched off", control.mapping.Type)
+	}
+	for _, retired := range []*mappedControl{first[1], first[3], first[4]} {
+		assert.True(t, retired.led.off.Load(), "the LED of the retired %s is still switched on", retired.mapping.Type)
+	}
+
+	// a retired controller cannot switch its LED on again
+	first[1].led.SetOn(ctrl.MidiKey{Key: 2}, true)
+	currentControls().Close()
+	testDevice.leds.Close()
+
+	keyOff := "channel.NoteOn channel 0 key 2 velocity 0"
+	keyOn := "channel.NoteOn channel 0 key 2 velocity 127"
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	require.Contains(t, w.messages, keyOff)
+	lastOff := 0
+	for i, message := range w.messages {
+		if message == keyOff {
+			lastOff = i
+		}
+	}
+	assert.NotContains(t, w.messages[lastOff:], keyOn)
+
+	assert.Contains(t, w.messages, "channel.NoteOn channel 0 key 6 velocity 127", "the added mute button is not switched on")
+
+	// keys that are still in use are not switched off
+	assert.NotContains(t, w.messages, "channel.NoteOn channel 0 key 3 velocity 0")
+	assert.NotContains(t, w.messages, "channel.NoteOn channel 0 key 5 velocity 0")
+}
+
+type txRecorder struct {
+	mutex     sync.Mutex
+	ptt       []bool
+	connected []bool
+}
+
+func (r *txRecorder) Connected(connected bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.connected = append(r.connected, connected)
+}
+
+func (r *txRecorder) SetTX(trx int, ptt bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ptt = append(r.ptt, ptt)
+}
+
+func TestTCIEvents_OnlyActiveControls(t *testing.T) {
+	defer activeControls.Store(newControls())
+
+	recorder := &txRecorder{}
+	active := newControls()
+	active.mappings = append(active.mappings, &mappedControl{controller: recorder})
+	activeControls.Store(active)
+	tciEvents.SetTX(0, true)
+
+	activeControls.Store(newControls())
+	tciEvents.SetTX(0, false)
+
+	assert.Equal(t, []bool{true}, recorder.ptt)
+}
+
+func TestTCIEvents_ConnectedForAddedControls(t *testing.T) {
+	defer activeControls.Store(newControls())
+	tciEvents = newTCIDispatcher()
+	defer func() { tciEvents = newTCIDispatcher() }()
+
+	tciEvents.Connected(true)
+	recorder := &txRecorder{}
+	control := &mappedControl{controller: recorder}
+	next := newControls()
+	next.mappings = append(next.mappings, control)
+	tciEvents.activate(next, []*mappedControl{control})
+
+	assert.Equal(t, []bool{true}, recorder.connected)
+	assert.Same(t, next, currentControls())
+}
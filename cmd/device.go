@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gitlab.com/gomidi/midi"
+	"gitlab.com/gomidi/midi/reader"
+	"gitlab.com/gomidi/midi/writer"
+
+	"github.com/ftl/midi2tci/pkg/cfg"
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/led"
+	"github.com/ftl/midi2tci/pkg/mcu"
+)
+
+// deviceKey identifies a control on a specific MIDI device.
+type deviceKey struct {
+	device string
+	key    ctrl.MidiKey
+}
+
+// deviceCheckInterval is the time between two checks if a MIDI device was unplugged or plugged in again.
+const deviceCheckInterval = 2 * time.Second
+
+// device is a MIDI device with its LEDs and displays. The device can be unplugged and plugged in again
+// while midi2tci is running, the LEDs keep their state in the meantime.
+type device struct {
+	name    string
+	config  cfg.Device
+	profile led.Profile
+	drv     midi.Driver
+	port    *port
+	writer  *writer.Writer
+	leds    *led.Controller
+	surface *mcu.Surface
+
+	mutex        sync.Mutex
+	out          midi.Out
+	in           midi.In
+	reader       *reader.Reader
+	outName      string
+	inName       string
+	tciConnected bool
+	closed       bool
+}
+
+// openDevice creates the given device and opens its output. If the device is not available, it is opened
+// as soon as it is plugged in.
+func openDevice(drv midi.Driver, config cfg.Device) (*device, error) {
+	profile, err := config.IndicatorProfile()
+	if err != nil {
+		return nil, fmt.Errorf("invalid LED profile: %w", err)
+	}
+
+	result := &device{
+		name:    config.Name,
+		config:  config,
+		profile: profile,
+		drv:     drv,
+		port:    new(port),
+	}
+	result.writer = writer.New(result.port)
+	result.writer.ConsolidateNotes(false)
+	result.leds = led.NewController(result.writer, profile)
+
+	// MCU devices show the mapped controls on their scribble strips
+	if profile.Protocol == led.MCUProtocol {
+		result.surface = mcu.NewSurface(result.leds)
+		result.leds.SetTextDisplay(result.surface)
+	}
+
+	result.mutex.Lock()
+	defer result.mutex.Unlock()
+	err = result.open()
+	if err != nil {
+		log.Printf("MIDI device %s is not available, waiting for it: %v", result, err)
+	}
+
+	return result, nil
+}
+
+func (d *device) String() string {
+	if d.name != "" {
+		return d.name
+	}
+	if d.config.PortName != "" {
+		return d.config.PortName
+	}
+	return fmt.Sprintf("port %d", d.config.PortNumber)
+}
+
+// selection returns the port number and name that are used to find the ports of the device. Once the device
+// was opened, its ports are found by their name, even if their numbers change.
+func (d *device) selection(knownName string) (int, string) {
+	if knownName != "" {
+		return -1, knownName
+	}
+	if d.config.PortName != "" {
+		return -1, d.config.PortName
+	}
+	return d.config.PortNumber, ""
+}
+
+// open opens the output of the device and sends the init sequence. If the device already listens for incoming
+// messages, the input is opened, too. Then the current state of the LEDs is written to the device.
+// The mutex must be held.
+func (d *device) open() error {
+	number, name := d.selection(d.outName)
+	out, err := midi.OpenOut(d.drv, number, name)
+	if err != nil {
+		return err
+	}
+	log.Printf("Opened %s successfully for writing", out)
+	d.out = out
+	d.outName = out.String()
+	d.port.set(out)
+
+	if d.reader != nil {
+		err = d.openInput()
+		if err != nil {
+			d.closePorts()
+			return err
+		}
+	}
+
+	if len(d.config.InitSequence) > 0 {
+		log.Printf("MIDI init sequence %s", d)
+		err = SendRawMidiSequence(d.writer, d.config.InitSequence)
+		if err != nil {
+			d.closePorts()
+			return err
+		}
+	}
+	if d.tciConnected {
+		SendRawMidiSequence(d.writer, d.config.ConnectSequence)
+	}
+	d.leds.Resync()
+
+	return nil
+}
+
+// openInput opens the input of the device and connects it with the reader. The mutex must be held.
+func (d *device) openInput() error {
+	number, name := d.selection(d.inName)
+	in, err := midi.OpenIn(d.drv, number, name)
+	if err != nil {
+		return err
+	}
+	log.Printf("Opened %s successfully for reading", in)
+	d.in = in
+	d.inName = in.String()
+
+	err = d.reader.ListenTo(in)
+	if err != nil {
+		in.Close()
+		d.in = nil
+		return err
+	}
+	return nil
+}
+
+// closePorts closes the input and output of the device. The mutex must be held.
+func (d *device) closePorts() {
+	d.port.set(nil)
+	if d.in != nil {
+		d.in.StopListening()
+		d.in.Close()
+		d.in = nil
+	}
+	if d.out != nil {
+		d.out.Close()
+		d.out = nil
+	}
+}
+
+// supervise checks regularly if the device was unplugged or plugged in again, until the given context is done.
+func (d *device) supervise(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.check()
+		}
+	}
+}
+
+// check closes the device if its output port disappeared and reopens the device if it is available again.
+func (d *device) check() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return
+	}
+
+	if d.out != nil {
+		if d.available() {
+			return
+		}
+		log.Printf("MIDI device %s was disconnected", d)
+		d.closePorts()
+		return
+	}
+
+	err := d.open()
+	if err != nil {
+		if rootFlags.trace {
+			log.Printf("MIDI device %s is still not available: %v", d, err)
+		}
+		return
+	}
+	log.Printf("MIDI device %s was reconnected", d)
+}
+
+// available indicates if the driver still knows the output port of the device. The mutex must be held.
+func (d *device) available() bool {
+	outs, err := d.drv.Outs()
+	if err != nil {
+		return false
+	}
+	for _, out := range outs {
+		if out.String() == d.outName {
+			return true
+		}
+	}
+	return false
+}
+
+// listen dispatches the incoming MIDI messages to the controls of this device. If the device is available,
+// its input is opened immediately, otherwise as soon as the device is plugged in.
+func (d *device) listen() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.reader = reader.New(
+		reader.NoLogger(),
+		reader.NoteOn(func(_ *reader.Position, channel, key, velocity uint8) {
+			controls := currentControls()
+			button, ok := controls.buttons[d.key(channel, int8(key))]
+			if ok {
+				button.Pressed()
+			}
+		}),
+		reader.NoteOff(func(_ *reader.Position, channel, key, _ uint8) {
+			controls := currentControls()
+			button, ok := controls.buttons[d.key(channel, int8(key))]
+			if !ok {
+				return
+			}
+			releasable, ok := button.(ReleasableButton)
+			if ok {
+				releasable.Released()
+			}
+		}),
+		reader.ControlChange(func(_ *reader.Position, channel, controller, value uint8) {
+			controls := currentControls()
+			midiKey := d.key(channel, int8(controller))
+			fader, ok := controls.faders[midiKey]
+			if ok {
+				fader.Moved()
+			}
+			encoder, ok := controls.encoders[midiKey]
+			if ok {
+				encoder.Changed(ctrl.DecodeEncoderTurns(d.profile.EncoderEncoding, value))
+			}
+			poti, ok := controls.potis[midiKey]
+			if ok {
+				poti.Changed(int(value))
+			}
+		}),
+		reader.Pitchbend(func(_ *reader.Position, channel uint8, value int16) {
+			controls := currentControls()
+			scaledValue := uint8((value + 0x2000) >> 7)
+			midiKey := d.key(channel, ctrl.PitchbendKey)
+			fader, ok := controls.faders[midiKey]
+			if ok {
+				fader.Moved()
+			}
+			encoder, ok := controls.encoders[midiKey]
+			if ok {
+				delta := int(scaledValue) - int(0x40)
+				encoder.Changed(delta)
+			}
+			poti, ok := controls.potis[midiKey]
+			if !ok {
+				return
+			}
+			finePoti, ok := poti.(FineValueControl)
+			if ok {
+				finePoti.ChangedFine(int(value) + 0x2000)
+			} else {
+				poti.Changed(int(scaledValue))
+			}
+		}),
+		reader.Each(func(_ *reader.Position, msg midi.Message) {
+			if !rootFlags.trace {
+				return
+			}
+			if d.name == "" {
+				log.Printf("rx: %#v", msg)
+			} else {
+				log.Printf("rx %s: %#v", d.name, msg)
+			}
+		}),
+	)
+	if d.out == nil {
+		return
+	}
+	err := d.openInput()
+	if err != nil {
+		log.Printf("Cannot listen to MIDI device %s, waiting for it: %v", d, err)
+		d.closePorts()
+	}
+}
+
+func (d *device) key(channel uint8, key int8) deviceKey {
+	return deviceKey{device: d.name, key: ctrl.MidiKey{Channel: channel, Key: key}}
+}
+
+func (d *device) Close() {
+	d.mutex.Lock()
+	d.closed = true
+	d.closePorts()
+	d.mutex.Unlock()
+
+	d.leds.Close()
+}
+
+func (d *device) Resync() {
+	d.leds.Resync()
+}
+
+// Connected sends the connect or disconnect sequence when the TCI connection changes.
+func (d *device) Connected(connected bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.tciConnected = connected
+	if d.closed || d.out == nil {
+		return
+	}
+
+	if connected {
+		SendRawMidiSequence(d.writer, d.config.ConnectSequence)
+		d.leds.Resync()
+	} else {
+		SendRawMidiSequence(d.writer, d.config.DisconnectSequence)
+	}
+}
+
+// port is the output of a device. The underlying MIDI port is replaced when the device is reconnected,
+// while the device is disconnected, all messages are dropped.
+type port struct {
+	mutex sync.Mutex
+	out   midi.Out
+}
+
+func (p *port) set(out midi.Out) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.out = out
+}
+
+func (p *port) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.out == nil {
+		return 0, midi.ErrPortClosed
+	}
+	return p.out.Write(b)
+}
+
+// devices contains all opened MIDI devices, the first device is the default device.
+type devices []*device
+
+// Get returns the device with the given name, the empty name denotes the default device.
+func (d devices) Get(name string) (*device, bool) {
+	if name == "" && len(d) > 0 {
+		return d[0], true
+	}
+	for _, device := range d {
+		if device.name == name {
+			return device, true
+		}
+	}
+	return nil, false
+}
+
+func (d devices) Resync() {
+	for _, device := range d {
+		device.Resync()
+	}
+}
+
+func (d devices) Close() {
+	for _, device := range d {
+		device.Close()
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/midi2tci/pkg/cfg"
+)
+
+var fmtCmd = &cobra.Command{
+	Use:     "fmt",
+	Aliases: []string{"migrate"},
+	Short:   "Upgrade the configuration file to the current schema and write it in the canonical format",
+	Long: `Upgrade the configuration file to the current schema and write it in the canonical format.
+
+Deprecated mapping types and option values are replaced, the values are normalized and the mappings are sorted
+by device, channel and key. A JSON configuration file without includes and variables is replaced by its formatted
+version, all other configurations are written as one JSON file that is given with --output.`,
+	Run: runFmt,
+}
+
+var fmtFlags = struct {
+	check  bool
+	output string
+}{}
+
+func init() {
+	fmtCmd.Flags().BoolVar(&fmtFlags.check, "check", false, "only check if the configuration file is formatted and up to date, exit with 1 if not")
+	fmtCmd.Flags().StringVar(&fmtFlags.output, "output", "", "write the formatted configuration to this file instead of replacing the configuration file")
+	rootCmd.AddCommand(fmtCmd)
+}
+
+func runFmt(_ *cobra.Command, _ []string) {
+	filename := requireConfigFile()
+	formatted, err := cfg.Format(filename)
+	if err != nil {
+		log.Fatalf("Cannot format configuration file: %v", err)
+	}
+	for _, upgrade := range formatted.Upgrades {
+		log.Print(upgrade)
+	}
+
+	if fmtFlags.check {
+		if len(formatted.Upgrades) > 0 {
+			log.Fatalf("%s needs to be upgraded, use fmt to upgrade it", filename)
+		}
+		if !formatted.SelfContained {
+			return
+		}
+		current, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !bytes.Equal(current, formatted.Data) {
+			log.Fatalf("%s is not formatted, use fmt to format it", filename)
+		}
+		return
+	}
+
+	output := fmtFlags.output
+	if output == "" {
+		if !formatted.SelfContained {
+			log.Fatalf("%s uses includes, variables or another format than JSON, use --output to write the formatted configuration into a new file", filename)
+		}
+		output = filename
+	}
+	err = os.WriteFile(output, formatted.Data, 0644)
+	if err != nil {
+		log.Fatalf("Cannot write the formatted configuration: %v", err)
+	}
+	log.Printf("Formatted configuration written to %s", output)
+}
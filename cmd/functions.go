@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/midi2tci/pkg/cfg"
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+var functionsCmd = &cobra.Command{
+	Use:   "functions",
+	Short: "Print the reference of all available functions",
+	Run:   runFunctions,
+}
+
+var functionsFlags = struct {
+	schema bool
+}{}
+
+func init() {
+	functionsCmd.Flags().BoolVar(&functionsFlags.schema, "schema", false, "print a JSON schema of the configuration file instead, e.g. for the autocompletion in your editor")
+	rootCmd.AddCommand(functionsCmd)
+}
+
+func runFunctions(_ *cobra.Command, _ []string) {
+	if functionsFlags.schema {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(cfg.JSONSchema())
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	writeFunctionReference(os.Stdout)
+}
+
+// writeFunctionReference writes the reference of all registered mapping types in markdown.
+func writeFunctionReference(w io.Writer) {
+	fmt.Fprintln(w, "# Functions")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Each mapping has a `type`, the MIDI `channel` and `key` of the control, the `trx` and, if required, the `vfo`. The options are given as strings in `options`.")
+
+	var deprecated []ctrl.Definition
+	for _, mappingType := range ctrl.MappingTypes() {
+		definition := ctrl.Definitions[mappingType]
+		if definition.ReplacedBy != "" {
+			deprecated = append(deprecated, definition)
+			continue
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "## %s\n\n", mappingType)
+		fmt.Fprintln(w, definition.Description)
+		fmt.Fprintln(w)
+
+		controls := make([]string, 0, 2)
+		for _, control := range definition.Controls() {
+			controls = append(controls, control.String())
+		}
+		fmt.Fprintf(w, "* Control: %s\n", strings.Join(controls, " or "))
+		if definition.VFO {
+			fmt.Fprintln(w, "* VFO: required")
+		}
+		if definition.ValueControl {
+			fmt.Fprintln(w, "* Supports the [value control options](#value-control-options)")
+		}
+		writeOptionTable(w, definition.Options)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## Value Control Options")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "These options can be used with all functions that are controlled by a poti or an encoder.")
+	writeOptionTable(w, ctrl.ValueControlOptionSchemas)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## LED Options")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "These options can be used with all functions to configure the LED of the control.")
+	writeOptionTable(w, ctrl.CommonOptionSchemas)
+
+	if len(deprecated) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## Deprecated Functions")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "These functions still work, but they are replaced by other functions. Use `midi2tci fmt` to upgrade your configuration.")
+	fmt.Fprintln(w)
+	for _, definition := range deprecated {
+		fmt.Fprintf(w, "* `%s`: use [%s](#%s)\n", definition.Type, definition.ReplacedBy, definition.ReplacedBy)
+	}
+}
+
+func writeOptionTable(w io.Writer, options []ctrl.OptionSchema) {
+	if len(options) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Option | Value | Default | Description |")
+	fmt.Fprintln(w, "|--------|-------|---------|-------------|")
+	for _, option := range options {
+		name := "`" + option.Name + "`"
+		if option.Prefix {
+			name = "`" + option.Name + "_<mode>`"
+		}
+		if option.Required {
+			name += " (required)"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s |\n", name, optionValue(option), option.Default, option.Description)
+	}
+}
+
+func optionValue(option ctrl.OptionSchema) string {
+	if len(option.Values) > 0 {
+		return strings.Join(option.Values, ", ")
+	}
+	if option.Range != nil {
+		return fmt.Sprintf("%s (%d to %d)", option.Type, option.Range[0], option.Range[1])
+	}
+	return string(option.Type)
+}
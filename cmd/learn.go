@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gitlab.com/gomidi/midi"
+	"gitlab.com/gomidi/midi/reader"
+	driver "gitlab.com/gomidi/rtmididrv"
+
+	"github.com/ftl/midi2tci/pkg/cfg"
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/learn"
+)
+
+// learnQuietTime is the time without any incoming message after which a control is considered to be released.
+const learnQuietTime = 700 * time.Millisecond
+
+var learnCmd = &cobra.Command{
+	Use:   "learn",
+	Short: "Build a configuration by touching the controls of the MIDI device",
+	Long: `Build a configuration by touching the controls of the MIDI device.
+
+For each function, touch the control that should be used: press a button, or turn an encoder or move a poti
+slowly in both directions. The kind of the control is detected from the MIDI messages it sends.
+The VFO and the required options of the mapping type are asked for after the control was detected.
+Without --functions, you choose the mapping type after each detected control. Press Ctrl+C to finish.`,
+	Run: runLearn,
+}
+
+var learnFlags = struct {
+	output    string
+	functions []string
+}{}
+
+func init() {
+	learnCmd.Flags().StringVar(&learnFlags.output, "output", "./learned_config.json", "the configuration file that is written, an existing file is not overwritten")
+	learnCmd.Flags().StringSliceVar(&learnFlags.functions, "functions", nil, "the mapping types that should be learned in this order, separated by commas")
+	rootCmd.AddCommand(learnCmd)
+}
+
+func runLearn(_ *cobra.Command, _ []string) {
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer done()
+
+	if _, err := os.Stat(learnFlags.output); err == nil {
+		log.Fatalf("The file %s already exists, use --output to choose another file", learnFlags.output)
+	}
+	for _, function := range learnFlags.functions {
+		if _, ok := ctrl.Definitions[ctrl.MappingType(function)]; !ok {
+			log.Fatalf("Unknown mapping type %s, use functions to list the available mapping types", function)
+		}
+	}
+
+	drv, err := driver.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer drv.Close()
+
+	number, name := learnPortSelection()
+	in, err := midi.OpenIn(drv, number, name)
+	if err != nil {
+		log.Fatalf("Cannot open MIDI input: %v", err)
+	}
+	defer in.Close()
+
+	events := make(chan learn.Event, 100)
+	send := func(event learn.Event) {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+	rd := reader.New(
+		reader.NoLogger(),
+		reader.NoteOn(func(_ *reader.Position, channel, key, _ uint8) {
+			send(learn.Event{Type: learn.NoteMessage, Channel: channel, Key: int8(key)})
+		}),
+		reader.ControlChange(func(_ *reader.Position, channel, controller, value uint8) {
+			send(learn.Event{Type: learn.ControlChangeMessage, Channel: channel, Key: int8(controller), Value: int(value)})
+		}),
+		reader.Pitchbend(func(_ *reader.Position, channel uint8, value int16) {
+			send(learn.Event{Type: learn.PitchbendMessage, Channel: channel, Key: ctrl.PitchbendKey, Value: int(value) + 0x2000})
+		}),
+	)
+	err = rd.ListenTo(in)
+	if err != nil {
+		log.Fatalf("Cannot listen to MIDI input: %v", err)
+	}
+	defer in.StopListening()
+	log.Printf("Learning the controls of %s", in)
+
+	session := &learnSession{
+		ctx:    ctx,
+		events: events,
+		lines:  readLines(),
+	}
+	if len(learnFlags.functions) > 0 {
+		for _, function := range learnFlags.functions {
+			if !session.learn(ctrl.MappingType(function)) {
+				break
+			}
+		}
+	} else {
+		for session.learn("") {
+		}
+	}
+
+	if len(session.mappings) == 0 {
+		log.Print("No controls were learned")
+		return
+	}
+	config := cfg.Configuration{
+		PortName:        in.String(),
+		TCIAddress:      rootFlags.tciAddress,
+		EncoderEncoding: session.encoding,
+		Mappings:        session.mappings,
+	}
+	err = cfg.WriteFile(learnFlags.output, config)
+	if err != nil {
+		log.Fatalf("Cannot write the configuration: %v", err)
+	}
+	log.Printf("Wrote %d mappings to %s", len(session.mappings), learnFlags.output)
+}
+
+// learnPortSelection returns the port that is used to learn the controls: the port given on the command line,
+// the port of the first device in the configuration file, or the first port.
+func learnPortSelection() (int, string) {
+	if rootFlags.portName != "" {
+		return -1, rootFlags.portName
+	}
+	if rootFlags.portNumber >= 0 {
+		return rootFlags.portNumber, ""
+	}
+	if rootFlags.configFile == "" {
+		return 0, ""
+	}
+	overrides, err := configOverrides()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := cfg.ReadFile(rootFlags.configFile, overrides...)
+	if err != nil {
+		return 0, ""
+	}
+	device := config.DeviceConfigs()[0]
+	if device.PortName != "" {
+		return -1, device.PortName
+	}
+	return device.PortNumber, ""
+}
+
+// readLines reads the lines from stdin in the background.
+func readLines() <-chan string {
+	result := make(chan string)
+	go func() {
+		defer close(result)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			result <- strings.TrimSpace(scanner.Text())
+		}
+	}()
+	return result
+}
+
+type learnSession struct {
+	ctx      context.Context
+	events   chan learn.Event
+	lines    <-chan string
+	mappings []ctrl.Mapping
+	encoding ctrl.EncoderEncoding
+}
+
+// learn detects one control and adds a mapping of the given type. If no mapping type is given, the user
+// chooses the mapping type after the control was detected. It returns false if learning is finished.
+func (s *learnSession) learn(mappingType ctrl.MappingType) bool {
+	for {
+		if mappingType == "" {
+			fmt.Println("Touch the next control, press Ctrl+C to finish")
+		} else {
+			fmt.Printf("Touch the control for %s, press Ctrl+C to finish\n", mappingType)
+		}
+		events, ok := learn.Capture(s.events, learnQuietTime, s.ctx.Done())
+		if !ok {
+			return false
+		}
+		control, err := learn.Detect(events)
+		if err != nil {
+			fmt.Printf("%v, try again\n", err)
+			continue
+		}
+		fmt.Printf("Detected %s\n", control)
+
+		selectedType := mappingType
+		if selectedType == "" {
+			answer, ok := s.ask("Mapping type (empty to skip, ? to list)")
+			if !ok {
+				return false
+			}
+			if answer == "" {
+				continue
+			}
+			if answer == "?" {
+				s.listMappingTypes(control)
+				continue
+			}
+			selectedType = ctrl.MappingType(answer)
+		}
+
+		mapping, err := control.Mapping(selectedType)
+		if err != nil {
+			fmt.Printf("%v, try again\n", err)
+			continue
+		}
+		if used, ok := s.usedBy(mapping); ok {
+			fmt.Printf("This control is already used by %s, try again\n", used)
+			continue
+		}
+		if ctrl.Definitions[selectedType].VFO {
+			vfo, ok := s.askVFO()
+			if !ok {
+				return false
+			}
+			mapping.VFO = vfo
+		}
+		if !s.askOptions(&mapping) {
+			return false
+		}
+		if control.Type == ctrl.EncoderControl {
+			if s.encoding == "" {
+				s.encoding = control.Encoding
+			} else if s.encoding != control.Encoding {
+				fmt.Printf("Warning: this encoder uses the %s encoding, the other encoders use %s\n", control.Encoding, s.encoding)
+			}
+		}
+
+		s.mappings = append(s.mappings, mapping)
+		fmt.Printf("Added %s\n", selectedType)
+		return true
+	}
+}
+
+func (s *learnSession) ask(question string) (string, bool) {
+	fmt.Printf("%s: ", question)
+	select {
+	case line, ok := <-s.lines:
+		return line, ok
+	case <-s.ctx.Done():
+		fmt.Println()
+		return "", false
+	}
+}
+
+func (s *learnSession) askVFO() (string, bool) {
+	for {
+		answer, ok := s.ask("VFO (A or B, empty for A)")
+		if !ok {
+			return "", false
+		}
+		if answer == "" {
+			return "VFOA", true
+		}
+		_, err := ctrl.AtoVFO(answer)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		return "VFO" + strings.ToUpper(answer[len(answer)-1:]), true
+	}
+}
+
+// askOptions asks for the values of the required options of the mapping type.
+func (s *learnSession) askOptions(mapping *ctrl.Mapping) bool {
+	for _, option := range ctrl.Definitions[mapping.Type].Options {
+		if !option.Required || option.Prefix {
+			continue
+		}
+		question := fmt.Sprintf("%s (%s)", option.Name, option.Description)
+		if len(option.Values) > 0 {
+			question = fmt.Sprintf("%s (%s, one of %s)", option.Name, option.Description, strings.Join(option.Values, ", "))
+		}
+		for {
+			answer, ok := s.ask(question)
+			if !ok {
+				return false
+			}
+			err := option.Validate(answer)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if mapping.Options == nil {
+				mapping.Options = make(map[string]string)
+			}
+			mapping.Options[option.Name], _ = option.Canonical(answer)
+			break
+		}
+	}
+	return true
+}
+
+// usedBy returns the type of the learned mapping that already uses the same control. Buttons send notes,
+// all other controls send control changes, so they do not interfere even if they use the same key.
+func (s *learnSession) usedBy(mapping ctrl.Mapping) (ctrl.MappingType, bool) {
+	isButton := func(m ctrl.Mapping) bool {
+		return ctrl.Definitions[m.Type].ControlType(m) == ctrl.ButtonControl
+	}
+	for _, learned := range s.mappings {
+		if learned.MidiKey() == mapping.MidiKey() && isButton(learned) == isButton(mapping) {
+			return learned.Type, true
+		}
+	}
+	return "", false
+}
+
+func (s *learnSession) listMappingTypes(control learn.Control) {
+	for _, mappingType := range ctrl.MappingTypes() {
+		if ctrl.Definitions[mappingType].ReplacedBy != "" {
+			continue
+		}
+		if _, err := control.Mapping(mappingType); err == nil {
+			fmt.Printf("  %-24s %s\n", mappingType, ctrl.Definitions[mappingType].Description)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/midi2tci/pkg/hardware"
+)
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles [profile]",
+	Short: "List the built-in device profiles or the controls of one profile",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   runProfiles,
+}
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+}
+
+func runProfiles(_ *cobra.Command, args []string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	if len(args) == 0 {
+		for _, name := range hardware.Names() {
+			profile, _ := hardware.Get(name)
+			fmt.Fprintf(w, "%s\t%s\n", profile.Name, profile.Description)
+		}
+		return
+	}
+
+	profile, err := hardware.Get(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(w, "%s: %s, LED profile %s\n\n", profile.Name, profile.Description, profile.Indicators)
+	fmt.Fprintln(w, "control\ttype\tchannel\tkey")
+	for _, control := range profile.Controls {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", control.Name, control.Type(), control.Channel, control.Key)
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ftl/tci/client"
+
+	"github.com/ftl/midi2tci/pkg/cfg"
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+// reloadDelay is the time to wait after the last change of the configuration files before they are reloaded.
+// Editors often write a file in several steps.
+const reloadDelay = 500 * time.Millisecond
+
+// reloader applies a changed configuration file to the running midi2tci, while the MIDI devices and the TCI
+// connection stay open. Only the mappings and the CW macro variables are reloaded. The configuration file and
+// all included files are watched.
+type reloader struct {
+	filename  string
+	config    cfg.Configuration
+	devices   devices
+	tciClient *client.Client
+
+	files map[string]bool
+	dirs  map[string]bool
+}
+
+// watch reloads the configuration when the configuration file or one of the included files changes or when
+// SIGHUP is received, until the given context is done.
+func (r *reloader) watch(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// the directories are watched, because many editors replace the files instead of writing them
+	var changes chan fsnotify.Event
+	var watcherErrors chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		defer watcher.Close()
+		err = r.watchFiles(watcher)
+	}
+	if err != nil {
+		log.Printf("Cannot watch the configuration file, send SIGHUP to reload it: %v", err)
+		watcher = nil
+	} else {
+		changes = watcher.Events
+		watcherErrors = watcher.Errors
+	}
+
+	// a reloaded configuration may include other files
+	reload := func() {
+		if !r.reload() || watcher == nil {
+			return
+		}
+		err := r.watchFiles(watcher)
+		if err != nil {
+			log.Printf("Cannot watch the configuration file: %v", err)
+		}
+	}
+
+	changed := r.filename
+	delay := time.NewTimer(reloadDelay)
+	delay.Stop()
+	defer delay.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			log.Print("Received SIGHUP")
+			reload()
+		case event := <-changes:
+			if !r.files[absPath(event.Name)] || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			changed = event.Name
+			delay.Reset(reloadDelay)
+		case err := <-watcherErrors:
+			log.Printf("Cannot watch the configuration file: %v", err)
+		case <-delay.C:
+			log.Printf("The configuration file %s was changed", changed)
+			reload()
+		}
+	}
+}
+
+// watchFiles watches the directories of the configuration file and of all files included by the current
+// configuration. The directories that are not needed anymore are no longer watched.
+func (r *reloader) watchFiles(watcher *fsnotify.Watcher) error {
+	files := map[string]bool{absPath(r.filename): true}
+	for _, file := range r.config.Files {
+		files[absPath(file)] = true
+	}
+	dirs := make(map[string]bool)
+	for file := range files {
+		dirs[filepath.Dir(file)] = true
+	}
+
+	for dir := range dirs {
+		if r.dirs[dir] {
+			continue
+		}
+		err := watcher.Add(dir)
+		if err != nil {
+			return err
+		}
+	}
+	for dir := range r.dirs {
+		if !dirs[dir] {
+			watcher.Remove(dir)
+		}
+	}
+	r.files = files
+	r.dirs = dirs
+	return nil
+}
+
+func absPath(filename string) string {
+	result, err := filepath.Abs(filename)
+	if err != nil {
+		return filepath.Clean(filename)
+	}
+	return result
+}
+
+// reload reads the configuration file and applies the new mappings. An invalid configuration is rejected,
+// the current configuration stays active. It returns true if the new configuration was applied.
+func (r *reloader) reload() bool {
+	config, err := readConfiguration(r.filename, false)
+	if err != nil {
+		log.Printf("Keeping the current configuration: %v", err)
+		return false
+	}
+
+	if r.restartRequired(config) {
+		log.Print("Changes of the devices, the displays, the TCI address or the control address take effect after a restart")
+	}
+	for name, value := range config.CWVariables {
+		err := ctrl.MacroVariables.Set(name, value)
+		if err != nil {
+			log.Printf("Cannot set CW macro variable %s: %v", name, err)
+		}
+	}
+
+	// the added controllers start with the current state of the TRX, then all LEDs are repainted
+	applyMappings(config.Mappings, r.devices, r.tciClient)
+	r.devices.Resync()
+	r.config = config
+	log.Printf("Reloaded %d mappings", len(config.Mappings))
+	return true
+}
+
+// restartRequired indicates if the given configuration changes parts that cannot be reloaded.
+func (r *reloader) restartRequired(config cfg.Configuration) bool {
+	return !reflect.DeepEqual(r.config.DeviceConfigs(), config.DeviceConfigs()) ||
+		!reflect.DeepEqual(r.config.Displays, config.Displays) ||
+		r.config.TCIAddress != config.TCIAddress ||
+		r.config.ControlAddress != config.ControlAddress
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -14,12 +15,13 @@ import (
 	"github.com/ftl/tci/client"
 	"github.com/spf13/cobra"
 	"gitlab.com/gomidi/midi"
-	"gitlab.com/gomidi/midi/reader"
 	"gitlab.com/gomidi/midi/writer"
 	driver "gitlab.com/gomidi/rtmididrv"
 
-	"github.com/ftl/midi2tci/pkg/cfg"
 	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/display"
+	"github.com/ftl/midi2tci/pkg/led"
+	"github.com/ftl/midi2tci/pkg/sequence"
 )
 
 var version string = "develop"
@@ -27,16 +29,22 @@ var version string = "develop"
 var rootCmd = &cobra.Command{
 	Use:   "midi2tci",
 	Short: "Control ExpertSDR through TCI with a MIDI input device",
-	Run:   run,
+	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		resolveConfigFile(cmd)
+	},
+	Run: run,
 }
 
 var rootFlags = struct {
-	trace      bool
-	traceTci   bool
-	portNumber int
-	portName   string
-	tciAddress string
-	configFile string
+	trace          bool
+	traceTci       bool
+	portNumber     int
+	portName       string
+	tciAddress     string
+	configFile     string
+	controlAddress string
+	lenient        bool
+	overrides      []string
 }{}
 
 func Execute() {
@@ -53,35 +61,21 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&rootFlags.tciAddress, "tci", "", "the address of the TCI server")
 	rootCmd.PersistentFlags().BoolVar(&rootFlags.trace, "trace", false, "print a trace of all incoming MIDI messages")
 	rootCmd.PersistentFlags().BoolVar(&rootFlags.traceTci, "traceTci", false, "print tracing information of the TCI client")
-	rootCmd.PersistentFlags().StringVar(&rootFlags.configFile, "config", "./config.json", "the configuration file")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.configFile, "config", "", "the configuration file, by default config.json, .yaml or .toml is searched in the working directory, the configuration directory of the user and the directory of the executable")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.controlAddress, "control", "", "the address of the local control interface, e.g. localhost:40010")
+	rootCmd.PersistentFlags().StringArrayVar(&rootFlags.overrides, "set", nil, "override a value of the configuration file, e.g. tci_address=localhost:40001 or mappings.vfo.options.step=5")
+	rootCmd.Flags().BoolVar(&rootFlags.lenient, "lenient", false, "start even if the configuration file is missing or invalid, invalid mappings are ignored")
 }
 
 func run(_ *cobra.Command, _ []string) {
+	ensureConfigFile()
+
 	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer done()
 
-	config, err := cfg.ReadFile(rootFlags.configFile)
+	config, err := readConfiguration(rootFlags.configFile, rootFlags.lenient)
 	if err != nil {
-		log.Printf("Cannot read configuration file: %v", err)
-		config = cfg.Configuration{}
-	} else {
-		log.Printf("Using configuration from %s", rootFlags.configFile)
-	}
-
-	var portNumber int
-	var portName string
-	if rootFlags.portName != "" {
-		portNumber = -1
-		portName = rootFlags.portName
-	} else if rootFlags.portNumber >= 0 {
-		portNumber = rootFlags.portNumber
-		portName = ""
-	} else if config.PortName != "" {
-		portNumber = -1
-		portName = config.PortName
-	} else {
-		portNumber = config.PortNumber
-		portName = ""
+		log.Fatal(err)
 	}
 
 	var tciHost *net.TCPAddr
@@ -94,127 +88,138 @@ func run(_ *cobra.Command, _ []string) {
 		log.Fatalf("Invalid TCI host address: %v", err)
 	}
 
+	// setup the CW macro variables
+	for name, value := range config.CWVariables {
+		err := ctrl.MacroVariables.Set(name, value)
+		if err != nil {
+			log.Printf("Cannot set CW macro variable %s: %v", name, err)
+		}
+	}
+	serialFile := config.CWSerialFile
+	if serialFile == "" {
+		serialFile = defaultSerialFile()
+	}
+	if serialFile != "" {
+		err = ctrl.MacroVariables.LoadSerial(serialFile)
+		if err != nil {
+			log.Printf("Cannot load the serial number: %v", err)
+		}
+	}
+
 	drv, err := driver.New()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer drv.Close()
 
-	// setup the outgoing MIDI communication
-	djControlOut, err := midi.OpenOut(drv, portNumber, portName)
-	if err != nil {
-		log.Fatal(err)
+	// setup the outgoing MIDI communication of all devices
+	deviceConfigs := config.DeviceConfigs()
+	if rootFlags.portName != "" {
+		deviceConfigs[0].PortNumber = -1
+		deviceConfigs[0].PortName = rootFlags.portName
+	} else if rootFlags.portNumber >= 0 {
+		deviceConfigs[0].PortNumber = rootFlags.portNumber
+		deviceConfigs[0].PortName = ""
 	}
-	log.Printf("Opened %s successfully for writing", djControlOut)
-	wr := writer.New(djControlOut)
+	var midiDevices devices
+	deviceNames := make(map[string]bool)
+	for _, deviceConfig := range deviceConfigs {
+		if len(deviceConfigs) > 1 && (deviceConfig.Name == "" || deviceNames[deviceConfig.Name]) {
+			log.Fatalf("Each MIDI device needs a unique name: %q", deviceConfig.Name)
+		}
+		deviceNames[deviceConfig.Name] = true
 
-	if len(config.InitSequence) > 0 {
-		log.Print("MIDI init sequence")
-		err = SendRawMidiSequence(wr, config.InitSequence)
+		device, err := openDevice(drv, deviceConfig)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("Cannot create MIDI device %s: %v", deviceConfig.Name, err)
 		}
+		midiDevices = append(midiDevices, device)
 	}
+	defer midiDevices.Close()
 
-	// use the configured LED controller
-	var ledController LEDController
-	switch strings.ToLower(config.Indicators) {
-	case "pl-1":
-		ledController = NewPL1LED(wr)
-	default:
-		ledController = NewSimpleLED(wr)
+	controlAddress := config.ControlAddress
+	if rootFlags.controlAddress != "" {
+		controlAddress = rootFlags.controlAddress
+	}
+	if controlAddress != "" {
+		err = serveControl(ctx, controlAddress, midiDevices)
+		if err != nil {
+			log.Printf("Cannot open the control interface: %v", err)
+		}
 	}
-	defer ledController.Close()
 
 	// open the TCI connection
 	tciClient := client.KeepOpen(tciHost, 10*time.Second, rootFlags.traceTci)
-	tciClient.Notify(&connectionListener{
-		midiWriter:         wr,
-		connectSequence:    config.ConnectSequence,
-		disconnectSequence: config.DisconnectSequence,
-	})
+	for _, device := range midiDevices {
+		tciClient.Notify(device)
+	}
+	tciClient.Notify(tciEvents)
 
-	// setup the configured controls
-	for _, mapping := range config.Mappings {
-		newController, ok := ctrl.Factories[mapping.Type]
+	// setup the configured displays, MCU devices show the VFO frequency on their timecode display
+	displayConfigs := config.Displays
+	for _, device := range midiDevices {
+		if device.profile.Protocol == led.MCUProtocol {
+			displayConfigs = withMCUTimecode(displayConfigs, device.name)
+		}
+	}
+	for _, displayConfig := range displayConfigs {
+		device, ok := midiDevices.Get(displayConfig.Device)
 		if !ok {
-			log.Printf("Cannot find factory for %s", mapping.Type)
+			log.Printf("Cannot create display %s: unknown device %s", displayConfig.Name, displayConfig.Device)
 			continue
 		}
-
-		controller, controlType, err := newController(mapping, ledController, tciClient)
+		d, err := display.New(displayConfig, device.leds)
 		if err != nil {
-			log.Printf("Cannot create %s: %v", mapping.Type, err)
+			log.Printf("Cannot create display %s: %v", displayConfig.Name, err)
 			continue
 		}
+		defer d.Close()
+		tciClient.Notify(d)
+	}
 
-		switch controlType {
-		case ctrl.ButtonControl:
-			button := controller.(Button)
-			buttons[mapping.MidiKey()] = button
-		case ctrl.PotiControl:
-			poti := controller.(ValueControl)
-			defer poti.Close()
-			potis[mapping.MidiKey()] = poti
-		case ctrl.EncoderControl:
-			encoder := controller.(ValueControl)
-			defer encoder.Close()
-			encoders[mapping.MidiKey()] = encoder
+	// setup the configured controls
+	applyMappings(config.Mappings, midiDevices, tciClient)
+	defer func() {
+		currentControls().Close()
+	}()
+
+	// setup the incoming MIDI communication of all devices and reconnect them when they are plugged in again
+	for _, device := range midiDevices {
+		device.listen()
+		go device.supervise(ctx, deviceCheckInterval)
+	}
+
+	// reload the mappings when the configuration file changes
+	if rootFlags.configFile != "" {
+		reloader := &reloader{
+			filename:  rootFlags.configFile,
+			config:    config,
+			devices:   midiDevices,
+			tciClient: tciClient,
 		}
-		tciClient.Notify(controller)
+		go reloader.watch(ctx)
 	}
 
-	// setup the incoming MIDI communication
-	djControlIn, err := midi.OpenIn(drv, portNumber, portName)
-	if err != nil {
-		log.Fatal(err)
+	<-ctx.Done()
+}
+
+// withMCUTimecode adds a display that shows the frequency of VFO A on the timecode display of the given MCU device,
+// if the timecode display is not used by any other display.
+func withMCUTimecode(configs []display.Config, device string) []display.Config {
+	for _, config := range configs {
+		if config.Encoder == "mcu_timecode" && config.Device == device {
+			return configs
+		}
 	}
-	log.Printf("Opened %s successfully for reading", djControlIn)
-	rd := reader.New(
-		reader.NoLogger(),
-		reader.NoteOn(func(_ *reader.Position, channel, key, velocity uint8) {
-			button, ok := buttons[ctrl.MidiKey{Channel: channel, Key: int8(key)}]
-			if ok {
-				button.Pressed()
-			}
-		}),
-		reader.ControlChange(func(_ *reader.Position, channel, controller, value uint8) {
-			midiKey := ctrl.MidiKey{Channel: channel, Key: int8(controller)}
-			encoder, ok := encoders[midiKey]
-			if ok {
-				delta := int(value) - int(0x40)
-				encoder.Changed(delta)
-			}
-			poti, ok := potis[midiKey]
-			if ok {
-				poti.Changed(int(value))
-			}
-		}),
-		reader.Pitchbend(func(_ *reader.Position, channel uint8, value int16) {
-			scaledValue := uint8((value + 0x2000) >> 7)
-			midiKey := ctrl.MidiKey{Channel: channel, Key: -1}
-			encoder, ok := encoders[midiKey]
-			if ok {
-				delta := int(scaledValue) - int(0x40)
-				encoder.Changed(delta)
-			}
-			poti, ok := potis[midiKey]
-			if ok {
-				poti.Changed(int(scaledValue))
-			}
-		}),
-		reader.Each(func(_ *reader.Position, msg midi.Message) {
-			if rootFlags.trace {
-				log.Printf("rx: %#v", msg)
-			}
-		}),
-	)
-	err = rd.ListenTo(djControlIn)
+	return append(configs, display.Config{Name: "timecode", Device: device, Encoder: "mcu_timecode", Template: "{vfo_a}"})
+}
+
+func defaultSerialFile() string {
+	dir, err := os.UserConfigDir()
 	if err != nil {
-		log.Fatal(err)
+		return ""
 	}
-
-	<-ctx.Done()
+	return filepath.Join(dir, "midi2tci", "cw_serial")
 }
 
 func parseTCIAddr(arg string) (*net.TCPAddr, error) {
@@ -259,29 +264,33 @@ func validOptionalPort(port string) bool {
 	return true
 }
 
-type connectionListener struct {
-	midiWriter         writer.ChannelWriter
-	connectSequence    [][]byte
-	disconnectSequence [][]byte
-}
-
-func (l *connectionListener) Connected(connected bool) {
-	if connected {
-		SendRawMidiSequence(l.midiWriter, l.connectSequence)
-	} else {
-		SendRawMidiSequence(l.midiWriter, l.disconnectSequence)
-	}
-}
-
-func SendRawMidiSequence(w writer.ChannelWriter, sequence [][]byte) error {
-	messages := make([]midi.Message, len(sequence))
-	for i, raw := range sequence {
-		messages[i] = NewRawMessage(raw)
+// SendRawMidiSequence sends the messages of the given sequence and waits for the delays in between.
+// Invalid steps are skipped.
+func SendRawMidiSequence(w writer.ChannelWriter, steps sequence.Sequence) error {
+	for _, step := range steps {
+		if !step.Valid() {
+			log.Printf("Skipping invalid MIDI sequence step %s: %v", step, step.Problem())
+			continue
+		}
+		if step.Message == nil {
+			if step.Delay > 0 {
+				if rootFlags.trace {
+					log.Printf("MIDI sequence: %s", step)
+				}
+				time.Sleep(step.Delay)
+			}
+			continue
+		}
+		message := NewRawMessage(step.Message)
 		if rootFlags.trace {
-			log.Printf("%s", messages[i])
+			log.Printf("MIDI sequence: %s (% X)", step, step.Message)
+		}
+		err := writer.WriteMessages(w, []midi.Message{message})
+		if err != nil {
+			return err
 		}
 	}
-	return writer.WriteMessages(w, messages)
+	return nil
 }
 
 func NewRawMessage(raw []byte) midi.Message {
@@ -297,143 +306,3 @@ func (m rawMessage) String() string {
 func (m rawMessage) Raw() []byte {
 	return []byte(m)
 }
-
-type LEDController interface {
-	ctrl.LED
-	Close()
-}
-
-func NewSimpleLED(w writer.ChannelWriter) *SimpleLED {
-	result := &SimpleLED{
-		w:        w,
-		commands: make(chan func(writer.ChannelWriter)),
-		closed:   make(chan struct{}),
-	}
-
-	go func() {
-		defer close(result.closed)
-		for command := range result.commands {
-			command(result.w)
-		}
-	}()
-
-	return result
-}
-
-type SimpleLED struct {
-	w        writer.ChannelWriter
-	commands chan func(writer.ChannelWriter)
-	closed   chan struct{}
-}
-
-func (c *SimpleLED) Close() {
-	select {
-	case <-c.closed:
-		return
-	default:
-		close(c.commands)
-		<-c.closed
-	}
-}
-
-func (c *SimpleLED) SetOn(key ctrl.MidiKey, on bool) {
-	c.commands <- func(w writer.ChannelWriter) {
-		w.SetChannel(key.Channel)
-		if on {
-			writer.NoteOn(w, uint8(key.Key), 0x7f)
-		} else {
-			writer.NoteOff(w, uint8(key.Key))
-		}
-	}
-}
-
-func (c *SimpleLED) SetFlashing(key ctrl.MidiKey, on bool) {
-	c.SetOn(key, on)
-}
-
-func (c *SimpleLED) SetValue(key ctrl.MidiKey, value uint8) {
-}
-
-func NewPL1LED(w writer.ChannelWriter) *PL1LED {
-	result := &PL1LED{
-		w:        w,
-		commands: make(chan func(writer.ChannelWriter)),
-		closed:   make(chan struct{}),
-	}
-
-	go func() {
-		defer close(result.closed)
-		for command := range result.commands {
-			command(result.w)
-		}
-	}()
-
-	return result
-}
-
-type PL1LED struct {
-	w        writer.ChannelWriter
-	commands chan func(writer.ChannelWriter)
-	closed   chan struct{}
-}
-
-func (c *PL1LED) Close() {
-	select {
-	case <-c.closed:
-		return
-	default:
-		close(c.commands)
-		<-c.closed
-	}
-}
-
-func (c *PL1LED) SetOn(key ctrl.MidiKey, on bool) {
-	c.commands <- func(w writer.ChannelWriter) {
-		w.SetChannel(key.Channel)
-		if on {
-			writer.NoteOn(w, uint8(key.Key), 0x01)
-		} else {
-			writer.NoteOff(w, uint8(key.Key))
-		}
-	}
-}
-
-func (c *PL1LED) SetFlashing(key ctrl.MidiKey, on bool) {
-	c.commands <- func(w writer.ChannelWriter) {
-		w.SetChannel(key.Channel)
-		if on {
-			writer.NoteOn(w, uint8(key.Key), 0x02)
-		} else {
-			writer.NoteOff(w, uint8(key.Key))
-		}
-	}
-}
-
-func (c *PL1LED) SetValue(key ctrl.MidiKey, value uint8) {
-	c.commands <- func(w writer.ChannelWriter) {
-		w.SetChannel(key.Channel)
-		cv := value >> 3
-		if cv <= 0 {
-			cv = 1
-		}
-		if cv > 0x0F {
-			cv = 0x0F
-		}
-		writer.ControlChange(w, uint8(key.Key), cv)
-	}
-}
-
-type Button interface {
-	Pressed()
-}
-
-type ValueControl interface {
-	Changed(int)
-	Close()
-}
-
-var (
-	buttons  map[ctrl.MidiKey]Button       = make(map[ctrl.MidiKey]Button)
-	potis    map[ctrl.MidiKey]ValueControl = make(map[ctrl.MidiKey]ValueControl)
-	encoders map[ctrl.MidiKey]ValueControl = make(map[ctrl.MidiKey]ValueControl)
-)
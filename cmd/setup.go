@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	driver "gitlab.com/gomidi/rtmididrv"
+
+	"github.com/ftl/midi2tci/pkg/cfg"
+	"github.com/ftl/midi2tci/pkg/hardware"
+)
+
+// configEnvironmentVariable selects the configuration file, like --config.
+const configEnvironmentVariable = cfg.EnvironmentPrefix + "CONFIG"
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Create a starter configuration file step by step",
+	Long: `Create a starter configuration file step by step.
+
+The setup asks for the MIDI port of your device, the address of the TCI server and the profile of your device,
+and writes a configuration file without mappings. Use learn to add the mappings afterwards.
+Without --config, the configuration file is written into your configuration directory.`,
+	Run: runSetup,
+}
+
+func init() {
+	rootCmd.AddCommand(setupCmd)
+}
+
+func runSetup(cmd *cobra.Command, _ []string) {
+	filename := rootFlags.configFile
+	if !cmd.Flags().Changed("config") {
+		filename = ""
+	}
+	_, err := runWizard(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// resolveConfigFile finds the configuration file, if it is not given with --config or MIDI2TCI_CONFIG. If no
+// configuration file is found, rootFlags.configFile is empty.
+func resolveConfigFile(cmd *cobra.Command) {
+	if cmd.Flags().Changed("config") {
+		return
+	}
+	if filename, ok := os.LookupEnv(configEnvironmentVariable); ok && filename != "" {
+		rootFlags.configFile = filename
+		return
+	}
+	filename, _ := cfg.Find(cfg.SearchPath())
+	rootFlags.configFile = filename
+}
+
+// requireConfigFile returns the configuration file or exits if no configuration file was found.
+func requireConfigFile() string {
+	if rootFlags.configFile == "" {
+		log.Fatal(noConfigFileMessage())
+	}
+	return rootFlags.configFile
+}
+
+func noConfigFileMessage() string {
+	return fmt.Sprintf("No configuration file found in %s, use --config to select a configuration file or setup to create one", strings.Join(cfg.SearchPath(), ", "))
+}
+
+// ensureConfigFile offers to create a configuration file with the wizard, if the configuration file does not exist
+// and midi2tci runs in a terminal.
+func ensureConfigFile() {
+	if rootFlags.configFile != "" {
+		if _, err := os.Stat(rootFlags.configFile); err == nil {
+			return
+		}
+	}
+	if rootFlags.lenient {
+		return
+	}
+	if !isTerminal(os.Stdin) {
+		if rootFlags.configFile == "" {
+			log.Fatal(noConfigFileMessage())
+		}
+		return
+	}
+
+	if rootFlags.configFile == "" {
+		fmt.Println("No configuration file found.")
+	} else {
+		fmt.Printf("The configuration file %s does not exist.\n", rootFlags.configFile)
+	}
+	w := newWizard()
+	answer, ok := w.ask("Do you want to create a configuration file now? [Y/n]", "y")
+	if !ok || !strings.HasPrefix(strings.ToLower(answer), "y") {
+		if rootFlags.configFile == "" {
+			log.Fatal(noConfigFileMessage())
+		}
+		return
+	}
+	filename, err := w.run(rootFlags.configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rootFlags.configFile = filename
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runWizard asks for the basic settings and writes a starter configuration into the given file. Without a filename,
+// the configuration file is written into the configuration directory of the user. It returns the name of the file.
+func runWizard(filename string) (string, error) {
+	return newWizard().run(filename)
+}
+
+type wizard struct {
+	lines *bufio.Scanner
+}
+
+func newWizard() *wizard {
+	return &wizard{lines: bufio.NewScanner(os.Stdin)}
+}
+
+// ask prints the question and returns the answer, or the default answer if the answer is empty.
+func (w *wizard) ask(question string, defaultAnswer string) (string, bool) {
+	fmt.Printf("%s: ", question)
+	if !w.lines.Scan() {
+		fmt.Println()
+		return "", false
+	}
+	answer := strings.TrimSpace(w.lines.Text())
+	if answer == "" {
+		return defaultAnswer, true
+	}
+	return answer, true
+}
+
+func (w *wizard) run(filename string) (string, error) {
+	if filename == "" {
+		dir := cfg.UserDir()
+		if dir == "" {
+			dir = "."
+		}
+		filename = filepath.Join(dir, cfg.Filenames[0])
+	}
+	filename, ok := w.ask(fmt.Sprintf("Configuration file (empty for %s)", filename), filename)
+	if !ok {
+		return "", fmt.Errorf("setup canceled")
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return "", fmt.Errorf("the file %s already exists, use --config to choose another file", filename)
+	}
+
+	var config cfg.Configuration
+	steps := []func(*cfg.Configuration) bool{w.askPort, w.askTCIAddress, w.askProfile}
+	for _, step := range steps {
+		if !step(&config) {
+			return "", fmt.Errorf("setup canceled")
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return "", err
+	}
+	err = cfg.WriteFile(filename, config)
+	if err != nil {
+		return "", fmt.Errorf("cannot write the configuration file: %w", err)
+	}
+	fmt.Printf("The configuration was written to %s. Use learn to add mappings:\n", filename)
+	fmt.Printf("  midi2tci learn --config=%s --output=<file>\n", filename)
+	return filename, nil
+}
+
+func (w *wizard) askPort(config *cfg.Configuration) bool {
+	drv, err := driver.New()
+	if err != nil {
+		log.Printf("Cannot list the MIDI devices: %v", err)
+		return true
+	}
+	defer drv.Close()
+	inputs, err := drv.Ins()
+	if err != nil || len(inputs) == 0 {
+		fmt.Println("No MIDI input device found, you can set port_name in the configuration file later.")
+		return true
+	}
+
+	fmt.Println("Available MIDI input devices:")
+	for _, port := range inputs {
+		fmt.Printf("%2d: %s\n", port.Number(), port.String())
+	}
+	for {
+		answer, ok := w.ask("Number of your MIDI device (empty to skip)", "")
+		if !ok {
+			return false
+		}
+		if answer == "" {
+			return true
+		}
+		number, err := strconv.Atoi(answer)
+		if err == nil {
+			for _, port := range inputs {
+				if port.Number() == number {
+					config.PortName = port.String()
+					return true
+				}
+			}
+		}
+		fmt.Printf("%s is not a MIDI input device\n", answer)
+	}
+}
+
+func (w *wizard) askTCIAddress(config *cfg.Configuration) bool {
+	const defaultAddress = "localhost:40001"
+	for {
+		answer, ok := w.ask(fmt.Sprintf("Address of the TCI server (empty for %s)", defaultAddress), defaultAddress)
+		if !ok {
+			return false
+		}
+		host, err := parseTCIAddr(answer)
+		if err != nil {
+			fmt.Printf("Invalid TCI address: %v\n", err)
+			continue
+		}
+		config.TCIAddress = answer
+
+		conn, err := net.DialTimeout("tcp", host.String(), 2*time.Second)
+		if err == nil {
+			conn.Close()
+			fmt.Printf("The TCI server at %s is reachable.\n", host)
+			return true
+		}
+		fmt.Printf("The TCI server at %s is not reachable: %v\n", host, err)
+		answer, ok = w.ask("Use this address anyway? [y/N]", "n")
+		if !ok {
+			return false
+		}
+		if strings.HasPrefix(strings.ToLower(answer), "y") {
+			return true
+		}
+	}
+}
+
+func (w *wizard) askProfile(config *cfg.Configuration) bool {
+	fmt.Println("Device profiles:")
+	for _, name := range hardware.Names() {
+		profile, _ := hardware.Get(name)
+		fmt.Printf("  %-12s %s\n", profile.Name, profile.Description)
+	}
+	for {
+		answer, ok := w.ask("Profile of your device (empty for none)", "")
+		if !ok {
+			return false
+		}
+		if answer == "" {
+			return true
+		}
+		profile, err := hardware.Get(answer)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		config.Profile = profile.Name
+		return true
+	}
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ftl/tci/client"
+)
+
+// tciEvents is registered once with the TCI client and forwards the TCI notifications to the controllers of the
+// active controls. Controllers cannot be removed from the TCI client, this way a retired controller does not
+// receive any notifications after a reload. The latest state of the TRX is kept, so that the controllers that are
+// added by a reload start with the current state.
+var tciEvents = newTCIDispatcher()
+
+type tciDispatcher struct {
+	mutex  sync.Mutex
+	keys   []string
+	latest map[string]func(listener any)
+}
+
+func newTCIDispatcher() *tciDispatcher {
+	return &tciDispatcher{latest: make(map[string]func(listener any))}
+}
+
+// notify forwards the event to the active controls and keeps it as the latest state of the given key.
+// Events without a key, like the sensor values, are only forwarded.
+func (d *tciDispatcher) notify(key string, event func(listener any)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if key != "" {
+		if _, ok := d.latest[key]; !ok {
+			d.keys = append(d.keys, key)
+		}
+		d.latest[key] = event
+	}
+	for _, control := range currentControls().mappings {
+		event(control.controller)
+	}
+}
+
+// activate makes the given controls the active controls. The added controllers get the latest state first,
+// e.g. the buttons switch on their LEDs and the meters enable the sensors if the TCI connection is already open.
+func (d *tciDispatcher) activate(next *controls, added []*mappedControl) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, key := range d.keys {
+		for _, control := range added {
+			d.latest[key](control.controller)
+		}
+	}
+	activeControls.Store(next)
+}
+
+func (d *tciDispatcher) Connected(connected bool) {
+	d.notify("connected", func(listener any) {
+		if l, ok := listener.(interface{ Connected(bool) }); ok {
+			l.Connected(connected)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetCWMacrosSpeed(wpm int) {
+	d.notify("cw_macros_speed", func(listener any) {
+		if l, ok := listener.(interface{ SetCWMacrosSpeed(int) }); ok {
+			l.SetCWMacrosSpeed(wpm)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetMode(trx int, mode client.Mode) {
+	d.notify(fmt.Sprintf("mode:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetMode(int, client.Mode) }); ok {
+			l.SetMode(trx, mode)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetMute(muted bool) {
+	d.notify("mute", func(listener any) {
+		if l, ok := listener.(interface{ SetMute(bool) }); ok {
+			l.SetMute(muted)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetVolume(volume int) {
+	d.notify("volume", func(listener any) {
+		if l, ok := listener.(interface{ SetVolume(int) }); ok {
+			l.SetVolume(volume)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetRITEnable(trx int, enabled bool) {
+	d.notify(fmt.Sprintf("rit_enable:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetRITEnable(int, bool) }); ok {
+			l.SetRITEnable(trx, enabled)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetRITOffset(trx int, offset int) {
+	d.notify(fmt.Sprintf("rit_offset:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetRITOffset(int, int) }); ok {
+			l.SetRITOffset(trx, offset)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetXITEnable(trx int, enabled bool) {
+	d.notify(fmt.Sprintf("xit_enable:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetXITEnable(int, bool) }); ok {
+			l.SetXITEnable(trx, enabled)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetXITOffset(trx int, offset int) {
+	d.notify(fmt.Sprintf("xit_offset:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetXITOffset(int, int) }); ok {
+			l.SetXITOffset(trx, offset)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetRXBalance(trx int, vfo client.VFO, balance int) {
+	d.notify(fmt.Sprintf("rx_balance:%d:%d", trx, vfo), func(listener any) {
+		if l, ok := listener.(interface {
+			SetRXBalance(int, client.VFO, int)
+		}); ok {
+			l.SetRXBalance(trx, vfo, balance)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetRXVolume(trx int, vfo client.VFO, volume int) {
+	d.notify(fmt.Sprintf("rx_volume:%d:%d", trx, vfo), func(listener any) {
+		if l, ok := listener.(interface {
+			SetRXVolume(int, client.VFO, int)
+		}); ok {
+			l.SetRXVolume(trx, vfo, volume)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetRXChannelEnable(trx int, vfo client.VFO, enabled bool) {
+	d.notify(fmt.Sprintf("rx_channel_enable:%d:%d", trx, vfo), func(listener any) {
+		if l, ok := listener.(interface {
+			SetRXChannelEnable(int, client.VFO, bool)
+		}); ok {
+			l.SetRXChannelEnable(trx, vfo, enabled)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetRXFilterBand(trx int, min, max int) {
+	d.notify(fmt.Sprintf("rx_filter_band:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetRXFilterBand(int, int, int) }); ok {
+			l.SetRXFilterBand(trx, min, max)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetRXSensors(trx int, dBm float64) {
+	d.notify("", func(listener any) {
+		if l, ok := listener.(interface{ SetRXSensors(int, float64) }); ok {
+			l.SetRXSensors(trx, dBm)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetTXSensors(trx int, micDB float64, rmsPower float64, peakPower float64, swr float64) {
+	d.notify("", func(listener any) {
+		if l, ok := listener.(interface {
+			SetTXSensors(int, float64, float64, float64, float64)
+		}); ok {
+			l.SetTXSensors(trx, micDB, rmsPower, peakPower, swr)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetSplitEnable(trx int, enabled bool) {
+	d.notify(fmt.Sprintf("split_enable:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetSplitEnable(int, bool) }); ok {
+			l.SetSplitEnable(trx, enabled)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetTX(trx int, ptt bool) {
+	d.notify(fmt.Sprintf("tx:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetTX(int, bool) }); ok {
+			l.SetTX(trx, ptt)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetTune(trx int, tune bool) {
+	d.notify(fmt.Sprintf("tune:%d", trx), func(listener any) {
+		if l, ok := listener.(interface{ SetTune(int, bool) }); ok {
+			l.SetTune(trx, tune)
+		}
+	})
+}
+
+func (d *tciDispatcher) SetVFOFrequency(trx int, vfo client.VFO, frequency int) {
+	d.notify(fmt.Sprintf("vfo:%d:%d", trx, vfo), func(listener any) {
+		if l, ok := listener.(interface {
+			SetVFOFrequency(int, client.VFO, int)
+		}); ok {
+			l.SetVFOFrequency(trx, vfo, frequency)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/midi2tci/pkg/cfg"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file",
+	Run:   runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(_ *cobra.Command, _ []string) {
+	overrides, err := configOverrides()
+	if err != nil {
+		log.Fatal(err)
+	}
+	filename := requireConfigFile()
+	_, problems, err := cfg.ValidateFile(filename, overrides...)
+	if err != nil {
+		log.Fatalf("Cannot read configuration file: %v", err)
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", filename)
+}
+
+// readConfiguration reads and validates the given configuration file. In lenient mode, the problems are only logged
+// and a missing configuration file results in an empty configuration.
+func readConfiguration(filename string, lenient bool) (cfg.Configuration, error) {
+	if filename == "" && lenient {
+		log.Print(noConfigFileMessage())
+		return cfg.Configuration{}, nil
+	}
+	overrides, err := configOverrides()
+	if err != nil {
+		return cfg.Configuration{}, err
+	}
+	config, problems, err := cfg.ValidateFile(filename, overrides...)
+	if err != nil && !lenient {
+		return cfg.Configuration{}, fmt.Errorf("cannot read configuration file: %w", err)
+	}
+	if err != nil {
+		log.Printf("Cannot read configuration file: %v", err)
+		return cfg.Configuration{}, nil
+	}
+
+	for _, problem := range problems {
+		log.Print(problem)
+	}
+	if len(problems) > 0 && !lenient {
+		return cfg.Configuration{}, fmt.Errorf("the configuration file %s is invalid, use validate to check it or --lenient to ignore the problems", filename)
+	}
+
+	log.Printf("Using configuration from %s", filename)
+	return config, nil
+}
+
+// configOverrides returns the overrides of the configuration from the environment variables and from --set.
+// The values given with --set take precedence.
+func configOverrides() ([]cfg.Override, error) {
+	result := cfg.EnvironmentOverrides(os.Environ(), configEnvironmentVariable)
+	for _, value := range rootFlags.overrides {
+		override, err := cfg.ParseOverride(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, override)
+	}
+	return result, nil
+}
@@ -2,31 +2,186 @@ package cfg
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
-	"os"
 
 	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/display"
+	"github.com/ftl/midi2tci/pkg/hardware"
+	"github.com/ftl/midi2tci/pkg/led"
+	"github.com/ftl/midi2tci/pkg/sequence"
 )
 
 type Configuration struct {
-	PortNumber         int            `json:"port_number,omitempty"`
-	PortName           string         `json:"port_name,omitempty"`
-	TCIAddress         string         `json:"tci_address,omitempty"`
-	Indicators         string         `json:"indicators,omitempty"`
-	InitSequence       [][]byte       `json:"init_sequence,omitempty"`
-	ConnectSequence    [][]byte       `json:"connect_sequence,omitempty"`
-	DisconnectSequence [][]byte       `json:"disconnect_sequence,omitempty"`
-	Mappings           []ctrl.Mapping `json:"mappings"`
-}
-
-func ReadFile(filename string) (Configuration, error) {
-	f, err := os.Open(filename)
+	PortNumber         int                  `json:"port_number,omitempty"`
+	PortName           string               `json:"port_name,omitempty"`
+	TCIAddress         string               `json:"tci_address,omitempty"`
+	Profile            string               `json:"profile,omitempty"`
+	Indicators         string               `json:"indicators,omitempty"`
+	LEDProfile         *led.Profile         `json:"led_profile,omitempty"`
+	InitSequence       sequence.Sequence    `json:"init_sequence,omitempty"`
+	ConnectSequence    sequence.Sequence    `json:"connect_sequence,omitempty"`
+	DisconnectSequence sequence.Sequence    `json:"disconnect_sequence,omitempty"`
+	EncoderEncoding    ctrl.EncoderEncoding `json:"encoder_encoding,omitempty"`
+	Devices            []Device             `json:"devices,omitempty"`
+	ControlAddress     string               `json:"control_address,omitempty"`
+	CWVariables        map[string]string    `json:"cw_variables,omitempty"`
+	CWSerialFile       string               `json:"cw_serial_file,omitempty"`
+	Displays           []display.Config     `json:"displays,omitempty"`
+	Mappings           []ctrl.Mapping       `json:"mappings"`
+
+	// Files contains the names of the files the configuration was read from: the configuration file and all
+	// included files.
+	Files []string `json:"-"`
+}
+
+// Device describes a MIDI device: the port, the LED profile and the MIDI sequences that are sent to the device.
+type Device struct {
+	Name               string               `json:"name"`
+	PortNumber         int                  `json:"port_number,omitempty"`
+	PortName           string               `json:"port_name,omitempty"`
+	Profile            string               `json:"profile,omitempty"`
+	Indicators         string               `json:"indicators,omitempty"`
+	LEDProfile         *led.Profile         `json:"led_profile,omitempty"`
+	InitSequence       sequence.Sequence    `json:"init_sequence,omitempty"`
+	ConnectSequence    sequence.Sequence    `json:"connect_sequence,omitempty"`
+	DisconnectSequence sequence.Sequence    `json:"disconnect_sequence,omitempty"`
+	EncoderEncoding    ctrl.EncoderEncoding `json:"encoder_encoding,omitempty"`
+}
+
+// DeviceConfigs returns the configured MIDI devices. Without any configured devices, the device is described
+// by the top level fields of the configuration. The LED profile and the MIDI sequences of a device that are not
+// configured are taken from its device profile.
+func (c Configuration) DeviceConfigs() []Device {
+	if len(c.Devices) > 0 {
+		result := make([]Device, len(c.Devices))
+		for i, device := range c.Devices {
+			result[i] = device.withProfileDefaults()
+		}
+		return result
+	}
+	device := Device{
+		PortNumber:         c.PortNumber,
+		PortName:           c.PortName,
+		Profile:            c.Profile,
+		Indicators:         c.Indicators,
+		LEDProfile:         c.LEDProfile,
+		InitSequence:       c.InitSequence,
+		ConnectSequence:    c.ConnectSequence,
+		DisconnectSequence: c.DisconnectSequence,
+		EncoderEncoding:    c.EncoderEncoding,
+	}
+	return []Device{device.withProfileDefaults()}
+}
+
+func (d Device) withProfileDefaults() Device {
+	if d.Profile == "" {
+		return d
+	}
+	profile, err := hardware.Get(d.Profile)
 	if err != nil {
-		return Configuration{}, err
+		return d
+	}
+	if d.Indicators == "" && d.LEDProfile == nil {
+		d.Indicators = profile.Indicators
+	}
+	if len(d.InitSequence) == 0 {
+		d.InitSequence = profile.InitSequence
+	}
+	if len(d.ConnectSequence) == 0 {
+		d.ConnectSequence = profile.ConnectSequence
+	}
+	if len(d.DisconnectSequence) == 0 {
+		d.DisconnectSequence = profile.DisconnectSequence
+	}
+	return d
+}
+
+// resolveControls sets the channel and the key of all mappings that reference a control of their device by name.
+func (c *Configuration) resolveControls() []finding {
+	profiles := make(map[string]string)
+	deviceConfigs := c.DeviceConfigs()
+	for _, device := range deviceConfigs {
+		profiles[device.Name] = device.Profile
 	}
-	defer f.Close()
 
-	return Read(f)
+	var result []finding
+	for i, mapping := range c.Mappings {
+		if mapping.Control == "" {
+			continue
+		}
+		path := fmt.Sprintf("/mappings/%d/control", i)
+		deviceName := mapping.Device
+		if deviceName == "" {
+			deviceName = deviceConfigs[0].Name
+		}
+		profileName, ok := profiles[deviceName]
+		if !ok {
+			// the unknown device is reported by validate
+			continue
+		}
+		if profileName == "" {
+			result = append(result, finding{path: path, message: "the device needs a profile to reference controls by name"})
+			continue
+		}
+		profile, err := hardware.Get(profileName)
+		if err != nil {
+			// the unknown profile is reported by validate
+			continue
+		}
+		resolved, err := profile.Resolve(mapping)
+		if err != nil {
+			result = append(result, finding{path: path, message: err.Error()})
+			continue
+		}
+		c.Mappings[i] = resolved
+	}
+	return result
+}
+
+// IndicatorProfile returns the LED profile of the MIDI device. A LED profile that is defined in the configuration
+// takes precedence over the built-in profile that is selected with "indicators". The encoder encoding of the
+// device overrides the encoder encoding of the profile.
+func (d Device) IndicatorProfile() (led.Profile, error) {
+	var result led.Profile
+	if d.LEDProfile == nil {
+		profile, err := led.BuiltinProfile(d.Indicators)
+		if err != nil {
+			return led.Profile{}, err
+		}
+		result = profile
+	} else {
+		result = *d.LEDProfile
+	}
+	if d.EncoderEncoding != "" {
+		result.EncoderEncoding = d.EncoderEncoding
+	}
+
+	err := result.Validate()
+	if err != nil {
+		return led.Profile{}, err
+	}
+	return result, nil
+}
+
+// ReadFile reads the given configuration file with all included files. The format of the file is chosen
+// by its extension: .yaml or .yml for YAML, .toml for TOML, and JSON for all other files. The given overrides
+// replace values of the configuration.
+func ReadFile(filename string, overrides ...Override) (Configuration, error) {
+	root, files, err := loadDocument(filename, overrides...)
+	if err != nil {
+		return Configuration{}, err
+	}
+	config, _, err := decode(root)
+	if err != nil {
+		return Configuration{}, err
+	}
+	config.Files = files
+	findings := config.resolveControls()
+	if len(findings) > 0 {
+		return Configuration{}, fmt.Errorf("%s: %s", filename, findings[0].message)
+	}
+	return config, nil
 }
 
 func Read(r io.Reader) (Configuration, error) {
@@ -0,0 +1,351 @@
+package cfg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+const (
+	// includeKey lists the files that are included into a configuration file.
+	includeKey = "include"
+	// variablesKey contains the variables that can be used in a configuration file.
+	variablesKey = "variables"
+	// mergeKey merges the fields of the given objects into an object, like the merge key of YAML.
+	mergeKey = "<<"
+)
+
+// position is the location of a value in a configuration file. Line and column start at 1, a line of 0
+// means that the exact location is unknown.
+type position struct {
+	file   string
+	line   int
+	column int
+}
+
+// documentError is a problem of a configuration document at the given position.
+type documentError struct {
+	pos position
+	err error
+}
+
+func errorAt(pos position, format string, args ...any) *documentError {
+	return &documentError{pos: pos, err: fmt.Errorf(format, args...)}
+}
+
+func (e *documentError) Error() string {
+	return e.problem().String()
+}
+
+func (e *documentError) Unwrap() error {
+	return e.err
+}
+
+func (e *documentError) problem() Problem {
+	return Problem{Filename: e.pos.file, Line: e.pos.line, Column: e.pos.column, Message: e.err.Error()}
+}
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	objectNode
+	arrayNode
+)
+
+// node is a value of a configuration document together with its position in the source file. All supported
+// file formats are converted into nodes, then the includes and variables are resolved on the nodes.
+type node struct {
+	kind   nodeKind
+	pos    position
+	value  any
+	keys   []string
+	fields map[string]*node
+	items  []*node
+}
+
+func newScalar(pos position, value any) *node {
+	return &node{kind: scalarNode, pos: pos, value: value}
+}
+
+func newObject(pos position) *node {
+	return &node{kind: objectNode, pos: pos, fields: make(map[string]*node)}
+}
+
+func newArray(pos position) *node {
+	return &node{kind: arrayNode, pos: pos}
+}
+
+func (n *node) get(key string) (*node, bool) {
+	if n.kind != objectNode {
+		return nil, false
+	}
+	result, ok := n.fields[key]
+	return result, ok
+}
+
+func (n *node) set(key string, value *node) {
+	if _, ok := n.fields[key]; !ok {
+		n.keys = append(n.keys, key)
+	}
+	n.fields[key] = value
+}
+
+func (n *node) remove(key string) {
+	if _, ok := n.fields[key]; !ok {
+		return
+	}
+	delete(n.fields, key)
+	for i, k := range n.keys {
+		if k == key {
+			n.keys = append(n.keys[:i:i], n.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (n *node) clone() *node {
+	result := *n
+	switch n.kind {
+	case objectNode:
+		result.keys = append([]string{}, n.keys...)
+		result.fields = make(map[string]*node, len(n.fields))
+		for key, field := range n.fields {
+			result.fields[key] = field.clone()
+		}
+	case arrayNode:
+		result.items = make([]*node, len(n.items))
+		for i, item := range n.items {
+			result.items[i] = item.clone()
+		}
+	}
+	return &result
+}
+
+// plain returns the value of the node without the positions, as it can be marshalled to JSON.
+func (n *node) plain() any {
+	switch n.kind {
+	case objectNode:
+		result := make(map[string]any, len(n.fields))
+		for key, field := range n.fields {
+			result[key] = field.plain()
+		}
+		return result
+	case arrayNode:
+		result := make([]any, len(n.items))
+		for i, item := range n.items {
+			result[i] = item.plain()
+		}
+		return result
+	default:
+		return n.value
+	}
+}
+
+// positions returns the positions of all values in the document. The paths are built like JSON pointers,
+// e.g. /mappings/3/options/step.
+func (n *node) positions() positions {
+	result := make(positions)
+	n.index("", result)
+	return result
+}
+
+func (n *node) index(path string, result positions) {
+	result[path] = n.pos
+	switch n.kind {
+	case objectNode:
+		for key, field := range n.fields {
+			field.index(path+"/"+key, result)
+		}
+	case arrayNode:
+		for i, item := range n.items {
+			item.index(path+"/"+strconv.Itoa(i), result)
+		}
+	}
+}
+
+// merge returns the composition of both nodes. Objects are merged field by field, arrays are concatenated,
+// all other values of the overlay replace the values of the base.
+func merge(base, overlay *node) *node {
+	switch {
+	case base.kind == objectNode && overlay.kind == objectNode:
+		result := base.clone()
+		for _, key := range overlay.keys {
+			field := overlay.fields[key]
+			if baseField, ok := result.fields[key]; ok {
+				field = merge(baseField, field)
+			}
+			result.set(key, field)
+		}
+		return result
+	case base.kind == arrayNode && overlay.kind == arrayNode:
+		result := base.clone()
+		result.items = append(result.items, overlay.items...)
+		return result
+	default:
+		return overlay
+	}
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// expandVariables replaces all references to the variables of the document, e.g. ${rx2}, with the values of
+// the variables, and resolves the merge keys. A value that consists only of a reference gets the type of the
+// variable, references within a text are replaced with the text of the variable.
+func (n *node) expandVariables() error {
+	variables, ok := n.get(variablesKey)
+	if !ok {
+		variables = newObject(n.pos)
+	} else if variables.kind != objectNode {
+		return errorAt(variables.pos, "the variables must be an object")
+	}
+	n.remove(variablesKey)
+
+	expanded, err := n.expand(variables)
+	if err != nil {
+		return err
+	}
+	*n = *expanded
+	return nil
+}
+
+func (n *node) expand(variables *node) (*node, error) {
+	switch n.kind {
+	case arrayNode:
+		for i, item := range n.items {
+			expanded, err := item.expand(variables)
+			if err != nil {
+				return nil, err
+			}
+			n.items[i] = expanded
+		}
+		return n, nil
+	case objectNode:
+		for _, key := range n.keys {
+			expanded, err := n.fields[key].expand(variables)
+			if err != nil {
+				return nil, err
+			}
+			n.fields[key] = expanded
+		}
+		return n, n.resolveMergeKey()
+	}
+
+	text, ok := n.value.(string)
+	if !ok {
+		return n, nil
+	}
+	references := variablePattern.FindAllStringSubmatchIndex(text, -1)
+	if len(references) == 0 {
+		return n, nil
+	}
+	if len(references) == 1 && references[0][0] == 0 && references[0][1] == len(text) {
+		variable, ok := variables.fields[text[references[0][2]:references[0][3]]]
+		if !ok {
+			return nil, errorAt(n.pos, "unknown variable %s", text)
+		}
+		result := variable.clone()
+		result.pos = n.pos
+		return result, nil
+	}
+
+	var err error
+	result := variablePattern.ReplaceAllStringFunc(text, func(reference string) string {
+		name := reference[2 : len(reference)-1]
+		variable, ok := variables.fields[name]
+		switch {
+		case !ok:
+			err = errorAt(n.pos, "unknown variable %s", reference)
+		case variable.kind != scalarNode:
+			err = errorAt(n.pos, "the variable %s cannot be used within a text", reference)
+		default:
+			return scalarText(variable.value)
+		}
+		return reference
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newScalar(n.pos, result), nil
+}
+
+// resolveMergeKey adds the fields of the objects given with the merge key to this object. Fields that
+// are already set in this object are kept, the first object takes precedence over the following objects.
+func (n *node) resolveMergeKey() error {
+	value, ok := n.get(mergeKey)
+	if !ok {
+		return nil
+	}
+	n.remove(mergeKey)
+
+	sources := []*node{value}
+	if value.kind == arrayNode {
+		sources = value.items
+	}
+	for _, source := range sources {
+		if source.kind != objectNode {
+			return errorAt(source.pos, "only objects can be merged")
+		}
+		for _, key := range source.keys {
+			if _, ok := n.fields[key]; !ok {
+				n.set(key, source.fields[key].clone())
+			}
+		}
+	}
+	return nil
+}
+
+// stringifyValues converts all scalar fields of the object into strings. The options and the CW variables are
+// strings in the configuration, but YAML and TOML allow to write them as numbers or booleans.
+func (n *node) stringifyValues() {
+	if n.kind != objectNode {
+		return
+	}
+	for _, field := range n.fields {
+		if field.kind == scalarNode && field.value != nil {
+			field.value = scalarText(field.value)
+		}
+	}
+}
+
+func scalarText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// stringMaps returns the objects of the document that are decoded into maps of strings.
+func (n *node) stringMaps() []*node {
+	var result []*node
+	if variables, ok := n.get("cw_variables"); ok {
+		result = append(result, variables)
+	}
+	for _, list := range []string{"mappings", "displays"} {
+		items, ok := n.get(list)
+		if !ok || items.kind != arrayNode {
+			continue
+		}
+		for _, item := range items.items {
+			if options, ok := item.get("options"); ok {
+				result = append(result, options)
+			}
+		}
+	}
+	return result
+}
+
+// sortedKeys returns the keys of the given map in alphabetical order.
+func sortedKeys(m map[string]any) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,267 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// parseDocument parses a configuration file. The format is chosen by the extension of the filename:
+// .yaml or .yml for YAML, .toml for TOML, and JSON for all other files.
+func parseDocument(filename string, data []byte) (*node, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return parseYAML(filename, data)
+	case ".toml":
+		return parseTOML(filename, data)
+	default:
+		return parseJSON(filename, data)
+	}
+}
+
+func parseJSON(filename string, data []byte) (*node, error) {
+	// the decoder of encoding/json reports the syntax errors with the best messages
+	var v any
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		var offset int64
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			// the offset points behind the invalid character
+			offset = syntaxError.Offset - 1
+		}
+		line, column := lineAndColumn(data, offset)
+		return nil, &documentError{pos: position{file: filename, line: line, column: column}, err: err}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return parseJSONValue(decoder, filename, data)
+}
+
+func parseJSONValue(decoder *json.Decoder, filename string, data []byte) (*node, error) {
+	line, column := lineAndColumn(data, skipSeparators(data, decoder.InputOffset()))
+	pos := position{file: filename, line: line, column: column}
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return newScalar(pos, token), nil
+	}
+
+	var result *node
+	switch delim {
+	case '{':
+		result = newObject(pos)
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			name, _ := key.(string)
+			field, err := parseJSONValue(decoder, filename, data)
+			if err != nil {
+				return nil, err
+			}
+			result.set(name, field)
+		}
+	case '[':
+		result = newArray(pos)
+		for decoder.More() {
+			item, err := parseJSONValue(decoder, filename, data)
+			if err != nil {
+				return nil, err
+			}
+			result.items = append(result.items, item)
+		}
+	}
+
+	// the closing delimiter
+	_, err = decoder.Token()
+	return result, err
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+)`)
+
+func parseYAML(filename string, data []byte) (*node, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		pos := position{file: filename, column: 1}
+		if match := yamlLine.FindStringSubmatch(err.Error()); match != nil {
+			pos.line, _ = strconv.Atoi(match[1])
+		}
+		return nil, &documentError{pos: pos, err: err}
+	}
+	if len(document.Content) == 0 {
+		return newObject(position{file: filename, line: 1, column: 1}), nil
+	}
+	return convertYAML(filename, document.Content[0])
+}
+
+func convertYAML(filename string, n *yaml.Node) (*node, error) {
+	pos := position{file: filename, line: n.Line, column: n.Column}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return convertYAML(filename, n.Content[0])
+	case yaml.AliasNode:
+		result, err := convertYAML(filename, n.Alias)
+		if err != nil {
+			return nil, err
+		}
+		result.pos = pos
+		return result, nil
+	case yaml.MappingNode:
+		result := newObject(pos)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			value, err := convertYAML(filename, n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			result.set(n.Content[i].Value, value)
+		}
+		return result, nil
+	case yaml.SequenceNode:
+		result := newArray(pos)
+		for _, content := range n.Content {
+			item, err := convertYAML(filename, content)
+			if err != nil {
+				return nil, err
+			}
+			result.items = append(result.items, item)
+		}
+		return result, nil
+	default:
+		var value any
+		err := n.Decode(&value)
+		if err != nil {
+			return nil, &documentError{pos: pos, err: err}
+		}
+		return newScalar(pos, value), nil
+	}
+}
+
+func parseTOML(filename string, data []byte) (*node, error) {
+	var document map[string]any
+	_, err := toml.Decode(string(data), &document)
+	if err != nil {
+		pos := position{file: filename, column: 1}
+		var parseError toml.ParseError
+		if errors.As(err, &parseError) {
+			pos.line = parseError.Position.Line
+			pos.column = parseError.Position.Col
+			err = errors.New(parseError.Message)
+		}
+		return nil, &documentError{pos: pos, err: err}
+	}
+
+	locator := newTOMLLocator(filename, data)
+	return convertTOML(document, "", locator), nil
+}
+
+func convertTOML(value any, path string, locator *tomlLocator) *node {
+	pos := locator.locate(path)
+	switch v := value.(type) {
+	case map[string]any:
+		result := newObject(pos)
+		for _, key := range sortedKeys(v) {
+			result.set(key, convertTOML(v[key], path+"/"+key, locator))
+		}
+		return result
+	case []map[string]any:
+		result := newArray(pos)
+		for i, item := range v {
+			result.items = append(result.items, convertTOML(item, path+"/"+strconv.Itoa(i), locator))
+		}
+		return result
+	case []any:
+		result := newArray(pos)
+		for i, item := range v {
+			result.items = append(result.items, convertTOML(item, path+"/"+strconv.Itoa(i), locator))
+		}
+		return result
+	case time.Time:
+		return newScalar(pos, v.Format(time.RFC3339))
+	case fmt.Stringer:
+		return newScalar(pos, v.String())
+	default:
+		return newScalar(pos, v)
+	}
+}
+
+// tomlLocator finds the lines of the tables and keys of a TOML document. The TOML decoder does not report the
+// positions of the values, therefore the lines are found by scanning the table headers and key assignments.
+// The lines of values in inline tables and arrays are not known, they get the line of their key.
+type tomlLocator struct {
+	filename string
+	lines    map[string]int
+}
+
+var (
+	tomlTableHeader      = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]`)
+	tomlArrayTableHeader = regexp.MustCompile(`^\s*\[\[\s*([^\[\]]+?)\s*\]\]`)
+	tomlKey              = regexp.MustCompile(`^\s*("[^"]*"|'[^']*'|[A-Za-z0-9_-]+)\s*=`)
+)
+
+func newTOMLLocator(filename string, data []byte) *tomlLocator {
+	result := &tomlLocator{
+		filename: filename,
+		lines:    map[string]int{"": 1},
+	}
+	tableCounts := make(map[string]int)
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNumber := i + 1
+		if match := tomlArrayTableHeader.FindStringSubmatch(line); match != nil {
+			name := tomlPath(match[1])
+			table = name + "/" + strconv.Itoa(tableCounts[name])
+			tableCounts[name]++
+			result.lines[table] = lineNumber
+			if _, ok := result.lines[name]; !ok {
+				result.lines[name] = lineNumber
+			}
+			continue
+		}
+		if match := tomlTableHeader.FindStringSubmatch(line); match != nil {
+			table = tomlPath(match[1])
+			result.lines[table] = lineNumber
+			continue
+		}
+		if match := tomlKey.FindStringSubmatch(line); match != nil {
+			result.lines[table+"/"+strings.Trim(match[1], `"'`)] = lineNumber
+		}
+	}
+	return result
+}
+
+func tomlPath(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+func (l *tomlLocator) locate(path string) position {
+	for {
+		if line, ok := l.lines[path]; ok {
+			return position{file: l.filename, line: line, column: 1}
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return position{file: l.filename}
+		}
+		path = path[:i]
+	}
+}
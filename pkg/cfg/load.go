@@ -0,0 +1,112 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// loadDocument reads the given configuration file with all included files, expands the variables and applies
+// the given overrides. It also returns the names of all files that were read, the given file first.
+func loadDocument(filename string, overrides ...Override) (*node, []string, error) {
+	var files []string
+	root, err := loadFile(filename, nil, &files)
+	if err != nil {
+		return nil, files, err
+	}
+	err = root.expandVariables()
+	if err != nil {
+		return nil, files, err
+	}
+	err = root.applyOverrides(overrides)
+	if err != nil {
+		return nil, files, err
+	}
+	root.stringifyOptions()
+	return root, files, nil
+}
+
+// loadFile reads the given file and merges it with its included files. The included files are merged in the given
+// order, the including file is merged last, so its values take precedence. The names of all files that are read
+// are added to loaded.
+func loadFile(filename string, including []string, loaded *[]string) (*node, error) {
+	for _, f := range including {
+		if f == filename {
+			return nil, fmt.Errorf("%s includes itself", filename)
+		}
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	*loaded = append(*loaded, filename)
+	root, err := parseDocument(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != objectNode {
+		return nil, errorAt(root.pos, "the configuration must be an object")
+	}
+
+	include, ok := root.get(includeKey)
+	if !ok {
+		return root, nil
+	}
+	root.remove(includeKey)
+	files := []*node{include}
+	if include.kind == arrayNode {
+		files = include.items
+	}
+
+	result := newObject(root.pos)
+	for _, file := range files {
+		name, ok := file.value.(string)
+		if file.kind != scalarNode || !ok {
+			return nil, errorAt(file.pos, "include needs the names of the included files")
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(filename), name)
+		}
+		included, err := loadFile(name, append(including, filename), loaded)
+		var documentErr *documentError
+		if err != nil && !errors.As(err, &documentErr) {
+			return nil, errorAt(file.pos, "cannot include %s: %v", name, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = merge(result, included)
+	}
+	return merge(result, root), nil
+}
+
+// prepare resolves the variables and converts the options into strings.
+func prepare(root *node) error {
+	err := root.expandVariables()
+	if err != nil {
+		return err
+	}
+	root.stringifyOptions()
+	return nil
+}
+
+// stringifyOptions converts the values of the options and the CW variables into strings.
+func (n *node) stringifyOptions() {
+	for _, m := range n.stringMaps() {
+		m.stringifyValues()
+	}
+}
+
+// decode converts the document into the configuration. It also returns the JSON representation of the document.
+func decode(root *node) (Configuration, []byte, error) {
+	data, err := json.Marshal(root.plain())
+	if err != nil {
+		return Configuration{}, nil, err
+	}
+	var result Configuration
+	err = json.Unmarshal(data, &result)
+	return result, data, err
+}
@@ -0,0 +1,203 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+	return dir
+}
+
+func TestReadFile_Formats(t *testing.T) {
+	expected := Configuration{
+		TCIAddress: "localhost:40001",
+		Mappings: []ctrl.Mapping{
+			{Type: ctrl.VFOMapping, Channel: 1, Key: 10, TRX: 1, VFO: "VFOB", Options: map[string]string{"step": "10"}},
+			{Type: ctrl.MOXMapping, Channel: 1, Key: 12},
+		},
+	}
+	dir := writeFiles(t, map[string]string{
+		"config.json": `{
+	"tci_address": "localhost:40001",
+	"mappings": [
+		{"type": "vfo", "channel": 1, "key": 10, "trx": 1, "vfo": "VFOB", "options": {"step": "10"}},
+		{"type": "mox", "channel": 1, "key": 12}
+	]
+}`,
+		"config.yaml": `
+tci_address: localhost:40001
+# the main VFO
+mappings:
+  - {type: vfo, channel: 1, key: 10, trx: 1, vfo: VFOB, options: {step: 10}}
+  - type: mox
+    channel: 1
+    key: 12
+`,
+		"config.toml": `
+tci_address = "localhost:40001"
+
+# the main VFO
+[[mappings]]
+type = "vfo"
+channel = 1
+key = 10
+trx = 1
+vfo = "VFOB"
+options = { step = 10 }
+
+[[mappings]]
+type = "mox"
+channel = 1
+key = 12
+`,
+	})
+
+	for _, name := range []string{"config.json", "config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(dir, name)
+			actual, err := ReadFile(filename)
+			require.NoError(t, err)
+			expected.Files = []string{filename}
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestReadFile_IncludeAndVariables(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"profile.yaml": `
+port_name: DJControl Starlight
+indicators: starlight
+variables:
+  step: 10
+mappings:
+  - {type: mox, channel: 1, key: 12}
+`,
+		"station.json": `{
+	"include": "profile.yaml",
+	"tci_address": "localhost:40001",
+	"variables": {"rx2": {"trx": 1, "vfo": "VFOB"}},
+	"mappings": [
+		{"<<": "${rx2}", "type": "vfo", "channel": 1, "key": 10, "options": {"step": "${step}", "text": "step ${step} Hz"}},
+		{"<<": "${rx2}", "type": "enable_rx", "channel": 1, "key": 11, "vfo": "VFOA"}
+	]
+}`,
+		"anchors.yaml": `
+include: [profile.yaml]
+variables:
+  rx2: &rx2 {trx: 1, vfo: VFOB}
+mappings:
+  - {<<: *rx2, type: vfo, channel: 1, key: 10}
+`,
+	})
+
+	actual, err := ReadFile(filepath.Join(dir, "station.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "DJControl Starlight", actual.PortName)
+	assert.Equal(t, "starlight", actual.Indicators)
+	assert.Equal(t, "localhost:40001", actual.TCIAddress)
+	assert.Equal(t, []ctrl.Mapping{
+		{Type: ctrl.MOXMapping, Channel: 1, Key: 12},
+		{Type: ctrl.VFOMapping, Channel: 1, Key: 10, TRX: 1, VFO: "VFOB", Options: map[string]string{"step": "10", "text": "step 10 Hz"}},
+		{Type: ctrl.EnableRXMapping, Channel: 1, Key: 11, TRX: 1, VFO: "VFOA"},
+	}, actual.Mappings)
+	assert.Equal(t, []string{filepath.Join(dir, "station.json"), filepath.Join(dir, "profile.yaml")}, actual.Files)
+
+	actual, err = ReadFile(filepath.Join(dir, "anchors.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []ctrl.Mapping{
+		{Type: ctrl.MOXMapping, Channel: 1, Key: 12},
+		{Type: ctrl.VFOMapping, Channel: 1, Key: 10, TRX: 1, VFO: "VFOB"},
+	}, actual.Mappings)
+}
+
+func TestValidateFile_Positions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"profile.yaml": `
+mappings:
+  - {type: mox, channel: 1, key: 12}
+`,
+		"station.yaml": `
+include: profile.yaml
+mappings:
+  - type: tune
+    channel: 1
+    key: 12
+  - {type: mode, channel: 1, key: 13, options: {mode: ssb}}
+`,
+		"station.toml": `
+include = "unknown.toml"
+`,
+		"variables.yaml": `
+mappings:
+  - {type: mox, channel: 1, key: "${key}"}
+`,
+	})
+	profile := filepath.Join(dir, "profile.yaml")
+	station := filepath.Join(dir, "station.yaml")
+
+	_, problems, err := ValidateFile(station)
+	require.NoError(t, err)
+	assert.Equal(t, []Problem{
+		{Filename: station, Line: 4, Column: 5, Message: "note channel 1 key 12 is already used by mox (see " + profile + ":3)"},
+		{Filename: station, Line: 7, Column: 55, Message: "mode: invalid value \"ssb\", use one of am, sam, dsb, lsb, usb, cw, nfm, wfm, digl, digu, spec, drm"},
+	}, problems)
+
+	_, problems, err = ValidateFile(filepath.Join(dir, "station.toml"))
+	require.NoError(t, err)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, 2, problems[0].Line)
+		assert.Contains(t, problems[0].Message, "cannot include")
+	}
+
+	_, problems, err = ValidateFile(filepath.Join(dir, "variables.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []Problem{{Filename: filepath.Join(dir, "variables.yaml"), Line: 3, Column: 34, Message: "unknown variable ${key}"}}, problems)
+}
+
+func TestReadFile_NamedControls(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+profile: starlight
+mappings:
+  - {type: vfo, control: deck_a.jog, vfo: VFOA}
+  - {type: mox, control: deck_a.play}
+`,
+		"invalid.yaml": `
+devices:
+  - {name: starlight, profile: starlight}
+  - {name: footswitch}
+mappings:
+  - {type: mox, control: crossfader}
+  - {type: tune, device: footswitch, control: pedal}
+`,
+	})
+
+	actual, err := ReadFile(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "starlight", actual.DeviceConfigs()[0].Indicators)
+	assert.Equal(t, []ctrl.Mapping{
+		{Type: ctrl.VFOMapping, Control: "deck_a.jog", Channel: 1, Key: 10, VFO: "VFOA"},
+		{Type: ctrl.MOXMapping, Control: "deck_a.play", Channel: 1, Key: 7},
+	}, actual.Mappings)
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	_, problems, err := ValidateFile(invalid)
+	require.NoError(t, err)
+	assert.Equal(t, []Problem{
+		{Filename: invalid, Line: 6, Column: 26, Message: "crossfader is a poti, mox needs a button"},
+		{Filename: invalid, Line: 7, Column: 47, Message: "the device needs a profile to reference controls by name"},
+	}, problems)
+}
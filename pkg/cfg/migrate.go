@@ -0,0 +1,141 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/hardware"
+)
+
+// Migrate upgrades all mappings to the current schema and sorts them by device, channel and key. Mappings that
+// reference their control by name are sorted by the channel and key of the control. It returns the descriptions
+// of all upgrades.
+func (c *Configuration) Migrate() []string {
+	var result []string
+	for i, mapping := range c.Mappings {
+		migrated, upgrades := ctrl.Migrate(mapping)
+		for _, upgrade := range upgrades {
+			result = append(result, fmt.Sprintf("mapping %d: %s", i+1, upgrade))
+		}
+		c.Mappings[i] = migrated
+	}
+	c.sortMappings()
+	return result
+}
+
+func (c *Configuration) sortMappings() {
+	deviceConfigs := c.DeviceConfigs()
+	devices := make(map[string]int, len(deviceConfigs))
+	for i, device := range deviceConfigs {
+		devices[device.Name] = i
+	}
+
+	type sortKey struct {
+		device int
+		key    ctrl.MidiKey
+	}
+	keys := make(map[int]sortKey, len(c.Mappings))
+	for i, mapping := range c.Mappings {
+		name := mapping.Device
+		if name == "" {
+			name = deviceConfigs[0].Name
+		}
+		device, ok := devices[name]
+		if !ok {
+			device = len(deviceConfigs)
+		}
+		key := mapping.MidiKey()
+		if mapping.Control != "" && ok {
+			if profile, err := hardware.Get(deviceConfigs[device].Profile); err == nil {
+				if control, ok := profile.Control(mapping.Control); ok {
+					key = control.MidiKey()
+				}
+			}
+		}
+		keys[i] = sortKey{device: device, key: key}
+	}
+
+	order := make([]int, len(c.Mappings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := keys[order[i]], keys[order[j]]
+		if a.device != b.device {
+			return a.device < b.device
+		}
+		if a.key.Channel != b.key.Channel {
+			return a.key.Channel < b.key.Channel
+		}
+		return a.key.Key < b.key.Key
+	})
+
+	sorted := make([]ctrl.Mapping, len(c.Mappings))
+	for i, index := range order {
+		sorted[i] = c.Mappings[index]
+	}
+	c.Mappings = sorted
+}
+
+// Formatted is a configuration file in the canonical format.
+type Formatted struct {
+	// Data contains the formatted configuration as JSON.
+	Data []byte
+	// Upgrades describes the upgrades of the mappings.
+	Upgrades []string
+	// SelfContained is true for a JSON file without includes and variables. Only such a file can be replaced
+	// by its formatted version without losing information.
+	SelfContained bool
+}
+
+// Format reads the given configuration file with all included files, upgrades it to the current schema and
+// returns it in the canonical format, see Write. The references to named controls are kept. Fields that are
+// unknown would be lost, therefore a configuration with unknown fields cannot be formatted.
+func Format(filename string) (Formatted, error) {
+	root, _, err := loadDocument(filename)
+	if err != nil {
+		return Formatted{}, err
+	}
+	_, data, err := decode(root)
+	if err != nil {
+		return Formatted{}, err
+	}
+	var config Configuration
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&config)
+	if err != nil {
+		return Formatted{}, err
+	}
+
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return Formatted{}, err
+	}
+	document, err := parseDocument(filename, source)
+	if err != nil {
+		return Formatted{}, err
+	}
+	_, hasInclude := document.get(includeKey)
+	_, hasVariables := document.get(variablesKey)
+	extension := strings.ToLower(filepath.Ext(filename))
+	isJSON := extension != ".yaml" && extension != ".yml" && extension != ".toml"
+
+	result := Formatted{
+		Upgrades:      config.Migrate(),
+		SelfContained: isJSON && !hasInclude && !hasVariables,
+	}
+	var buffer bytes.Buffer
+	err = Write(&buffer, config)
+	if err != nil {
+		return Formatted{}, err
+	}
+	result.Data = buffer.Bytes()
+	return result, nil
+}
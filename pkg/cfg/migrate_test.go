@@ -0,0 +1,55 @@
+package cfg
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.json": `{"tci_address": "localhost:40001", "port_name": null, "profile": "starlight",
+	"mappings": [
+		{"type": "vfo", "channel": 2, "key": 10, "vfo": "b", "options": {"direction": "default", "step": 10}},
+		{"type": "mox", "control": "deck_a.play"},
+		{"type": "experimental_rx_mixer", "channel": 0, "key": 0}
+	]
+}`,
+		"config.yaml": `
+variables:
+  step: 10
+mappings:
+  - {type: vfo, channel: 1, key: 10, vfo: VFOA, options: {step: "${step}"}}
+`,
+		"unknown.json": `{"tci_adress": "localhost:40001"}`,
+	})
+	expected := `{
+    "tci_address": "localhost:40001",
+    "profile": "starlight",
+    "mappings": [
+        {"type": "rx_mixer_balance", "channel": 0, "key": 0, "trx": 0},
+        {"type": "mox", "control": "deck_a.play", "trx": 0},
+        {"type": "vfo", "channel": 2, "key": 10, "trx": 0, "vfo": "VFOB", "options": {"direction": "normal", "step": "10"}}
+    ]
+}
+`
+
+	actual, err := Format(filepath.Join(dir, "config.json"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(actual.Data))
+	assert.Equal(t, []string{
+		"mapping 1: vfo: the value default of the option direction is replaced by normal",
+		"mapping 3: the mapping type experimental_rx_mixer is replaced by rx_mixer_balance",
+	}, actual.Upgrades)
+	assert.True(t, actual.SelfContained)
+
+	actual, err = Format(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(actual.Data), `"options": {"step": "10"}`)
+	assert.False(t, actual.SelfContained)
+
+	_, err = Format(filepath.Join(dir, "unknown.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EnvironmentPrefix is the prefix of the environment variables that override the configuration.
+const EnvironmentPrefix = "MIDI2TCI_"
+
+// Override replaces a value of the configuration. The key is a path of field names separated by dots,
+// e.g. tci_address or cw_variables.mycall. In a list, an item is selected by its index, or all mappings of a type
+// are selected by the name of the type, e.g. mappings.vfo.options.step. The source tells where the override
+// comes from, it is used in error messages.
+type Override struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// ParseOverride parses an override in the form key=value.
+func ParseOverride(s string) (Override, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return Override{}, fmt.Errorf("invalid override %q, use key=value", s)
+	}
+	return Override{Key: key, Value: value, Source: "--set " + key}, nil
+}
+
+// EnvironmentOverrides returns the overrides that are given by environment variables in the form
+// MIDI2TCI_<KEY>=value. The key is written in upper case and the dots are replaced by double underscores,
+// e.g. MIDI2TCI_CW_VARIABLES__MYCALL. The given variables that are listed in ignore are skipped.
+func EnvironmentOverrides(environ []string, ignore ...string) []Override {
+	var result []Override
+	for _, variable := range environ {
+		name, value, ok := strings.Cut(variable, "=")
+		if !ok || !strings.HasPrefix(name, EnvironmentPrefix) || contains(ignore, name) {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, EnvironmentPrefix), "__", "."))
+		result = append(result, Override{Key: key, Value: value, Source: name})
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOverrides sets the values of the given overrides in the document. The value of an override is read as JSON,
+// if possible, e.g. numbers, booleans or lists. Otherwise, and if the overridden value is a string, the value is
+// used as string.
+func (n *node) applyOverrides(overrides []Override) error {
+	properties := JSONSchema()["properties"].(jsonObject)
+	for _, o := range overrides {
+		path := strings.Split(o.Key, ".")
+		if _, ok := properties[path[0]]; !ok || path[0] == includeKey || path[0] == variablesKey {
+			return fmt.Errorf("%s: unknown configuration key %s", o.Source, path[0])
+		}
+		err := n.override(path, o)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.Source, err)
+		}
+	}
+	return nil
+}
+
+func (n *node) override(path []string, o Override) error {
+	name := path[0]
+	last := len(path) == 1
+	switch n.kind {
+	case objectNode:
+		field, ok := n.get(name)
+		if last {
+			n.set(name, overrideValue(o, field))
+			return nil
+		}
+		if !ok {
+			field = newObject(position{file: o.Source})
+			n.set(name, field)
+		}
+		return field.override(path[1:], o)
+	case arrayNode:
+		if index, err := strconv.Atoi(name); err == nil {
+			if index < 0 || index >= len(n.items) {
+				return fmt.Errorf("there is no item %d", index)
+			}
+			if last {
+				n.items[index] = overrideValue(o, n.items[index])
+				return nil
+			}
+			return n.items[index].override(path[1:], o)
+		}
+		if last {
+			return fmt.Errorf("%s is not an index", name)
+		}
+		found := false
+		for _, item := range n.items {
+			itemType, ok := item.get("type")
+			if !ok || itemType.kind != scalarNode || itemType.value != name {
+				continue
+			}
+			found = true
+			err := item.override(path[1:], o)
+			if err != nil {
+				return err
+			}
+		}
+		if !found {
+			return fmt.Errorf("there is no item of type %s", name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s cannot be set, its parent is not an object or a list", name)
+	}
+}
+
+func overrideValue(o Override, current *node) *node {
+	pos := position{file: o.Source}
+	if current != nil && current.kind == scalarNode {
+		if _, ok := current.value.(string); ok {
+			return newScalar(pos, o.Value)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(o.Value)))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return newScalar(pos, o.Value)
+	}
+	return plainNode(pos, value)
+}
+
+// plainNode converts a plain value, as it is decoded from JSON, into a node.
+func plainNode(pos position, value any) *node {
+	switch v := value.(type) {
+	case map[string]any:
+		result := newObject(pos)
+		for _, key := range sortedKeys(v) {
+			result.set(key, plainNode(pos, v[key]))
+		}
+		return result
+	case []any:
+		result := newArray(pos)
+		for _, item := range v {
+			result.items = append(result.items, plainNode(pos, item))
+		}
+		return result
+	default:
+		return newScalar(pos, v)
+	}
+}
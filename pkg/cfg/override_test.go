@@ -0,0 +1,83 @@
+package cfg
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func TestParseOverride(t *testing.T) {
+	actual, err := ParseOverride("cw_variables.rst=5nn=599")
+	require.NoError(t, err)
+	assert.Equal(t, Override{Key: "cw_variables.rst", Value: "5nn=599", Source: "--set cw_variables.rst"}, actual)
+
+	_, err = ParseOverride("tci_address")
+	assert.Error(t, err)
+	_, err = ParseOverride("=localhost")
+	assert.Error(t, err)
+}
+
+func TestEnvironmentOverrides(t *testing.T) {
+	actual := EnvironmentOverrides([]string{
+		"HOME=/home/dl0abc",
+		"MIDI2TCI_TCI_ADDRESS=localhost:40001",
+		"MIDI2TCI_CW_VARIABLES__MYCALL=DL0ABC",
+		"MIDI2TCI_CONFIG=config.json",
+	}, "MIDI2TCI_CONFIG")
+	assert.Equal(t, []Override{
+		{Key: "tci_address", Value: "localhost:40001", Source: "MIDI2TCI_TCI_ADDRESS"},
+		{Key: "cw_variables.mycall", Value: "DL0ABC", Source: "MIDI2TCI_CW_VARIABLES__MYCALL"},
+	}, actual)
+}
+
+func TestReadFile_Overrides(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+port_name: "42"
+tci_address: localhost:40001
+mappings:
+  - {type: vfo, channel: 1, key: 10, vfo: VFOA, options: {step: 10}}
+  - {type: vfo, channel: 2, key: 10, vfo: VFOB}
+  - {type: mox, channel: 1, key: 12}
+`,
+	})
+	filename := filepath.Join(dir, "config.yaml")
+
+	actual, err := ReadFile(filename,
+		Override{Key: "tci_address", Value: "radio:40001", Source: "MIDI2TCI_TCI_ADDRESS"},
+		Override{Key: "port_name", Value: "43", Source: "--set port_name"},
+		Override{Key: "port_number", Value: "2", Source: "--set port_number"},
+		Override{Key: "cw_variables.mycall", Value: "DL0ABC", Source: "--set cw_variables.mycall"},
+		Override{Key: "mappings.vfo.options.step", Value: "5", Source: "--set mappings.vfo.options.step"},
+		Override{Key: "mappings.2.trx", Value: "1", Source: "--set mappings.2.trx"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "radio:40001", actual.TCIAddress)
+	assert.Equal(t, "43", actual.PortName)
+	assert.Equal(t, 2, actual.PortNumber)
+	assert.Equal(t, map[string]string{"mycall": "DL0ABC"}, actual.CWVariables)
+	assert.Equal(t, []ctrl.Mapping{
+		{Type: ctrl.VFOMapping, Channel: 1, Key: 10, VFO: "VFOA", Options: map[string]string{"step": "5"}},
+		{Type: ctrl.VFOMapping, Channel: 2, Key: 10, VFO: "VFOB", Options: map[string]string{"step": "5"}},
+		{Type: ctrl.MOXMapping, Channel: 1, Key: 12, TRX: 1},
+	}, actual.Mappings)
+
+	for _, override := range []Override{
+		{Key: "tci_adress", Value: "radio:40001", Source: "--set tci_adress"},
+		{Key: "variables.step", Value: "5", Source: "--set variables.step"},
+		{Key: "mappings.tune.trx", Value: "1", Source: "--set mappings.tune.trx"},
+		{Key: "mappings.3.trx", Value: "1", Source: "--set mappings.3.trx"},
+		{Key: "tci_address.host", Value: "radio", Source: "--set tci_address.host"},
+	} {
+		_, err := ReadFile(filename, override)
+		assert.ErrorContains(t, err, override.Source)
+	}
+
+	_, problems, err := ValidateFile(filename, Override{Key: "mappings.mox.channel", Value: "16", Source: "--set mappings.mox.channel"})
+	require.NoError(t, err)
+	assert.Equal(t, []Problem{{Filename: "--set mappings.mox.channel", Message: "mox: invalid channel 16, use 0 to 15"}}, problems)
+}
@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// positions maps the paths of all values in a configuration document to their positions in the source files.
+// A path is built like a JSON pointer, e.g. /mappings/3/options/step.
+type positions map[string]position
+
+// lookup returns the position of the given path. If the path does not exist in the document, the position
+// of the closest existing parent is returned.
+func (p positions) lookup(path string) position {
+	for {
+		if pos, ok := p[path]; ok {
+			return pos
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return position{}
+		}
+		path = path[:i]
+	}
+}
+
+// offsets maps the paths of all values in a JSON document to their byte offsets.
+type offsets map[string]int64
+
+func indexOffsets(data []byte) (offsets, error) {
+	result := make(offsets)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	err := result.index(decoder, data, "")
+	return result, err
+}
+
+func (o offsets) index(decoder *json.Decoder, data []byte, path string) error {
+	o[path] = skipSeparators(data, decoder.InputOffset())
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			err = o.index(decoder, data, path+"/"+name)
+			if err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; decoder.More(); i++ {
+			err = o.index(decoder, data, path+"/"+strconv.Itoa(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// the closing delimiter
+	_, err = decoder.Token()
+	return err
+}
+
+// pathAt returns the path of the innermost value that starts before the given offset.
+func (o offsets) pathAt(offset int64) string {
+	result := ""
+	var resultOffset int64 = -1
+	for path, start := range o {
+		if start >= offset || start < resultOffset {
+			continue
+		}
+		if start > resultOffset || len(path) > len(result) {
+			result = path
+			resultOffset = start
+		}
+	}
+	return result
+}
+
+// skipSeparators returns the offset of the next value, starting at the given offset.
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// lineAndColumn returns the line and the column of the given offset, both start at 1.
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
@@ -0,0 +1,195 @@
+package cfg
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/display"
+	"github.com/ftl/midi2tci/pkg/hardware"
+	"github.com/ftl/midi2tci/pkg/led"
+)
+
+type jsonObject = map[string]any
+
+// JSONSchema returns a JSON schema of the configuration file that can be used by editors for autocompletion
+// and validation. The mappings are described by the definitions of all registered mapping types.
+func JSONSchema() jsonObject {
+	sequence := jsonObject{
+		"type":        "array",
+		"description": "MIDI messages and delays, each step is an array of bytes or a string like \"note_on ch1 0x24 127\", \"cc ch0 3 64\", \"sysex F0 00 20 F7\" or \"delay 50ms\"",
+		"items": jsonObject{
+			"anyOf": []jsonObject{
+				{"type": "array", "items": jsonObject{"type": "integer", "minimum": 0, "maximum": 255}},
+				{"type": "string"},
+			},
+		},
+	}
+	deviceProperties := jsonObject{
+		"port_number":         jsonObject{"type": "integer", "description": "the number of the MIDI port"},
+		"port_name":           jsonObject{"type": "string", "description": "the name of the MIDI port"},
+		"profile":             jsonObject{"type": "string", "description": "the name of the device profile that defines the named controls", "enum": hardware.Names()},
+		"indicators":          jsonObject{"type": "string", "description": "the name of the built-in LED profile", "examples": led.BuiltinProfileNames()},
+		"led_profile":         jsonObject{"type": "object", "description": "a custom LED profile"},
+		"encoder_encoding":    jsonObject{"type": "string", "description": "how the encoders send their turns", "enum": []string{string(ctrl.OffsetEncoding), string(ctrl.SignMagnitudeEncoding), string(ctrl.TwosComplementEncoding)}},
+		"init_sequence":       sequence,
+		"connect_sequence":    sequence,
+		"disconnect_sequence": sequence,
+	}
+	device := jsonObject{
+		"type":                 "object",
+		"properties":           withProperty(deviceProperties, "name", jsonObject{"type": "string", "description": "the unique name of the device"}),
+		"additionalProperties": false,
+	}
+
+	properties := jsonObject{
+		"tci_address":     jsonObject{"type": "string", "description": "the address of the TCI server, e.g. localhost:40001"},
+		"devices":         jsonObject{"type": "array", "items": device},
+		"control_address": jsonObject{"type": "string", "description": "the address of the local control interface"},
+		"cw_variables":    jsonObject{"type": "object", "description": "the variables of the CW macros", "additionalProperties": jsonObject{"type": "string"}},
+		"cw_serial_file":  jsonObject{"type": "string", "description": "the file that stores the serial number of the CW macros"},
+		"displays":        jsonObject{"type": "array", "items": displaySchema()},
+		"mappings":        jsonObject{"type": "array", "items": mappingSchema()},
+		"include":         jsonObject{"type": []string{"string", "array"}, "items": jsonObject{"type": "string"}, "description": "the files that are included, relative to this file"},
+		"variables":       jsonObject{"type": "object", "description": "the variables that can be used with ${name}"},
+	}
+	for name, property := range deviceProperties {
+		properties[name] = property
+	}
+
+	return jsonObject{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "midi2tci configuration",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func withProperty(properties jsonObject, name string, property jsonObject) jsonObject {
+	result := make(jsonObject, len(properties)+1)
+	for k, v := range properties {
+		result[k] = v
+	}
+	result[name] = property
+	return result
+}
+
+func displaySchema() jsonObject {
+	encoders := make([]string, 0, len(display.Encoders))
+	for name := range display.Encoders {
+		encoders = append(encoders, name)
+	}
+	sort.Strings(encoders)
+
+	return jsonObject{
+		"type": "object",
+		"properties": jsonObject{
+			"name":     jsonObject{"type": "string"},
+			"device":   jsonObject{"type": "string", "description": "the name of the device, the first device is used by default"},
+			"encoder":  jsonObject{"type": "string", "enum": encoders},
+			"template": jsonObject{"type": "string", "description": "the text of the display with variables in curly braces, e.g. {vfo_a}"},
+			"trx":      jsonObject{"type": "integer", "minimum": 0},
+			"interval": jsonObject{"type": "integer", "minimum": 0, "description": "the minimum time between two updates in milliseconds"},
+			"options":  jsonObject{"type": "object", "additionalProperties": jsonObject{"type": "string"}},
+		},
+		"required":             []string{"encoder", "template"},
+		"additionalProperties": false,
+	}
+}
+
+func mappingSchema() jsonObject {
+	types := make([]jsonObject, 0, len(ctrl.Definitions))
+	conditions := make([]jsonObject, 0, len(ctrl.Definitions))
+	for _, mappingType := range ctrl.MappingTypes() {
+		definition := ctrl.Definitions[mappingType]
+		typeSchema := jsonObject{"const": string(mappingType), "description": definition.Description}
+		if definition.ReplacedBy != "" {
+			typeSchema["deprecated"] = true
+		}
+		types = append(types, typeSchema)
+
+		then := jsonObject{
+			"properties": jsonObject{"options": optionsSchema(definition)},
+		}
+		if definition.VFO {
+			then["required"] = []string{"vfo"}
+		}
+		conditions = append(conditions, jsonObject{
+			"if":   jsonObject{"properties": jsonObject{"type": jsonObject{"const": string(mappingType)}}},
+			"then": then,
+		})
+	}
+
+	return jsonObject{
+		"type": "object",
+		"properties": jsonObject{
+			"type":    jsonObject{"anyOf": types},
+			"device":  jsonObject{"type": "string", "description": "the name of the device, the first device is used by default"},
+			"control": jsonObject{"type": "string", "description": "the name of a control in the device profile, replaces channel and key, e.g. deck_a.jog"},
+			"channel": jsonObject{"type": "integer", "minimum": 0, "maximum": 15},
+			"key":     jsonObject{"type": "integer", "minimum": -1, "maximum": 127, "description": "the key or controller number, -1 for pitch bend"},
+			"trx":     jsonObject{"type": "integer", "minimum": 0},
+			"vfo":     jsonObject{"type": "string", "enum": []string{"VFOA", "VFOB", "A", "B"}},
+			"options": jsonObject{"type": "object"},
+			"<<":      jsonObject{"type": "string", "description": "merges the fields of a variable into the mapping, e.g. ${rx2}"},
+		},
+		"required":             []string{"type"},
+		"additionalProperties": false,
+		"allOf":                conditions,
+	}
+}
+
+func optionsSchema(definition ctrl.Definition) jsonObject {
+	properties := make(jsonObject)
+	patternProperties := make(jsonObject)
+	var required []string
+	for _, option := range definition.AllOptions() {
+		schema := optionSchema(option)
+		if option.Prefix {
+			patternProperties["^"+option.Name+"_("+strings.Join(option.Suffixes, "|")+")$"] = schema
+			continue
+		}
+		properties[option.Name] = schema
+		if option.Required {
+			required = append(required, option.Name)
+		}
+	}
+
+	result := jsonObject{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(patternProperties) > 0 {
+		result["patternProperties"] = patternProperties
+	}
+	if len(required) > 0 {
+		result["required"] = required
+	}
+	return result
+}
+
+// optionSchema describes the given option. All options are strings in the configuration file.
+func optionSchema(option ctrl.OptionSchema) jsonObject {
+	result := jsonObject{
+		"type":        "string",
+		"description": option.Description,
+	}
+	if option.Default != "" {
+		result["default"] = option.Default
+	}
+	switch {
+	case len(option.Values) > 0:
+		result["enum"] = option.Values
+	case option.Type == ctrl.IntOptionType:
+		result["pattern"] = `^\s*-?[0-9]+\s*$`
+	case option.Type == ctrl.FloatOptionType:
+		result["pattern"] = `^\s*-?[0-9]+(\.[0-9]+)?\s*$`
+	case option.Type == ctrl.BoolOptionType:
+		result["enum"] = []string{"true", "false", "on", "off", "yes", "no", "1", "0"}
+	case option.Type == ctrl.VFOOptionType:
+		result["enum"] = []string{"VFOA", "VFOB", "A", "B"}
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package cfg
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func TestJSONSchema_ContainsAllFields(t *testing.T) {
+	schema := JSONSchema()
+	properties := schema["properties"].(jsonObject)
+
+	configType := reflect.TypeOf(Configuration{})
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		assert.Contains(t, properties, name)
+	}
+
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+}
+
+func TestJSONSchema_ContainsAllMappingTypes(t *testing.T) {
+	schema := JSONSchema()
+	mapping := schema["properties"].(jsonObject)["mappings"].(jsonObject)["items"].(jsonObject)
+	conditions := mapping["allOf"].([]jsonObject)
+
+	assert.Equal(t, len(ctrl.Definitions), len(conditions))
+	for i, mappingType := range ctrl.MappingTypes() {
+		condition := conditions[i]["if"].(jsonObject)["properties"].(jsonObject)["type"].(jsonObject)
+		assert.Equal(t, string(mappingType), condition["const"])
+	}
+}
@@ -0,0 +1,65 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ApplicationName is the name of the directory of the configuration file in the configuration directories.
+const ApplicationName = "midi2tci"
+
+// Filenames are the names of the configuration file that are searched in each directory, in this order.
+var Filenames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// SearchPath returns the directories that are searched for the configuration file, in this order:
+// the working directory, the configuration directory of the user (e.g. $XDG_CONFIG_HOME/midi2tci), the system
+// configuration directories in $XDG_CONFIG_DIRS (/etc/xdg/midi2tci by default), and the directory of the executable.
+func SearchPath() []string {
+	result := []string{"."}
+	if dir := UserDir(); dir != "" {
+		result = append(result, dir)
+	}
+
+	systemDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if systemDirs == "" && runtime.GOOS != "windows" {
+		systemDirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(systemDirs) {
+		if dir != "" {
+			result = append(result, filepath.Join(dir, ApplicationName))
+		}
+	}
+
+	if executable, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		result = append(result, filepath.Dir(executable))
+	}
+	return result
+}
+
+// UserDir returns the configuration directory of the user, e.g. $XDG_CONFIG_HOME/midi2tci or ~/.config/midi2tci.
+// It returns an empty string if the configuration directory of the user is unknown.
+func UserDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ApplicationName)
+}
+
+// Find returns the first configuration file in the given directories.
+func Find(dirs []string) (string, bool) {
+	for _, dir := range dirs {
+		for _, name := range Filenames {
+			filename := filepath.Join(dir, name)
+			info, err := os.Stat(filename)
+			if err == nil && !info.IsDir() {
+				return filename, true
+			}
+		}
+	}
+	return "", false
+}
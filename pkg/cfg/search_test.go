@@ -0,0 +1,38 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/home/dl0abc/.config")
+	t.Setenv("XDG_CONFIG_DIRS", "/etc/xdg"+string(filepath.ListSeparator)+"/usr/local/etc/xdg")
+
+	actual := SearchPath()
+	require.Len(t, actual, 5)
+	assert.Equal(t, ".", actual[0])
+	assert.Equal(t, filepath.Join("/etc/xdg", ApplicationName), actual[2])
+	assert.Equal(t, filepath.Join("/usr/local/etc/xdg", ApplicationName), actual[3])
+	if os.Getenv("HOME") != "" {
+		assert.Equal(t, filepath.Join("/home/dl0abc/.config", ApplicationName), actual[1])
+	}
+}
+
+func TestFind(t *testing.T) {
+	empty := t.TempDir()
+	user := writeFiles(t, map[string]string{"config.yaml": "mappings: []"})
+	system := writeFiles(t, map[string]string{"config.json": `{"mappings": []}`})
+	require.NoError(t, os.Mkdir(filepath.Join(empty, "config.json"), 0755))
+
+	actual, ok := Find([]string{empty, user, system})
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(user, "config.yaml"), actual)
+
+	_, ok = Find([]string{empty})
+	assert.False(t, ok)
+}
@@ -0,0 +1,229 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/hardware"
+	"github.com/ftl/midi2tci/pkg/sequence"
+)
+
+// Problem is a problem in a configuration file at the given line and column.
+type Problem struct {
+	Filename string
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Filename, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.Filename, p.Line, p.Column, p.Message)
+}
+
+// finding is a problem at the given path of the configuration. If the problem relates to another part of
+// the configuration, its path is given in related.
+type finding struct {
+	path    string
+	message string
+	related string
+}
+
+// ValidateFile reads the given configuration file with all included files and checks it. The configuration
+// is only returned if the files can be parsed, even if they contain problems. The given overrides replace values
+// of the configuration before it is checked.
+func ValidateFile(filename string, overrides ...Override) (Configuration, []Problem, error) {
+	root, files, err := loadDocument(filename, overrides...)
+	var documentErr *documentError
+	if errors.As(err, &documentErr) {
+		return Configuration{}, []Problem{documentErr.problem()}, nil
+	}
+	if err != nil {
+		return Configuration{}, nil, err
+	}
+
+	config, problems := validateDocument(root)
+	config.Files = files
+	return config, problems, nil
+}
+
+// Validate parses the given JSON configuration and checks every device, display and mapping.
+func Validate(data []byte) (Configuration, []Problem) {
+	root, err := parseJSON("", data)
+	if err == nil {
+		err = prepare(root)
+	}
+	var documentErr *documentError
+	if errors.As(err, &documentErr) {
+		return Configuration{}, []Problem{documentErr.problem()}
+	}
+	if err != nil {
+		return Configuration{}, []Problem{{Message: err.Error()}}
+	}
+	return validateDocument(root)
+}
+
+func validateDocument(root *node) (Configuration, []Problem) {
+	positions := root.positions()
+	config, data, err := decode(root)
+	if err != nil {
+		path := ""
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) {
+			offsets, _ := indexOffsets(data)
+			path = offsets.pathAt(typeError.Offset)
+		}
+		return Configuration{}, []Problem{problemAt(positions.lookup(path), err.Error())}
+	}
+
+	findings := config.resolveControls()
+	findings = append(findings, config.validate()...)
+	result := make([]Problem, 0, len(findings))
+	for _, f := range findings {
+		pos := positions.lookup(f.path)
+		message := f.message
+		if f.related != "" {
+			related := positions.lookup(f.related)
+			if related.file == pos.file {
+				message = fmt.Sprintf("%s (see line %d)", message, related.line)
+			} else {
+				message = fmt.Sprintf("%s (see %s:%d)", message, related.file, related.line)
+			}
+		}
+		result = append(result, problemAt(pos, message))
+	}
+	return config, result
+}
+
+func problemAt(pos position, message string) Problem {
+	return Problem{Filename: pos.file, Line: pos.line, Column: pos.column, Message: message}
+}
+
+func (c Configuration) validate() []finding {
+	var result []finding
+
+	// devices
+	deviceConfigs := c.DeviceConfigs()
+	deviceNames := make(map[string]bool)
+	for i, device := range deviceConfigs {
+		path := fmt.Sprintf("/devices/%d", i)
+		if len(c.Devices) == 0 {
+			path = ""
+		}
+		if len(deviceConfigs) > 1 && device.Name == "" {
+			result = append(result, finding{path: path, message: "each device needs a name"})
+		} else if deviceNames[device.Name] {
+			result = append(result, finding{path: path + "/name", message: fmt.Sprintf("duplicate device name %s", device.Name)})
+		}
+		deviceNames[device.Name] = true
+
+		if device.Profile != "" {
+			if _, err := hardware.Get(device.Profile); err != nil {
+				result = append(result, finding{path: path + "/profile", message: err.Error()})
+			}
+		}
+
+		sequences := []struct {
+			name  string
+			steps sequence.Sequence
+		}{
+			{"init_sequence", device.InitSequence},
+			{"connect_sequence", device.ConnectSequence},
+			{"disconnect_sequence", device.DisconnectSequence},
+		}
+		for _, s := range sequences {
+			if step, err := s.steps.Validate(); err != nil {
+				result = append(result, finding{path: fmt.Sprintf("%s/%s/%d", path, s.name, step), message: fmt.Sprintf("invalid MIDI sequence: %v", err)})
+			}
+		}
+
+		_, err := device.IndicatorProfile()
+		if err != nil {
+			field := "/indicators"
+			if device.LEDProfile != nil {
+				field = "/led_profile"
+			}
+			result = append(result, finding{path: path + field, message: fmt.Sprintf("invalid LED profile: %v", err)})
+		}
+	}
+	defaultDevice := deviceConfigs[0].Name
+	deviceName := func(name string) (string, bool) {
+		if name == "" {
+			return defaultDevice, true
+		}
+		return name, deviceNames[name]
+	}
+
+	// displays
+	for i, config := range c.Displays {
+		path := fmt.Sprintf("/displays/%d", i)
+		if _, ok := deviceName(config.Device); !ok {
+			result = append(result, finding{path: path + "/device", message: fmt.Sprintf("unknown device %s", config.Device)})
+		}
+		err := config.Validate()
+		if err != nil {
+			result = append(result, finding{path: path, message: err.Error()})
+		}
+	}
+
+	// mappings
+	type assignment struct {
+		device string
+		input  string
+		key    ctrl.MidiKey
+	}
+	assigned := make(map[assignment]int)
+	assign := func(i int, device string, input string, key ctrl.MidiKey) {
+		a := assignment{device: device, input: input, key: key}
+		if first, ok := assigned[a]; ok {
+			result = append(result, finding{
+				path:    fmt.Sprintf("/mappings/%d", i),
+				message: fmt.Sprintf("%s channel %d key %d is already used by %s", input, key.Channel, key.Key, c.Mappings[first].Type),
+				related: fmt.Sprintf("/mappings/%d", first),
+			})
+			return
+		}
+		assigned[a] = i
+	}
+	for i, mapping := range c.Mappings {
+		path := fmt.Sprintf("/mappings/%d", i)
+		definition, ok := ctrl.Definitions[mapping.Type]
+		if !ok {
+			result = append(result, finding{path: path + "/type", message: fmt.Sprintf("unknown mapping type %s", mapping.Type)})
+			continue
+		}
+		device, ok := deviceName(mapping.Device)
+		if !ok {
+			result = append(result, finding{path: path + "/device", message: fmt.Sprintf("unknown device %s", mapping.Device)})
+		}
+
+		errs := definition.Validate(mapping)
+		for _, err := range errs {
+			result = append(result, finding{path: path + "/" + err.Field, message: fmt.Sprintf("%s: %v", mapping.Type, err.Err)})
+		}
+		if len(errs) > 0 {
+			continue
+		}
+
+		switch definition.ControlType(mapping) {
+		case ctrl.ButtonControl:
+			assign(i, device, "note", mapping.MidiKey())
+		case ctrl.PotiControl, ctrl.EncoderControl:
+			if mapping.MidiKey().IsPitchbend() {
+				assign(i, device, "pitch bend", mapping.MidiKey())
+			} else {
+				assign(i, device, "control change", mapping.MidiKey())
+			}
+		}
+		touchKey, _, _, _ := mapping.FaderOptions()
+		if touchKey != nil {
+			assign(i, device, "note", *touchKey)
+		}
+	}
+
+	return result
+}
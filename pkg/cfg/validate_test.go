@@ -0,0 +1,96 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		desc     string
+		config   string
+		expected []Problem
+	}{
+		{
+			desc: "valid",
+			config: `{
+	"mappings": [
+		{"type": "mox", "channel": 0, "key": 1},
+		{"type": "volume", "channel": 0, "key": 1}
+	]
+}`,
+			expected: []Problem{},
+		},
+		{
+			desc:     "syntax error",
+			config:   "{\n\t\"mappings\": [\n\t\t{\"type\": \"mox\",}\n\t]\n}",
+			expected: []Problem{{Line: 3, Column: 18, Message: "invalid character '}' looking for beginning of object key string"}},
+		},
+		{
+			desc: "unknown type and option",
+			config: `{
+	"mappings": [
+		{"type": "moxx", "channel": 0, "key": 1},
+		{"type": "mode", "channel": 0, "key": 2, "options": {"mode": "ssb"}}
+	]
+}`,
+			expected: []Problem{
+				{Line: 3, Column: 12, Message: "unknown mapping type moxx"},
+				{Line: 4, Column: 64, Message: "mode: invalid value \"ssb\", use one of am, sam, dsb, lsb, usb, cw, nfm, wfm, digl, digu, spec, drm"},
+			},
+		},
+		{
+			desc: "duplicate keys",
+			config: `{
+	"mappings": [
+		{"type": "mox", "channel": 0, "key": 1},
+		{"type": "volume", "channel": 0, "key": 1},
+		{"type": "tune", "channel": 0, "key": 1},
+		{"type": "filter_width", "channel": 0, "key": 1, "options": {"control": "encoder"}}
+	]
+}`,
+			expected: []Problem{
+				{Line: 5, Column: 3, Message: "note channel 0 key 1 is already used by mox (see line 3)"},
+				{Line: 6, Column: 3, Message: "control change channel 0 key 1 is already used by volume (see line 4)"},
+			},
+		},
+		{
+			desc: "invalid MIDI sequences",
+			config: `{
+	"init_sequence": [[176, 0, 0], "note_on ch1 0x24 127", "delay 50ms"],
+	"connect_sequence": [[0, 1, 2]],
+	"disconnect_sequence": ["note_on ch16 0x24 127"],
+	"mappings": []
+}`,
+			expected: []Problem{
+				{Line: 3, Column: 23, Message: "invalid MIDI sequence: byte 0: 00 is not a status byte"},
+				{Line: 4, Column: 26, Message: "invalid MIDI sequence: invalid channel ch16, use ch0 to ch15"},
+			},
+		},
+		{
+			desc: "unknown device",
+			config: `{
+	"devices": [{"name": "a"}, {"name": "b"}],
+	"mappings": [
+		{"type": "mox", "device": "c", "channel": 0, "key": 1}
+	]
+}`,
+			expected: []Problem{{Line: 4, Column: 29, Message: "unknown device c"}},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, actual := Validate([]byte(tc.config))
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestValidate_TypeError(t *testing.T) {
+	_, actual := Validate([]byte("{\n\t\"mappings\": [\n\t\t{\"type\": \"mox\", \"key\": 200}\n\t]\n}"))
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, 3, actual[0].Line)
+		assert.Contains(t, actual[0].Message, "cannot unmarshal number 200")
+	}
+}
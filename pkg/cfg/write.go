@@ -0,0 +1,101 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+const indent = "    "
+
+// WriteFile writes the given configuration to the given file.
+func WriteFile(filename string, config Configuration) error {
+	var buffer bytes.Buffer
+	err := Write(&buffer, config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buffer.Bytes(), 0644)
+}
+
+// Write writes the given configuration as indented JSON. Each mapping is written in a single line. Mappings that
+// reference their control by name are written without channel and key.
+func Write(w io.Writer, config Configuration) error {
+	mappings := config.Mappings
+	config.Mappings = nil
+	data, err := json.MarshalIndent(config, "", indent)
+	if err != nil {
+		return err
+	}
+
+	var list bytes.Buffer
+	list.WriteString("[")
+	for i, mapping := range mappings {
+		line, err := marshalMapping(mapping)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			list.WriteString(",")
+		}
+		list.WriteString("\n" + indent + indent)
+		list.Write(spaced(line))
+	}
+	if len(mappings) > 0 {
+		list.WriteString("\n" + indent)
+	}
+	list.WriteString("]")
+
+	data = bytes.Replace(data, []byte(`"mappings": null`), append([]byte(`"mappings": `), list.Bytes()...), 1)
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
+
+// namedMapping is a mapping that references its control by name, the device profile defines the channel and the key.
+type namedMapping struct {
+	Type    ctrl.MappingType  `json:"type"`
+	Device  string            `json:"device,omitempty"`
+	Control string            `json:"control"`
+	TRX     int               `json:"trx"`
+	VFO     string            `json:"vfo,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+func marshalMapping(m ctrl.Mapping) ([]byte, error) {
+	if m.Control == "" {
+		return json.Marshal(m)
+	}
+	return json.Marshal(namedMapping{
+		Type:    m.Type,
+		Device:  m.Device,
+		Control: m.Control,
+		TRX:     m.TRX,
+		VFO:     m.VFO,
+		Options: m.Options,
+	})
+}
+
+// spaced adds a space after each colon and comma of the given compact JSON.
+func spaced(compact []byte) []byte {
+	result := make([]byte, 0, len(compact)+len(compact)/4)
+	inString := false
+	escaped := false
+	for _, b := range compact {
+		result = append(result, b)
+		switch {
+		case escaped:
+			escaped = false
+		case inString && b == '\\':
+			escaped = true
+		case b == '"':
+			inString = !inString
+		case !inString && (b == ':' || b == ','):
+			result = append(result, ' ')
+		}
+	}
+	return result
+}
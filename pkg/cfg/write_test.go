@@ -0,0 +1,47 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func TestWrite(t *testing.T) {
+	config := Configuration{
+		TCIAddress: "localhost:40001",
+		Mappings: []ctrl.Mapping{
+			{Type: ctrl.VFOMapping, Channel: 1, Key: 10, VFO: "VFOA", Options: map[string]string{"step": "10", "text": "a, b: \"c\""}},
+			{Type: ctrl.MOXMapping, Channel: 1, Key: 12},
+		},
+	}
+	expected := `{
+    "tci_address": "localhost:40001",
+    "mappings": [
+        {"type": "vfo", "channel": 1, "key": 10, "trx": 0, "vfo": "VFOA", "options": {"step": "10", "text": "a, b: \"c\""}},
+        {"type": "mox", "channel": 1, "key": 12, "trx": 0}
+    ]
+}
+`
+
+	var buffer bytes.Buffer
+	err := Write(&buffer, config)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buffer.String())
+
+	var parsed Configuration
+	err = json.Unmarshal(buffer.Bytes(), &parsed)
+	require.NoError(t, err)
+	assert.Equal(t, config, parsed)
+}
+
+func TestWrite_NoMappings(t *testing.T) {
+	var buffer bytes.Buffer
+	err := Write(&buffer, Configuration{})
+	require.NoError(t, err)
+	assert.Equal(t, "{\n    \"mappings\": []\n}\n", buffer.String())
+}
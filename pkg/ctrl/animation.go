@@ -0,0 +1,96 @@
+package ctrl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AnimationPattern string
+
+const (
+	NoAnimation    AnimationPattern = ""
+	BlinkAnimation AnimationPattern = "blink"
+	PulseAnimation AnimationPattern = "pulse"
+	ChaseAnimation AnimationPattern = "chase"
+)
+
+// Animation describes a pattern that is shown on a LED. A chase animation runs over the LED of the control and
+// the LEDs of the additional keys. If a color is set, the LED shows this color while it is on.
+type Animation struct {
+	Pattern AnimationPattern
+	Period  time.Duration
+	Color   *Color
+	Keys    []MidiKey
+}
+
+func (a Animation) Active() bool {
+	return a.Pattern != NoAnimation
+}
+
+// Animations contains the named animations that can be used in the mappings.
+var Animations = map[string]Animation{
+	"none":       {Pattern: NoAnimation},
+	"blink":      {Pattern: BlinkAnimation, Period: 500 * time.Millisecond},
+	"blink_fast": {Pattern: BlinkAnimation, Period: 250 * time.Millisecond},
+	"blink_slow": {Pattern: BlinkAnimation, Period: 1000 * time.Millisecond},
+	"pulse":      {Pattern: PulseAnimation, Period: 1200 * time.Millisecond},
+	"chase":      {Pattern: ChaseAnimation, Period: 800 * time.Millisecond},
+}
+
+// AnimationOption reads the animation that is shown instead of the flashing state from options["animation"].
+// The keys of a chase animation are given in options["chase_keys"] as comma separated list of keys on the
+// same channel.
+func (m Mapping) AnimationOption() (Animation, error) {
+	name := strings.ToLower(strings.TrimSpace(m.Options["animation"]))
+	if name == "" {
+		return Animation{}, nil
+	}
+	result, ok := Animations[name]
+	if !ok {
+		return Animation{}, fmt.Errorf("unknown animation %s", name)
+	}
+
+	period, set, err := Definitions[m.Type].OptionalIntOption(m, "animation_period")
+	if err != nil {
+		return Animation{}, err
+	}
+	if set {
+		result.Period = time.Duration(period) * time.Millisecond
+	}
+
+	if str, ok := m.Options["chase_keys"]; ok {
+		for _, field := range strings.Split(str, ",") {
+			key, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return Animation{}, fmt.Errorf("invalid chase key %s: %w", field, err)
+			}
+			result.Keys = append(result.Keys, MidiKey{Channel: m.Channel, Key: int8(key)})
+		}
+	}
+
+	return result, nil
+}
+
+func NewAnimatedLED(led LED, animation Animation) *AnimatedLED {
+	return &AnimatedLED{
+		LED:       led,
+		animation: animation,
+	}
+}
+
+// AnimatedLED shows the flashing state of a LED with an animation.
+type AnimatedLED struct {
+	LED
+	animation Animation
+}
+
+func (l *AnimatedLED) SetFlashing(key MidiKey, on bool) {
+	if on {
+		l.LED.SetAnimation(key, l.animation)
+		return
+	}
+	l.LED.SetAnimation(key, Animation{})
+	l.LED.SetFlashing(key, false)
+}
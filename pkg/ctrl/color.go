@@ -0,0 +1,180 @@
+package ctrl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Color struct {
+	R, G, B uint8
+}
+
+func (c Color) IsBlack() bool {
+	return c.R == 0 && c.G == 0 && c.B == 0
+}
+
+var (
+	Black  = Color{0x00, 0x00, 0x00}
+	White  = Color{0xff, 0xff, 0xff}
+	Red    = Color{0xff, 0x00, 0x00}
+	Green  = Color{0x00, 0xff, 0x00}
+	Blue   = Color{0x00, 0x00, 0xff}
+	Yellow = Color{0xff, 0xff, 0x00}
+	Orange = Color{0xff, 0x80, 0x00}
+	Cyan   = Color{0x00, 0xff, 0xff}
+	Purple = Color{0xff, 0x00, 0xff}
+)
+
+// Palette contains the named colors that can be used in the mappings. Besides the basic colors, it contains the colors
+// of the states that are indicated by the LEDs.
+var Palette = map[string]Color{
+	"off":    Black,
+	"black":  Black,
+	"white":  White,
+	"red":    Red,
+	"green":  Green,
+	"blue":   Blue,
+	"yellow": Yellow,
+	"orange": Orange,
+	"cyan":   Cyan,
+	"purple": Purple,
+
+	"tx":     Red,
+	"rx":     Green,
+	"mode":   Blue,
+	"active": Blue,
+	"cw":     Yellow,
+}
+
+// ParseColor parses a color given either as name from the palette or as hex value #rrggbb.
+func ParseColor(s string) (Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if color, ok := Palette[s]; ok {
+		return color, nil
+	}
+	if !strings.HasPrefix(s, "#") || len(s) != 7 {
+		return Black, fmt.Errorf("%s is not a valid color, use a name from the palette or #rrggbb", s)
+	}
+	value, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return Black, fmt.Errorf("%s is not a valid color: %w", s, err)
+	}
+	return Color{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value)}, nil
+}
+
+// LEDColors defines the colors of the LED states of a mapping. A nil color means that the state
+// is shown with the device's default behavior.
+type LEDColors struct {
+	On       *Color
+	Off      *Color
+	Flashing *Color
+}
+
+// DefaultLEDColors defines the color of the "on" state for mappings that indicate a specific state.
+var DefaultLEDColors = map[MappingType]string{
+	MOXMapping:      "tx",
+	TuneMapping:     "tx",
+	EnableRXMapping: "rx",
+	ModeMapping:     "mode",
+	SendCWMapping:   "cw",
+}
+
+// LEDColorsOption reads the colors of the LED states from options["color_on"], options["color_off"]
+// and options["color_flashing"].
+func (m Mapping) LEDColorsOption() (LEDColors, error) {
+	var result LEDColors
+	options := map[string]**Color{
+		"color_on":       &result.On,
+		"color_off":      &result.Off,
+		"color_flashing": &result.Flashing,
+	}
+	for name, color := range options {
+		str, ok := m.Options[name]
+		if !ok {
+			continue
+		}
+		value, err := ParseColor(str)
+		if err != nil {
+			return LEDColors{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*color = &value
+	}
+
+	if result.On == nil {
+		if str, ok := DefaultLEDColors[m.Type]; ok {
+			value := Palette[str]
+			result.On = &value
+		}
+	}
+
+	return result, nil
+}
+
+// MappingLED returns the LED that is used by the control of the given mapping.
+func MappingLED(m Mapping, led LED) (LED, error) {
+	colors, err := m.LEDColorsOption()
+	if err != nil {
+		return nil, err
+	}
+	animation, err := m.AnimationOption()
+	if err != nil {
+		return nil, err
+	}
+	touchKey, feedbackDelay, isFader, err := m.FaderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	result := led
+	if colors.On != nil || colors.Off != nil || colors.Flashing != nil {
+		result = NewColoredLED(result, colors)
+	}
+	if animation.Active() {
+		if colors.Flashing != nil {
+			animation.Color = colors.Flashing
+		} else {
+			animation.Color = colors.On
+		}
+		result = NewAnimatedLED(result, animation)
+	}
+	if isFader {
+		result = NewFaderLED(result, touchKey, feedbackDelay)
+	}
+	return result, nil
+}
+
+func NewColoredLED(led LED, colors LEDColors) *ColoredLED {
+	return &ColoredLED{
+		LED:    led,
+		colors: colors,
+	}
+}
+
+// ColoredLED shows the states of a LED with the configured colors.
+type ColoredLED struct {
+	LED
+	colors LEDColors
+}
+
+func (l *ColoredLED) SetOn(key MidiKey, on bool) {
+	switch {
+	case on && l.colors.On != nil:
+		l.LED.SetColor(key, *l.colors.On)
+	case !on && l.colors.Off != nil:
+		l.LED.SetColor(key, *l.colors.Off)
+	default:
+		l.LED.SetOn(key, on)
+	}
+}
+
+func (l *ColoredLED) SetFlashing(key MidiKey, on bool) {
+	switch {
+	case on && l.colors.Flashing != nil:
+		l.LED.SetColor(key, *l.colors.Flashing)
+	case !on && l.colors.Off != nil:
+		l.LED.SetColor(key, *l.colors.Off)
+	default:
+		l.LED.SetFlashing(key, on)
+	}
+}
@@ -14,19 +14,32 @@ type MidiKey struct {
 	Key     int8
 }
 
+// PitchbendKey is the key of controls that send pitch bend messages, e.g. motor faders.
+const PitchbendKey int8 = -1
+
+func (k MidiKey) IsPitchbend() bool {
+	return k.Key == PitchbendKey
+}
+
 type LED interface {
 	SetOn(key MidiKey, on bool)
 	SetFlashing(key MidiKey, on bool)
 	SetValue(key MidiKey, value uint8)
+	SetFineValue(key MidiKey, value uint16)
+	SetColor(key MidiKey, color Color)
+	SetAnimation(key MidiKey, animation Animation)
+	SetText(key MidiKey, text string)
 }
 
 type Mapping struct {
 	Type    MappingType       `json:"type"`
+	Device  string            `json:"device,omitempty"`
+	Control string            `json:"control,omitempty"`
 	Channel byte              `json:"channel"`
 	Key     int8              `json:"key"`
 	TRX     int               `json:"trx"`
-	VFO     string            `json:"vfo"`
-	Options map[string]string `json:"options"`
+	VFO     string            `json:"vfo,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
 }
 
 func (m Mapping) MidiKey() MidiKey {
@@ -36,68 +49,6 @@ func (m Mapping) MidiKey() MidiKey {
 	}
 }
 
-func (m Mapping) ValueControlOptions(defaultStepSize int) (controlType ControlType, stepSize int, reverseDirection bool, dynamicMode bool, err error) {
-	str := m.Options["control"]
-	switch strings.ToLower(str) {
-	case "poti":
-		controlType = PotiControl
-	case "encoder":
-		controlType = EncoderControl
-	default:
-		controlType = PotiControl
-	}
-
-	stepSize, err = m.IntOption("step", defaultStepSize)
-	if stepSize == 0 {
-		stepSize = defaultStepSize
-	}
-
-	str = m.Options["direction"]
-	reverseDirection = strings.ToLower(str) == "reverse"
-
-	str = m.Options["speed"]
-	dynamicMode = strings.ToLower(str) == "dynamic"
-
-	return
-}
-
-func (m Mapping) RequiredIntOption(name string) (int, bool, error) {
-	str, ok := m.Options[name]
-	if !ok {
-		return 0, false, nil
-	}
-	value, err := strconv.Atoi(str)
-	if err != nil {
-		return 0, false, err
-	}
-	return value, true, nil
-}
-
-func (m Mapping) IntOption(name string, defaultValue int) (int, error) {
-	value, set, err := m.RequiredIntOption(name)
-	if err != nil {
-		return 0, err
-	}
-	if !set {
-		return defaultValue, nil
-	}
-	return value, nil
-}
-
-func (m Mapping) BoolOption(name string, defaultValue bool) bool {
-	str, ok := m.Options[name]
-	if !ok {
-		return defaultValue
-	}
-	str = strings.ToLower(strings.TrimSpace(str))
-	switch str {
-	case "on", "true", "yes", "1":
-		return true
-	default:
-		return false
-	}
-}
-
 type MappingType string
 
 type ControlType int
@@ -107,8 +58,24 @@ const (
 	ButtonControl
 	PotiControl
 	EncoderControl
+	IndicatorControl
 )
 
+func (t ControlType) String() string {
+	switch t {
+	case ButtonControl:
+		return "button"
+	case PotiControl:
+		return "poti"
+	case EncoderControl:
+		return "encoder"
+	case IndicatorControl:
+		return "indicator"
+	default:
+		return "unknown"
+	}
+}
+
 type ValueRange interface {
 	Min() int
 	Max() int
@@ -167,12 +134,56 @@ func Project(r ValueRange, value int) uint8 {
 	if value > r.Max() {
 		return 0x7f
 	}
-	p := uint8(math.Ceil(float64(value-r.Min()) / RangeTick(r)))
-	return p
+	p := math.Ceil(float64(value-r.Min()) / RangeTick(r))
+	return uint8(math.Min(p, 0x7f))
+}
+
+// fineResolution is the maximum 14-bit value, e.g. of a pitch bend message.
+const fineResolution = 0x3fff
+
+// TranslateFine translates a 14-bit value into the given range.
+func TranslateFine(r ValueRange, value uint16) int {
+	if r.Infinite() {
+		return int(value)
+	}
+	return TrimToRange(r, r.Min()+int(math.Round(float64(value)*float64(r.Max()-r.Min())/fineResolution)))
+}
+
+// ProjectFine projects the given value onto 14 bits.
+func ProjectFine(r ValueRange, value int) uint16 {
+	if r.Infinite() {
+		return uint16(value)
+	}
+	if value < r.Min() {
+		return 0
+	}
+	if value > r.Max() {
+		return fineResolution
+	}
+	return uint16(math.Round(float64(value-r.Min()) * fineResolution / float64(r.Max()-r.Min())))
+}
+
+// ShortValue formats the given value with at most seven characters, large values are shown in k or M.
+func ShortValue(value int) string {
+	abs := value
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= 10000000:
+		return fmt.Sprintf("%.2fM", float64(value)/1000000)
+	case abs >= 1000000:
+		return fmt.Sprintf("%.3fM", float64(value)/1000000)
+	case abs >= 100000:
+		return fmt.Sprintf("%.1fk", float64(value)/1000)
+	default:
+		return strconv.Itoa(value)
+	}
 }
 
 type ValueControl interface {
 	Changed(int)
+	ChangedFine(int)
 	SetActiveValue(value int)
 	Close()
 }
@@ -187,8 +198,6 @@ func NewValueControl(key MidiKey, controlType ControlType, set func(int), valueR
 
 type ControlFactory func(Mapping, LED, *client.Client) (any, ControlType, error)
 
-var Factories = make(map[MappingType]ControlFactory)
-
 func AtoVFO(a string) (client.VFO, error) {
 	switch strings.ToUpper(a) {
 	case "A", "VFOA":
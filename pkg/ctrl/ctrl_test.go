@@ -2,8 +2,10 @@ package ctrl
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTranslate(t *testing.T) {
@@ -107,6 +109,12 @@ func TestProject(t *testing.T) {
 			value:    49,
 			expected: 0x7f,
 		},
+		{
+			desc:     "end of a wide range",
+			r:        StaticRange{-100, 100},
+			value:    100,
+			expected: 0x7f,
+		},
 		{
 			desc:     "below",
 			r:        StaticRange{-50, 50},
@@ -133,3 +141,156 @@ func TestProject(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslateFineAndProjectFine(t *testing.T) {
+	tt := []struct {
+		desc  string
+		r     ValueRange
+		value int
+		fine  uint16
+	}{
+		{
+			desc:  "begin",
+			r:     StaticRange{-50, 50},
+			value: -50,
+			fine:  0,
+		},
+		{
+			desc:  "center",
+			r:     StaticRange{0, 100},
+			value: 50,
+			fine:  0x2000,
+		},
+		{
+			desc:  "end",
+			r:     StaticRange{-50, 50},
+			value: 50,
+			fine:  0x3fff,
+		},
+		{
+			desc:  "infinite",
+			r:     InfiniteRange{},
+			value: 1234,
+			fine:  1234,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.fine, ProjectFine(tc.r, tc.value))
+			assert.Equal(t, tc.value, TranslateFine(tc.r, tc.fine))
+		})
+	}
+}
+
+func TestShortValue(t *testing.T) {
+	tt := []struct {
+		value    int
+		expected string
+	}{
+		{0, "0"},
+		{-250, "-250"},
+		{99999, "99999"},
+		{123456, "123.5k"},
+		{-123456, "-123.5k"},
+		{7074000, "7.074M"},
+		{14074000, "14.07M"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ShortValue(tc.value))
+		})
+	}
+}
+
+func TestDecodeEncoderTurns(t *testing.T) {
+	tt := []struct {
+		desc     string
+		encoding EncoderEncoding
+		value    uint8
+		expected int
+	}{
+		{"offset right", OffsetEncoding, 0x41, 1},
+		{"offset left", OffsetEncoding, 0x3e, -2},
+		{"default", "", 0x43, 3},
+		{"sign magnitude right", SignMagnitudeEncoding, 0x02, 2},
+		{"sign magnitude left", SignMagnitudeEncoding, 0x41, -1},
+		{"twos complement right", TwosComplementEncoding, 0x01, 1},
+		{"twos complement left", TwosComplementEncoding, 0x7e, -2},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, DecodeEncoderTurns(tc.encoding, tc.value))
+		})
+	}
+}
+
+func TestClosedValueControlsIgnoreValues(t *testing.T) {
+	valueRange := StaticRange{0, 100}
+	poti := NewPoti(MidiKey{Channel: 1, Key: 1}, func(int) {}, valueRange, nil)
+	encoder := NewEncoder(MidiKey{Channel: 1, Key: 2}, func(int) {}, valueRange, nil, 1, false, false)
+	poti.Close()
+	encoder.Close()
+
+	assert.NotPanics(t, func() {
+		poti.SetActiveValue(10)
+		poti.Changed(20)
+		poti.ChangedFine(0x2000)
+		encoder.SetActiveValue(10)
+		encoder.Changed(1)
+		encoder.ChangedFine(0x2080)
+		poti.Close()
+		encoder.Close()
+	})
+}
+
+type volumeRecorder chan int
+
+func (r volumeRecorder) SetVolume(dB int) error {
+	r <- dB
+	return nil
+}
+
+func TestPitchbendReachesValueControls(t *testing.T) {
+	volume := make(volumeRecorder, 1)
+	control := NewVolumeControl(MidiKey{Channel: 1, Key: PitchbendKey}, PotiControl, nil, 1, false, false, volume)
+	defer control.Close()
+
+	control.ChangedFine(0x1000)
+
+	select {
+	case actual := <-volume:
+		assert.Equal(t, TranslateFine(StaticRange{-60, 0}, 0x1000), actual)
+	case <-time.After(time.Second):
+		t.Fatal("the pitch bend did not reach the value control")
+	}
+}
+
+func TestMappingLED_AnimationOnlyWhenConfigured(t *testing.T) {
+	tt := []struct {
+		desc     string
+		mapping  Mapping
+		animated bool
+	}{
+		{
+			desc:    "mox without animation",
+			mapping: Mapping{Type: MOXMapping},
+		},
+		{
+			desc:    "send_cw without animation",
+			mapping: Mapping{Type: SendCWMapping},
+		},
+		{
+			desc:     "tune with animation",
+			mapping:  Mapping{Type: TuneMapping, Options: map[string]string{"animation": "pulse"}},
+			animated: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			led, err := MappingLED(tc.mapping, nil)
+			require.NoError(t, err)
+			_, animated := led.(*AnimatedLED)
+			assert.Equal(t, tc.animated, animated)
+		})
+	}
+}
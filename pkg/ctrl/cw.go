@@ -1,7 +1,10 @@
 package ctrl
 
 import (
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/ftl/tci/client"
 )
@@ -12,39 +15,93 @@ const (
 	CWSpeedMapping MappingType = "cw_speed"
 )
 
+const (
+	defaultCWSpeed = 20
+
+	// cwPTTTimeout is the maximum time to wait for the end of the transmission after the estimated duration of a message.
+	cwPTTTimeout = 5 * time.Second
+)
+
+var cwSpeedRange = StaticRange{5, 50}
+
 func init() {
-	Factories[SendCWMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		text := m.Options["text"]
-		return NewSendCWButton(m.MidiKey(), m.TRX, led, text, tciClient), ButtonControl, nil
-	}
-	Factories[StopCWMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		return NewStopCWButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
-	}
-	Factories[CWSpeedMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewCWSpeedControl(m.MidiKey(), controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
-	}
+	Register(Definition{
+		Type:        SendCWMapping,
+		Description: "Sends a CW macro. The text may contain variables in curly braces.",
+		Control:     ButtonControl,
+		Options: []OptionSchema{
+			{Name: "text", Type: StringOptionType, Description: "the text of the CW macro"},
+			{Name: "queue", Type: StringOptionType, Description: "append the macro to the CW queue or replace the queue", Default: "append", Values: []string{"append", "replace"}},
+			{Name: "repeat", Type: DurationOptionType, Description: "repeat the macro with this interval until the button is pressed again, e.g. 5s"},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			text := m.Options["text"]
+
+			queue, err := Definitions[m.Type].StringOption(m, "queue")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			replace := queue == "replace"
+
+			var repeat time.Duration
+			if str, ok := m.Options["repeat"]; ok {
+				repeat, err = time.ParseDuration(str)
+				if err != nil {
+					return nil, ButtonControl, fmt.Errorf("invalid repeat interval: %w", err)
+				}
+			}
+
+			sender := CWSenderFor(m.TRX, tciClient)
+			button := NewSendCWButton(m.MidiKey(), m.TRX, led, text, replace, repeat, sender)
+			sender.Notify(button)
+			return button, ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:        StopCWMapping,
+		Description: "Stops the current CW transmission and clears the CW macro queue.",
+		Control:     ButtonControl,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			sender := CWSenderFor(m.TRX, tciClient)
+			button := NewStopCWButton(m.MidiKey(), m.TRX, led, sender)
+			sender.Notify(button)
+			return button, ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         CWSpeedMapping,
+		Description:  "Controls the CW keyer speed in WPM.",
+		ValueControl: true,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewCWSpeedControl(m.MidiKey(), controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
+		},
+	})
 }
 
-func NewSendCWButton(key MidiKey, trx int, led LED, text string, controller CWController) *SendCWButton {
+func NewSendCWButton(key MidiKey, trx int, led LED, text string, replace bool, repeat time.Duration, sender *CWSender) *SendCWButton {
 	return &SendCWButton{
-		key:        key,
-		trx:        trx,
-		led:        led,
-		text:       text,
-		controller: controller,
+		key:     key,
+		trx:     trx,
+		led:     led,
+		text:    text,
+		replace: replace,
+		repeat:  repeat,
+		sender:  sender,
 	}
 }
 
 type SendCWButton struct {
-	key        MidiKey
-	trx        int
-	led        LED
-	text       string
-	controller CWController
+	key     MidiKey
+	trx     int
+	led     LED
+	text    string
+	replace bool
+	repeat  time.Duration
+	sender  *CWSender
 }
 
 type CWController interface {
@@ -54,33 +111,51 @@ type CWController interface {
 }
 
 func (b *SendCWButton) Pressed() {
-	err := b.controller.SendCWMessage(b.trx, b.text, "", "")
-	if err != nil {
-		log.Print(err)
+	b.sender.Send(b, b.text, b.repeat, b.replace)
+}
+
+// Close stops the notifications of the CW sender.
+func (b *SendCWButton) Close() {
+	b.sender.Remove(b)
+}
+
+func (b *SendCWButton) SetCWSending(trx int, source any, pending bool) {
+	if trx != b.trx {
+		return
 	}
+	b.led.SetFlashing(b.key, source == b)
 }
 
-func NewStopCWButton(key MidiKey, trx int, led LED, controller CWController) *StopCWButton {
+func NewStopCWButton(key MidiKey, trx int, led LED, sender *CWSender) *StopCWButton {
 	return &StopCWButton{
-		key:        key,
-		trx:        trx,
-		led:        led,
-		controller: controller,
+		key:    key,
+		trx:    trx,
+		led:    led,
+		sender: sender,
 	}
 }
 
 type StopCWButton struct {
-	key        MidiKey
-	trx        int
-	led        LED
-	controller CWController
+	key    MidiKey
+	trx    int
+	led    LED
+	sender *CWSender
 }
 
 func (b *StopCWButton) Pressed() {
-	err := b.controller.StopCW()
-	if err != nil {
-		log.Print(err)
+	b.sender.Stop()
+}
+
+// Close stops the notifications of the CW sender.
+func (b *StopCWButton) Close() {
+	b.sender.Remove(b)
+}
+
+func (b *StopCWButton) SetCWSending(trx int, source any, pending bool) {
+	if trx != b.trx {
+		return
 	}
+	b.led.SetOn(b.key, pending)
 }
 
 func NewCWSpeedControl(key MidiKey, controlType ControlType, led LED, stepSize int, reverseDirection bool, dynamicMode bool, controller CWController) *CWSpeedControl {
@@ -90,10 +165,8 @@ func NewCWSpeedControl(key MidiKey, controlType ControlType, led LED, stepSize i
 			log.Printf("Cannot change RX balance: %v", err)
 		}
 	}
-	valueRange := StaticRange{5, 50}
-
 	return &CWSpeedControl{
-		ValueControl: NewValueControl(key, controlType, set, valueRange, led, stepSize, reverseDirection, dynamicMode),
+		ValueControl: NewValueControl(key, controlType, set, cwSpeedRange, led, stepSize, reverseDirection, dynamicMode),
 	}
 }
 
@@ -108,3 +181,307 @@ type CWSpeedController interface {
 func (s *CWSpeedControl) SetCWMacrosSpeed(wpm int) {
 	s.ValueControl.SetActiveValue(wpm)
 }
+
+var (
+	cwSendersLock sync.Mutex
+	cwSenders     = make(map[int]*CWSender)
+)
+
+// CWSenderFor returns the CW sender of the given TRX. All macro buttons of one TRX share the same sender,
+// so that they can use a common queue.
+func CWSenderFor(trx int, tciClient *client.Client) *CWSender {
+	cwSendersLock.Lock()
+	defer cwSendersLock.Unlock()
+
+	sender, ok := cwSenders[trx]
+	if ok {
+		return sender
+	}
+	sender = NewCWSender(trx, MacroVariables, tciClient)
+	tciClient.Notify(sender)
+	cwSenders[trx] = sender
+	return sender
+}
+
+func NewCWSender(trx int, variables *CWVariables, controller CWController) *CWSender {
+	result := &CWSender{
+		trx:        trx,
+		variables:  variables,
+		controller: controller,
+		commands:   make(chan func()),
+		closed:     make(chan struct{}),
+		wpm:        defaultCWSpeed,
+	}
+
+	go func() {
+		for {
+			select {
+			case <-result.closed:
+				return
+			case command := <-result.commands:
+				command()
+				result.emitState()
+			}
+		}
+	}()
+
+	return result
+}
+
+// CWSendingListener is notified when the CW sender starts or finishes to send a message.
+// The source is the origin of the message that is currently sent or nil, pending is true as long
+// as any message is sent or queued.
+type CWSendingListener interface {
+	SetCWSending(trx int, source any, pending bool)
+}
+
+// CWSender sends the expanded CW macros one after the other. The state of the sender is only
+// accessed from within the sender's goroutine.
+type CWSender struct {
+	trx        int
+	variables  *CWVariables
+	controller CWController
+	commands   chan func()
+	closed     chan struct{}
+
+	listeners []CWSendingListener
+
+	wpm           int
+	baseWPM       int
+	speedChanged  bool
+	ptt           bool
+	waitingForPTT bool
+	queue         []cwMessage
+	current       *cwMessage
+	segments      []cwSegment
+	beacon        bool
+	generation    int
+
+	lastSource  any
+	lastPending bool
+}
+
+type cwMessage struct {
+	source any
+	text   string
+	repeat time.Duration
+	serial string // the serial number of the first transmission, the repetitions send the same number
+}
+
+func (s *CWSender) Close() {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
+func (s *CWSender) do(command func()) {
+	select {
+	case s.commands <- command:
+	case <-s.closed:
+	}
+}
+
+// Send enqueues the given macro text. If replace is true, the currently sent message and all queued messages
+// are dropped. Sending a repeated message again while it is active stops the repetition.
+func (s *CWSender) Send(source any, text string, repeat time.Duration, replace bool) {
+	s.do(func() {
+		if repeat > 0 && s.current != nil && s.current.source == source {
+			s.stop()
+			return
+		}
+
+		if replace {
+			s.stop()
+		} else if s.beacon {
+			s.current = nil
+			s.beacon = false
+			s.generation++
+		}
+		s.queue = append(s.queue, cwMessage{source: source, text: text, repeat: repeat})
+		if s.current == nil {
+			s.startNext()
+		}
+	})
+}
+
+func (s *CWSender) Stop() {
+	s.do(s.stop)
+}
+
+func (s *CWSender) Notify(listener CWSendingListener) {
+	s.do(func() {
+		s.listeners = append(s.listeners, listener)
+		listener.SetCWSending(s.trx, s.lastSource, s.lastPending)
+	})
+}
+
+// Remove stops the notifications of the given listener.
+func (s *CWSender) Remove(listener CWSendingListener) {
+	s.do(func() {
+		for i, l := range s.listeners {
+			if l == listener {
+				s.listeners = append(s.listeners[:i:i], s.listeners[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+func (s *CWSender) SetCWMacrosSpeed(wpm int) {
+	s.do(func() {
+		s.wpm = wpm
+	})
+}
+
+// SetTX is used to detect the end of a message more precisely than the estimated duration allows.
+func (s *CWSender) SetTX(trx int, ptt bool) {
+	if trx != s.trx {
+		return
+	}
+	s.do(func() {
+		s.ptt = ptt
+		if !ptt && s.waitingForPTT {
+			s.generation++
+			s.finish()
+		}
+	})
+}
+
+func (s *CWSender) emitState() {
+	var source any
+	if s.current != nil {
+		source = s.current.source
+	}
+	pending := s.current != nil || len(s.queue) > 0
+	if source == s.lastSource && pending == s.lastPending {
+		return
+	}
+	s.lastSource = source
+	s.lastPending = pending
+
+	for _, listener := range s.listeners {
+		listener.SetCWSending(s.trx, source, pending)
+	}
+}
+
+func (s *CWSender) stop() {
+	sending := s.current != nil && !s.beacon
+	s.queue = nil
+	s.current = nil
+	s.segments = nil
+	s.beacon = false
+	s.waitingForPTT = false
+	s.generation++
+
+	if sending {
+		err := s.controller.StopCW()
+		if err != nil {
+			log.Print(err)
+		}
+	}
+	s.restoreSpeed()
+}
+
+func (s *CWSender) startNext() {
+	if len(s.queue) == 0 {
+		return
+	}
+	message := s.queue[0]
+	s.queue = s.queue[1:]
+	s.start(message)
+}
+
+func (s *CWSender) start(message cwMessage) {
+	serial := message.serial
+	if serial == "" {
+		serial, _ = s.variables.Get(SerialVariable)
+	}
+	segments, usesSerial := expandCWMacro(message.text, s.variables, s.wpm, serial)
+	if usesSerial && message.serial == "" {
+		message.serial = serial
+		s.variables.IncrementSerial()
+	}
+
+	s.current = &message
+	s.segments = segments
+	s.beacon = false
+	s.baseWPM = s.wpm
+	s.sendSegment()
+}
+
+func (s *CWSender) sendSegment() {
+	if len(s.segments) == 0 && s.ptt {
+		s.waitingForPTT = true
+		s.schedule(cwPTTTimeout, s.finish)
+		return
+	}
+	if len(s.segments) == 0 {
+		s.finish()
+		return
+	}
+	segment := s.segments[0]
+	s.segments = s.segments[1:]
+
+	if segment.wpm > 0 && segment.wpm != s.wpm {
+		err := s.controller.SetCWMacrosSpeed(segment.wpm)
+		if err != nil {
+			log.Printf("Cannot change CW speed: %v", err)
+		}
+		s.wpm = segment.wpm
+		s.speedChanged = true
+	}
+
+	err := s.controller.SendCWMessage(s.trx, segment.text, "", "")
+	if err != nil {
+		log.Print(err)
+	}
+	s.schedule(CWDuration(segment.text, s.wpm), s.sendSegment)
+}
+
+func (s *CWSender) finish() {
+	s.waitingForPTT = false
+	if s.current == nil {
+		return
+	}
+	s.restoreSpeed()
+
+	message := s.current
+	if message.repeat > 0 && len(s.queue) == 0 {
+		s.beacon = true
+		s.schedule(message.repeat, func() {
+			s.start(*message)
+		})
+		return
+	}
+
+	s.current = nil
+	s.startNext()
+}
+
+func (s *CWSender) restoreSpeed() {
+	if !s.speedChanged {
+		return
+	}
+	err := s.controller.SetCWMacrosSpeed(s.baseWPM)
+	if err != nil {
+		log.Printf("Cannot change CW speed: %v", err)
+	}
+	s.wpm = s.baseWPM
+	s.speedChanged = false
+}
+
+// schedule executes the given command after the given delay, as long as nothing else happened in the meantime.
+func (s *CWSender) schedule(delay time.Duration, command func()) {
+	s.generation++
+	generation := s.generation
+	time.AfterFunc(delay, func() {
+		s.do(func() {
+			if generation != s.generation {
+				return
+			}
+			command()
+		})
+	})
+}
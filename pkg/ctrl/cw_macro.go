@@ -0,0 +1,261 @@
+package ctrl
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	SerialVariable     = "nr"
+	LastSerialVariable = "last_nr"
+
+	defaultRST = "599"
+)
+
+// MacroVariables holds the variables that are used to expand the CW macros.
+var MacroVariables = NewCWVariables()
+
+func NewCWVariables() *CWVariables {
+	return &CWVariables{
+		values: map[string]string{
+			"rst": defaultRST,
+		},
+		serial: 1,
+	}
+}
+
+type CWVariables struct {
+	mutex      sync.RWMutex
+	values     map[string]string
+	serial     int
+	serialFile string
+}
+
+// LoadSerial reads the serial number from the given file and persists every change of the serial number
+// to this file. A missing file is not an error, the serial number then starts at its current value.
+func (v *CWVariables) LoadSerial(filename string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.serialFile = filename
+	bytes, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	serial, err := strconv.Atoi(strings.TrimSpace(string(bytes)))
+	if err != nil {
+		return fmt.Errorf("invalid serial number in %s: %w", filename, err)
+	}
+	v.serial = serial
+	return nil
+}
+
+func (v *CWVariables) Set(name string, value string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf("the variable name must not be empty")
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if name == LastSerialVariable {
+		return fmt.Errorf("the variable %s cannot be set", name)
+	}
+	if name == SerialVariable {
+		serial, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid serial number: %w", err)
+		}
+		v.serial = serial
+		return v.storeSerial()
+	}
+
+	v.values[name] = strings.TrimSpace(value)
+	return nil
+}
+
+func (v *CWVariables) Get(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	switch name {
+	case SerialVariable:
+		return fmt.Sprintf("%03d", v.serial), true
+	case LastSerialVariable:
+		return fmt.Sprintf("%03d", v.serial-1), true
+	}
+	value, ok := v.values[name]
+	return value, ok
+}
+
+func (v *CWVariables) Names() []string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	result := make([]string, 0, len(v.values)+2)
+	result = append(result, SerialVariable, LastSerialVariable)
+	for name := range v.values {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (v *CWVariables) IncrementSerial() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.serial++
+	err := v.storeSerial()
+	if err != nil {
+		log.Printf("Cannot store the serial number: %v", err)
+	}
+}
+
+func (v *CWVariables) storeSerial() error {
+	if v.serialFile == "" {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(v.serialFile), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(v.serialFile, []byte(strconv.Itoa(v.serial)+"\n"), 0o644)
+}
+
+type cwSegment struct {
+	text string
+	wpm  int
+}
+
+// expandCWMacro replaces the variables in the given macro text and splits the text into segments
+// at each speed change. Variables are written as {name}, speed changes as {wpm:28}, {wpm:+5} or {wpm:-5}.
+// If serial is not empty, it is used for {nr} instead of the current serial number.
+func expandCWMacro(macro string, variables *CWVariables, wpm int, serial string) (segments []cwSegment, usesSerial bool) {
+	current := cwSegment{wpm: wpm}
+	text := &strings.Builder{}
+	flush := func() {
+		current.text = text.String()
+		if strings.TrimSpace(current.text) != "" {
+			segments = append(segments, current)
+		}
+		text.Reset()
+	}
+
+	for len(macro) > 0 {
+		open := strings.IndexByte(macro, '{')
+		if open == -1 {
+			text.WriteString(macro)
+			break
+		}
+		text.WriteString(macro[:open])
+		macro = macro[open+1:]
+
+		end := strings.IndexByte(macro, '}')
+		if end == -1 {
+			log.Printf("unterminated variable in CW macro: {%s", macro)
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(macro[:end]))
+		macro = macro[end+1:]
+
+		if strings.HasPrefix(name, "wpm:") {
+			nextWPM, err := parseWPMChange(strings.TrimPrefix(name, "wpm:"), current.wpm)
+			if err != nil {
+				log.Printf("invalid speed change in CW macro: %v", err)
+				continue
+			}
+			flush()
+			current.wpm = nextWPM
+			continue
+		}
+
+		if name == SerialVariable {
+			usesSerial = true
+			if serial != "" {
+				text.WriteString(serial)
+				continue
+			}
+		}
+		value, ok := variables.Get(name)
+		if !ok {
+			log.Printf("unknown variable in CW macro: %s", name)
+		}
+		text.WriteString(value)
+	}
+	flush()
+
+	return segments, usesSerial
+}
+
+func parseWPMChange(s string, wpm int) (int, error) {
+	s = strings.TrimSpace(s)
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		value += wpm
+	}
+	return TrimToRange(cwSpeedRange, value), nil
+}
+
+var morseCode = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.", 'G': "--.", 'H': "....",
+	'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..", 'M': "--", 'N': "-.", 'O': "---", 'P': ".--.",
+	'Q': "--.-", 'R': ".-.", 'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+	'.': ".-.-.-", ',': "--..--", '?': "..--..", '/': "-..-.", '=': "-...-", '+': ".-.-.",
+	'-': "-....-", '@': ".--.-.", '\'': ".----.", ':': "---...", '(': "-.--.", ')': "-.--.-",
+}
+
+// CWDuration estimates how long it takes to send the given text at the given speed, based on the PARIS timing.
+func CWDuration(text string, wpm int) time.Duration {
+	if wpm <= 0 {
+		return 0
+	}
+	units := 0
+	gap := 0
+	for _, r := range strings.ToUpper(text) {
+		if unicode.IsSpace(r) {
+			gap = 7
+			continue
+		}
+		code, ok := morseCode[r]
+		if !ok {
+			continue
+		}
+		if units > 0 {
+			units += gap
+		}
+		for i, element := range code {
+			if i > 0 {
+				units++
+			}
+			if element == '.' {
+				units++
+			} else {
+				units += 3
+			}
+		}
+		gap = 3
+	}
+	unit := 1200 * float64(time.Millisecond) / float64(wpm)
+	return time.Duration(float64(units) * unit)
+}
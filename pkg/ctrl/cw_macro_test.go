@@ -0,0 +1,137 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandCWMacro(t *testing.T) {
+	variables := NewCWVariables()
+	variables.Set("mycall", "DL0ABC")
+	variables.Set("call", "DL1XYZ")
+	variables.Set("nr", "7")
+
+	tt := []struct {
+		desc       string
+		macro      string
+		expected   []cwSegment
+		usesSerial bool
+	}{
+		{
+			desc:     "plain text",
+			macro:    "cq test",
+			expected: []cwSegment{{text: "cq test", wpm: 20}},
+		},
+		{
+			desc:     "variables",
+			macro:    "{call} {RST} de {mycall}",
+			expected: []cwSegment{{text: "DL1XYZ 599 de DL0ABC", wpm: 20}},
+		},
+		{
+			desc:       "serial",
+			macro:      "{rst} {nr}",
+			expected:   []cwSegment{{text: "599 007", wpm: 20}},
+			usesSerial: true,
+		},
+		{
+			desc:     "last serial",
+			macro:    "agn {last_nr}",
+			expected: []cwSegment{{text: "agn 006", wpm: 20}},
+		},
+		{
+			desc:     "unknown variable",
+			macro:    "tu {unknown}",
+			expected: []cwSegment{{text: "tu ", wpm: 20}},
+		},
+		{
+			desc:  "speed changes",
+			macro: "{call} {wpm:+6}5nn{wpm:20} tu",
+			expected: []cwSegment{
+				{text: "DL1XYZ ", wpm: 20},
+				{text: "5nn", wpm: 26},
+				{text: " tu", wpm: 20},
+			},
+		},
+		{
+			desc:     "speed out of range",
+			macro:    "{wpm:99}test",
+			expected: []cwSegment{{text: "test", wpm: 50}},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, usesSerial := expandCWMacro(tc.macro, variables, 20, "")
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.usesSerial, usesSerial)
+		})
+	}
+}
+
+type cwRecorder struct {
+	texts []string
+}
+
+func (r *cwRecorder) SendCWMessage(trx int, text string, _, _ string) error {
+	r.texts = append(r.texts, text)
+	return nil
+}
+
+func (r *cwRecorder) StopCW() error                  { return nil }
+func (r *cwRecorder) SetCWMacrosSpeed(wpm int) error { return nil }
+
+func TestCWSender_SerialOnlyIncrementedOnce(t *testing.T) {
+	variables := NewCWVariables()
+	recorder := &cwRecorder{}
+	sender := NewCWSender(1, variables, recorder)
+	defer sender.Close()
+
+	done := make(chan struct{})
+	sender.do(func() {
+		sender.start(cwMessage{text: "{nr}", repeat: time.Hour})
+		repetition := *sender.current
+		sender.start(repetition)
+		sender.start(cwMessage{text: "{nr}"})
+		close(done)
+	})
+	<-done
+
+	assert.Equal(t, []string{"001", "001", "002"}, recorder.texts)
+	serial, _ := variables.Get(SerialVariable)
+	assert.Equal(t, "003", serial)
+}
+
+func TestCWDuration(t *testing.T) {
+	tt := []struct {
+		desc     string
+		text     string
+		wpm      int
+		expected time.Duration
+	}{
+		{
+			desc:     "PARIS",
+			text:     "paris",
+			wpm:      20,
+			expected: 43 * 60 * time.Millisecond,
+		},
+		{
+			desc:     "two words",
+			text:     "e e",
+			wpm:      12,
+			expected: 9 * 100 * time.Millisecond,
+		},
+		{
+			desc:     "no speed",
+			text:     "paris",
+			wpm:      0,
+			expected: 0,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual := CWDuration(tc.text, tc.wpm)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
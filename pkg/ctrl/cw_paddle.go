@@ -0,0 +1,338 @@
+package ctrl
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ftl/tci/client"
+)
+
+const (
+	CWPaddleMapping MappingType = "cw_paddle"
+
+	defaultKeyerWeight = 50
+)
+
+func init() {
+	Register(Definition{
+		Type:        CWPaddleMapping,
+		Description: "Keys CW with a paddle or a straight key.",
+		Control:     ButtonControl,
+		Options: []OptionSchema{
+			{Name: "paddle", Type: StringOptionType, Description: "the paddle or key that is simulated", Required: true, Values: []string{"dit", "dah", "straight"}, Aliases: map[string]string{"dot": "dit", "dash": "dah", "key": "straight"}},
+			{Name: "keyer", Type: StringOptionType, Description: "the mode of the iambic keyer", Default: "iambic_b", Values: []string{"iambic_a", "iambic_b"}, Aliases: map[string]string{"a": "iambic_a", "b": "iambic_b"}},
+			{Name: "weight", Type: IntOptionType, Description: "the weight of the keyer in percent", Range: &[2]int{25, 75}},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			definition := Definitions[m.Type]
+			var paddle cwPaddle
+			name, err := definition.StringOption(m, "paddle")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			switch name {
+			case "dit":
+				paddle = ditPaddle
+			case "dah":
+				paddle = dahPaddle
+			case "straight":
+				paddle = straightKey
+			default:
+				return nil, ButtonControl, fmt.Errorf("no paddle configured. Use options[\"paddle\"]=\"<dit|dah|straight>\" to configure the paddle")
+			}
+
+			keyer := CWKeyerFor(m.TRX, tciClient)
+
+			// the keyer is shared by all paddles of the TRX, only the explicitly configured settings are applied
+			if _, ok := m.Options["keyer"]; ok {
+				str, err := definition.StringOption(m, "keyer")
+				if err != nil {
+					return nil, ButtonControl, err
+				}
+				mode, err := parseKeyerMode(str)
+				if err != nil {
+					return nil, ButtonControl, err
+				}
+				keyer.SetMode(mode)
+			}
+
+			weight, set, err := definition.OptionalIntOption(m, "weight")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			if set {
+				keyer.SetWeight(weight)
+			}
+
+			return NewCWPaddleButton(m.MidiKey(), m.TRX, led, paddle, keyer), ButtonControl, nil
+		},
+	})
+}
+
+type cwPaddle int
+
+const (
+	straightKey cwPaddle = iota
+	ditPaddle
+	dahPaddle
+)
+
+type KeyerMode int
+
+const (
+	IambicB KeyerMode = iota
+	IambicA
+)
+
+func parseKeyerMode(s string) (KeyerMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "iambic_a", "a":
+		return IambicA, nil
+	case "iambic_b", "b", "":
+		return IambicB, nil
+	default:
+		return 0, fmt.Errorf("invalid keyer mode %q, use iambic_a or iambic_b", s)
+	}
+}
+
+func NewCWPaddleButton(key MidiKey, trx int, led LED, paddle cwPaddle, keyer *CWKeyer) *CWPaddleButton {
+	return &CWPaddleButton{
+		key:    key,
+		trx:    trx,
+		led:    led,
+		paddle: paddle,
+		keyer:  keyer,
+	}
+}
+
+type CWPaddleButton struct {
+	key    MidiKey
+	trx    int
+	led    LED
+	paddle cwPaddle
+	keyer  *CWKeyer
+}
+
+func (b *CWPaddleButton) Pressed() {
+	b.keyer.SetPaddle(b.paddle, true)
+}
+
+func (b *CWPaddleButton) Released() {
+	b.keyer.SetPaddle(b.paddle, false)
+}
+
+var (
+	cwKeyersLock sync.Mutex
+	cwKeyers     = make(map[int]*CWKeyer)
+)
+
+// CWKeyerFor returns the keyer of the given TRX. The dit and dah paddles of one TRX share the same keyer.
+func CWKeyerFor(trx int, tciClient *client.Client) *CWKeyer {
+	cwKeyersLock.Lock()
+	defer cwKeyersLock.Unlock()
+
+	keyer, ok := cwKeyers[trx]
+	if ok {
+		return keyer
+	}
+	keyer = NewCWKeyer(trx, tciClient)
+	tciClient.Notify(keyer)
+	cwKeyers[trx] = keyer
+	return keyer
+}
+
+type CWKeyController interface {
+	SetCWKeyer(trx int, down bool) error
+}
+
+func NewCWKeyer(trx int, controller CWKeyController) *CWKeyer {
+	result := &CWKeyer{
+		trx:        trx,
+		controller: controller,
+		commands:   make(chan func()),
+		closed:     make(chan struct{}),
+		wpm:        defaultCWSpeed,
+		weight:     defaultKeyerWeight,
+	}
+
+	go func() {
+		for {
+			select {
+			case <-result.closed:
+				return
+			case command := <-result.commands:
+				command()
+			}
+		}
+	}()
+
+	return result
+}
+
+// CWKeyer is an iambic keyer that generates the key down and key up events for the TRX. The state of the keyer
+// is only accessed from within the keyer's goroutine.
+type CWKeyer struct {
+	trx        int
+	controller CWKeyController
+	commands   chan func()
+	closed     chan struct{}
+
+	mode   KeyerMode
+	wpm    int
+	weight int
+
+	dit       bool
+	dah       bool
+	ditMemory bool
+	dahMemory bool
+	element   keyerElement
+	last      keyerElement
+	busy      bool
+}
+
+type keyerElement int
+
+const (
+	noElement keyerElement = iota
+	ditElement
+	dahElement
+)
+
+func (k *CWKeyer) Close() {
+	select {
+	case <-k.closed:
+	default:
+		close(k.closed)
+	}
+}
+
+func (k *CWKeyer) do(command func()) {
+	select {
+	case k.commands <- command:
+	case <-k.closed:
+	}
+}
+
+func (k *CWKeyer) SetMode(mode KeyerMode) {
+	k.do(func() {
+		k.mode = mode
+	})
+}
+
+func (k *CWKeyer) SetWeight(weight int) {
+	k.do(func() {
+		k.weight = weight
+	})
+}
+
+func (k *CWKeyer) SetCWMacrosSpeed(wpm int) {
+	k.do(func() {
+		k.wpm = wpm
+	})
+}
+
+func (k *CWKeyer) SetPaddle(paddle cwPaddle, pressed bool) {
+	k.do(func() {
+		switch paddle {
+		case straightKey:
+			k.key(pressed)
+			return
+		case ditPaddle:
+			k.dit = pressed
+			if pressed && k.busy {
+				k.ditMemory = true
+			}
+		case dahPaddle:
+			k.dah = pressed
+			if pressed && k.busy {
+				k.dahMemory = true
+			}
+		}
+
+		if !k.busy {
+			k.last = noElement
+			k.next()
+		}
+	})
+}
+
+func (k *CWKeyer) key(down bool) {
+	err := k.controller.SetCWKeyer(k.trx, down)
+	if err != nil {
+		log.Printf("Cannot key the transmitter: %v", err)
+	}
+}
+
+// next sends the next element, if any paddle is pressed or memorized.
+func (k *CWKeyer) next() {
+	k.element = nextKeyerElement(k.last, k.dit, k.dah, k.ditMemory, k.dahMemory)
+	if k.element == noElement {
+		k.busy = false
+		return
+	}
+	k.busy = true
+
+	k.ditMemory = false
+	k.dahMemory = false
+	if k.mode == IambicB {
+		// in mode B, a squeeze during the element adds the opposite element after the paddles are released
+		k.ditMemory = k.element == dahElement && k.dit
+		k.dahMemory = k.element == ditElement && k.dah
+	}
+
+	ditDuration, dahDuration, spaceDuration := keyerTiming(k.wpm, k.weight)
+	elementDuration := ditDuration
+	if k.element == dahElement {
+		elementDuration = dahDuration
+	}
+
+	k.key(true)
+	time.AfterFunc(elementDuration, func() {
+		k.do(func() {
+			k.key(false)
+			time.AfterFunc(spaceDuration, func() {
+				k.do(func() {
+					k.last = k.element
+					k.next()
+				})
+			})
+		})
+	})
+}
+
+// nextKeyerElement decides which element follows the last element, depending on the paddles and the memory.
+func nextKeyerElement(last keyerElement, dit, dah, ditMemory, dahMemory bool) keyerElement {
+	ditWanted := dit || ditMemory
+	dahWanted := dah || dahMemory
+	switch {
+	case ditWanted && dahWanted:
+		if last == ditElement {
+			return dahElement
+		}
+		return ditElement
+	case ditWanted:
+		return ditElement
+	case dahWanted:
+		return dahElement
+	default:
+		return noElement
+	}
+}
+
+// keyerTiming calculates the durations of dit, dah and space for the given speed. A weight of 50 is the standard
+// ratio of 1:3 between dit and dah, a higher weight makes the elements longer and the space shorter.
+func keyerTiming(wpm int, weight int) (dit, dah, space time.Duration) {
+	if wpm <= 0 {
+		wpm = defaultCWSpeed
+	}
+	unit := 1200 * float64(time.Millisecond) / float64(wpm)
+	extra := unit * float64(weight-defaultKeyerWeight) / float64(defaultKeyerWeight)
+
+	dit = time.Duration(unit + extra)
+	dah = time.Duration(3*unit + extra)
+	space = time.Duration(unit - extra)
+	return
+}
@@ -0,0 +1,119 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextKeyerElement(t *testing.T) {
+	tt := []struct {
+		desc      string
+		last      keyerElement
+		dit       bool
+		dah       bool
+		ditMemory bool
+		dahMemory bool
+		expected  keyerElement
+	}{
+		{
+			desc:     "idle",
+			expected: noElement,
+		},
+		{
+			desc:     "dit",
+			dit:      true,
+			expected: ditElement,
+		},
+		{
+			desc:     "repeated dah",
+			last:     dahElement,
+			dah:      true,
+			expected: dahElement,
+		},
+		{
+			desc:     "squeeze starts with dit",
+			dit:      true,
+			dah:      true,
+			expected: ditElement,
+		},
+		{
+			desc:     "squeeze after dit",
+			last:     ditElement,
+			dit:      true,
+			dah:      true,
+			expected: dahElement,
+		},
+		{
+			desc:     "squeeze after dah",
+			last:     dahElement,
+			dit:      true,
+			dah:      true,
+			expected: ditElement,
+		},
+		{
+			desc:      "memorized dah",
+			last:      ditElement,
+			dahMemory: true,
+			expected:  dahElement,
+		},
+		{
+			desc:      "memorized dit while holding dah",
+			last:      dahElement,
+			dah:       true,
+			ditMemory: true,
+			expected:  ditElement,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual := nextKeyerElement(tc.last, tc.dit, tc.dah, tc.ditMemory, tc.dahMemory)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestKeyerTiming(t *testing.T) {
+	tt := []struct {
+		desc          string
+		wpm           int
+		weight        int
+		expectedDit   time.Duration
+		expectedDah   time.Duration
+		expectedSpace time.Duration
+	}{
+		{
+			desc:          "standard weight",
+			wpm:           20,
+			weight:        50,
+			expectedDit:   60 * time.Millisecond,
+			expectedDah:   180 * time.Millisecond,
+			expectedSpace: 60 * time.Millisecond,
+		},
+		{
+			desc:          "heavy weight",
+			wpm:           20,
+			weight:        60,
+			expectedDit:   72 * time.Millisecond,
+			expectedDah:   192 * time.Millisecond,
+			expectedSpace: 48 * time.Millisecond,
+		},
+		{
+			desc:          "no speed",
+			wpm:           0,
+			weight:        50,
+			expectedDit:   60 * time.Millisecond,
+			expectedDah:   180 * time.Millisecond,
+			expectedSpace: 60 * time.Millisecond,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			dit, dah, space := keyerTiming(tc.wpm, tc.weight)
+			assert.Equal(t, tc.expectedDit, dit)
+			assert.Equal(t, tc.expectedDah, dah)
+			assert.Equal(t, tc.expectedSpace, space)
+		})
+	}
+}
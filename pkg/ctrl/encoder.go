@@ -13,6 +13,7 @@ func NewEncoder(key MidiKey, set func(int), valueRange ValueRange, led LED, step
 		led:         led,
 		activeValue: make(chan int, 1000),
 		turns:       make(chan int, 1000),
+		stop:        make(chan struct{}),
 		closed:      make(chan struct{}),
 
 		stepSize:         stepSize,
@@ -32,6 +33,7 @@ type Encoder struct {
 	led         LED
 	activeValue chan int
 	turns       chan int
+	stop        chan struct{}
 	closed      chan struct{}
 
 	stepSize         int
@@ -69,19 +71,15 @@ func (e *Encoder) start() {
 
 		for {
 			select {
-			case value, valid := <-e.activeValue:
-				if !valid {
-					return
-				}
+			case <-e.stop:
+				return
+			case value := <-e.activeValue:
 				activeValue = value
 				// log.Printf("encoder active value: %d", activeValue)
 				if !pending {
 					selectedValue = activeValue
 				}
-			case turns, valid := <-e.turns:
-				if !valid {
-					return
-				}
+			case turns := <-e.turns:
 				// log.Printf("1 turns: %d", turns)
 
 				amount := e.stepSize
@@ -142,24 +140,68 @@ func (e *Encoder) start() {
 	}()
 }
 
+// Close stops the encoder. A closed encoder ignores all further values, it may still be notified by the TCI client.
 func (e *Encoder) Close() {
 	select {
-	case <-e.closed:
+	case <-e.stop:
 		return
 	default:
-		close(e.activeValue)
-		close(e.turns)
+		close(e.stop)
 		<-e.closed
 	}
 }
 
+func (e *Encoder) send(values chan<- int, value int) {
+	select {
+	case values <- value:
+	case <-e.stop:
+	}
+}
+
 func (e *Encoder) Changed(turns int) {
-	e.turns <- turns
+	e.send(e.turns, turns)
+}
+
+// ChangedFine handles a 14-bit value, e.g. from a pitch bend message, as turns relative to the center.
+func (e *Encoder) ChangedFine(value int) {
+	e.Changed(int(uint16(value)>>7) - 0x40)
 }
 
 func (e *Encoder) SetActiveValue(value int) {
-	e.activeValue <- value
+	e.send(e.activeValue, value)
 	if e.led != nil {
 		e.led.SetValue(e.key, Project(e.valueRange, value))
+		e.led.SetText(e.key, ShortValue(value))
+	}
+}
+
+type EncoderEncoding string
+
+const (
+	// OffsetEncoding encodes the turns as offset to 0x40.
+	OffsetEncoding EncoderEncoding = "offset"
+	// SignMagnitudeEncoding encodes the direction in bit 6 and the number of turns in bits 0 to 5, as used by MCU V-Pots.
+	SignMagnitudeEncoding EncoderEncoding = "sign_magnitude"
+	// TwosComplementEncoding encodes the turns as 7-bit two's complement.
+	TwosComplementEncoding EncoderEncoding = "twos_complement"
+)
+
+// DecodeEncoderTurns decodes the turns of an encoder from the given 7-bit value.
+func DecodeEncoderTurns(encoding EncoderEncoding, value uint8) int {
+	value &= 0x7f
+	switch encoding {
+	case SignMagnitudeEncoding:
+		turns := int(value & 0x3f)
+		if value&0x40 != 0 {
+			return -turns
+		}
+		return turns
+	case TwosComplementEncoding:
+		if value&0x40 != 0 {
+			return int(value) - 0x80
+		}
+		return int(value)
+	default:
+		return int(value) - 0x40
 	}
 }
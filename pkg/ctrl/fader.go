@@ -0,0 +1,143 @@
+package ctrl
+
+import (
+	"sync"
+	"time"
+)
+
+// FaderOptions reads the options of a motor fader: options["touch_key"] is the key on the same channel that
+// signals that the fader is touched, options["feedback_delay"] is the time in milliseconds after the last
+// movement until the value is sent back to the fader. Pitch bend controls are always handled as motor faders.
+func (m Mapping) FaderOptions() (touchKey *MidiKey, feedbackDelay time.Duration, isFader bool, err error) {
+	definition := Definitions[m.Type]
+	key, hasTouchKey, err := definition.OptionalIntOption(m, "touch_key")
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if hasTouchKey {
+		touchKey = &MidiKey{Channel: m.Channel, Key: int8(key)}
+	}
+
+	delay, err := definition.IntOption(m, "feedback_delay")
+	if err != nil {
+		return nil, 0, false, err
+	}
+	feedbackDelay = time.Duration(delay) * time.Millisecond
+	_, hasDelay := m.Options["feedback_delay"]
+
+	isFader = hasTouchKey || hasDelay || m.MidiKey().IsPitchbend()
+	return touchKey, feedbackDelay, isFader, nil
+}
+
+func NewFaderLED(led LED, touchKey *MidiKey, feedbackDelay time.Duration) *FaderLED {
+	return &FaderLED{
+		LED:           led,
+		touchKey:      touchKey,
+		feedbackDelay: feedbackDelay,
+	}
+}
+
+// FaderLED sends values back to a motor fader or LED ring. While the fader is touched or moved, the feedback
+// is suppressed, so the motor does not fight the operator's hand. The last suppressed value is sent when the
+// fader is released.
+type FaderLED struct {
+	LED
+	touchKey      *MidiKey
+	feedbackDelay time.Duration
+
+	mutex     sync.Mutex
+	touched   bool
+	lastMoved time.Time
+	pending   func()
+	timer     *time.Timer
+}
+
+// TouchKey returns the key that signals that the fader is touched.
+func (l *FaderLED) TouchKey() (MidiKey, bool) {
+	if l.touchKey == nil {
+		return MidiKey{}, false
+	}
+	return *l.touchKey, true
+}
+
+func (l *FaderLED) SetValue(key MidiKey, value uint8) {
+	l.feedback(func() {
+		l.LED.SetValue(key, value)
+	})
+}
+
+func (l *FaderLED) SetFineValue(key MidiKey, value uint16) {
+	l.feedback(func() {
+		l.LED.SetFineValue(key, value)
+	})
+}
+
+// Moved is called whenever a value is received from the fader.
+func (l *FaderLED) Moved() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lastMoved = time.Now()
+}
+
+// Pressed is called when the fader is touched.
+func (l *FaderLED) Pressed() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.touched = true
+}
+
+// Released is called when the fader is released.
+func (l *FaderLED) Released() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.touched = false
+	l.lastMoved = time.Now()
+	l.schedule(l.lastMoved)
+}
+
+func (l *FaderLED) feedback(write func()) {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.suppressed(now) {
+		l.pending = write
+		l.schedule(now)
+		l.mutex.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mutex.Unlock()
+
+	write()
+}
+
+// suppressed indicates if the feedback is currently suppressed. The mutex must be held.
+func (l *FaderLED) suppressed(now time.Time) bool {
+	return l.touched || now.Sub(l.lastMoved) < l.feedbackDelay
+}
+
+// schedule sends the pending value when the feedback delay after the last movement is over. While the fader is
+// touched, nothing is scheduled, Released schedules the pending value. The mutex must be held.
+func (l *FaderLED) schedule(now time.Time) {
+	if l.pending == nil || l.timer != nil || l.touched {
+		return
+	}
+	l.timer = time.AfterFunc(l.lastMoved.Add(l.feedbackDelay).Sub(now), l.flush)
+}
+
+func (l *FaderLED) flush() {
+	l.mutex.Lock()
+	l.timer = nil
+	now := time.Now()
+	if l.suppressed(now) {
+		l.schedule(now)
+		l.mutex.Unlock()
+		return
+	}
+	write := l.pending
+	l.pending = nil
+	l.mutex.Unlock()
+
+	if write != nil {
+		write()
+	}
+}
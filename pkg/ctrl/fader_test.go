@@ -0,0 +1,87 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type valueRecorder struct {
+	LED
+	values chan uint8
+}
+
+func (r *valueRecorder) SetValue(key MidiKey, value uint8) {
+	r.values <- value
+}
+
+func TestFaderLED(t *testing.T) {
+	key := MidiKey{Channel: 1, Key: 1}
+	touchKey := MidiKey{Channel: 1, Key: 2}
+	tt := []struct {
+		desc          string
+		feedbackDelay time.Duration
+		moved         bool
+		touched       bool
+		immediate     bool
+	}{
+		{
+			desc:          "zero delay",
+			feedbackDelay: 0,
+			immediate:     true,
+		},
+		{
+			desc:          "zero delay, touched",
+			feedbackDelay: 0,
+			touched:       true,
+		},
+		{
+			desc:          "delay after movement",
+			feedbackDelay: 200 * time.Millisecond,
+			moved:         true,
+		},
+		{
+			desc:          "delay, touched",
+			feedbackDelay: 20 * time.Millisecond,
+			touched:       true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			recorder := &valueRecorder{values: make(chan uint8, 1)}
+			fader := NewFaderLED(recorder, &touchKey, tc.feedbackDelay)
+			if tc.moved {
+				fader.Moved()
+			}
+			if tc.touched {
+				fader.Pressed()
+			}
+
+			fader.SetValue(key, 42)
+			if tc.immediate {
+				assert.Equal(t, uint8(42), <-recorder.values)
+				return
+			}
+			select {
+			case <-recorder.values:
+				t.Fatal("the feedback is not suppressed")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			if tc.touched {
+				fader.mutex.Lock()
+				scheduled := fader.timer != nil
+				fader.mutex.Unlock()
+				assert.False(t, scheduled, "the feedback is scheduled while the fader is touched")
+				fader.Released()
+			}
+			select {
+			case actual := <-recorder.values:
+				assert.Equal(t, uint8(42), actual)
+			case <-time.After(time.Second):
+				t.Fatal("the suppressed value was not sent")
+			}
+		})
+	}
+}
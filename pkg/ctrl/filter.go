@@ -1,9 +1,7 @@
 package ctrl
 
 import (
-	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/ftl/tci/client"
@@ -15,38 +13,46 @@ const (
 )
 
 func init() {
-	Factories[FilterMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		minFrequency, set, err := m.RequiredIntOption("min")
-		if err != nil {
-			return nil, ButtonControl, fmt.Errorf("invalid minimum frequency: %w", err)
-		}
-		if !set {
-			return nil, ButtonControl, fmt.Errorf("no minimum frequency configured. Use options[\"min\"]=\"<min frequency in Hz>\" to configure the filter's minimum frequency")
-		}
-
-		maxFrequency, set, err := m.RequiredIntOption("max")
-		if err != nil {
-			return nil, ButtonControl, fmt.Errorf("invalid maximum frequency: %w", err)
-		}
-		if !set {
-			return nil, ButtonControl, fmt.Errorf("no maximum frequency configured. Use options[\"max\"]=\"<max frequency in Hz>\" to configure the filter's maximum frequency")
-		}
-
-		mode, ok := m.Options["mode"]
-		if ok {
-			mode = strings.TrimSpace(strings.ToLower(mode))
-		}
-
-		return NewFilterBandButton(m.MidiKey(), m.TRX, minFrequency, maxFrequency, client.Mode(mode), led, tciClient), ButtonControl, nil
-	}
-	Factories[FilterWidthMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-
-		return NewFilterWidthControl(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
-	}
+	Register(Definition{
+		Type:        FilterMapping,
+		Description: "Selects a filter band.",
+		Control:     ButtonControl,
+		Options: []OptionSchema{
+			{Name: "min", Type: IntOptionType, Description: "the lower edge of the filter band in Hz", Required: true},
+			{Name: "max", Type: IntOptionType, Description: "the upper edge of the filter band in Hz", Required: true},
+			{Name: "mode", Type: StringOptionType, Description: "select the filter band only in this mode", Values: Modes},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			definition := Definitions[m.Type]
+			minFrequency, err := definition.IntOption(m, "min")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			maxFrequency, err := definition.IntOption(m, "max")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			mode, err := definition.StringOption(m, "mode")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+
+			return NewFilterBandButton(m.MidiKey(), m.TRX, minFrequency, maxFrequency, client.Mode(mode), led, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         FilterWidthMapping,
+		Description:  "Controls the width of the RX filter.",
+		ValueControl: true,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			return NewFilterWidthControl(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
+		},
+	})
 }
 
 func NewFilterBandButton(key MidiKey, trx int, bottomFrequency int, topFrequency int, mode client.Mode, led LED, controller RXFilterBandController) *FilterBandButton {
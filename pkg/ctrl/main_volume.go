@@ -12,16 +12,26 @@ const (
 )
 
 func init() {
-	Factories[MuteMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		return NewMuteButton(m.MidiKey(), led, tciClient), ButtonControl, nil
-	}
-	Factories[VolumeMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewVolumeControl(m.MidiKey(), controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
-	}
+	Register(Definition{
+		Type:        MuteMapping,
+		Description: "Mutes the audio output.",
+		Control:     ButtonControl,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			return NewMuteButton(m.MidiKey(), led, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         VolumeMapping,
+		Description:  "Controls the main volume.",
+		ValueControl: true,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewVolumeControl(m.MidiKey(), controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
+		},
+	})
 }
 
 func NewMuteButton(key MidiKey, led LED, muter Muter) *MuteButton {
@@ -0,0 +1,393 @@
+package ctrl
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ftl/tci/client"
+)
+
+const (
+	SMeterMapping  MappingType = "smeter"
+	TXPowerMapping MappingType = "tx_power"
+	SWRMapping     MappingType = "swr"
+)
+
+const sensorsInterval = 100 * time.Millisecond
+
+// the default ranges of the meters: S-meter from S0 to S9+40dB in dBm, TX power in W, SWR from 1:1 to 3:1
+var defaultMeterRanges = map[MappingType][2]float64{
+	SMeterMapping:  {-127, -33},
+	TXPowerMapping: {0, 100},
+	SWRMapping:     {1, 3},
+}
+
+// meterOptionSchemas returns the options of the given meter mapping type.
+func meterOptionSchemas(mappingType MappingType) []OptionSchema {
+	defaultRange := defaultMeterRanges[mappingType]
+	return []OptionSchema{
+		{Name: "min", Type: FloatOptionType, Description: "the value that is shown as empty meter", Default: strconv.FormatFloat(defaultRange[0], 'f', -1, 64)},
+		{Name: "max", Type: FloatOptionType, Description: "the value that is shown as full meter", Default: strconv.FormatFloat(defaultRange[1], 'f', -1, 64)},
+		{Name: "output", Type: StringOptionType, Description: "show the meter as value of the key or as bar on a series of LEDs", Default: string(ValueMeterOutput), Values: []string{string(ValueMeterOutput), string(NotesMeterOutput)}},
+		{Name: "count", Type: IntOptionType, Description: "the number of LEDs of the bar", Default: "8", Range: &[2]int{1, 128}},
+		{Name: "interval", Type: IntOptionType, Description: "the update interval of the LEDs in milliseconds", Default: "50", Range: &[2]int{10, 60000}},
+		{Name: "peak_hold", Type: IntOptionType, Description: "the time in milliseconds the peak is held", Default: "1000", Range: &[2]int{0, 60000}},
+		{Name: "decay", Type: FloatOptionType, Description: "the part of the range the level falls per second, greater than 0", Default: "1.5"},
+	}
+}
+
+func init() {
+	Register(Definition{
+		Type:        SMeterMapping,
+		Description: "Shows the RX signal strength in dBm on the LEDs.",
+		Control:     IndicatorControl,
+		Options:     meterOptionSchemas(SMeterMapping),
+		Check:       checkMeterOptions,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			options, err := meterOptions(m)
+			if err != nil {
+				return nil, UnknownControl, err
+			}
+			return NewSMeter(m.MidiKey(), m.TRX, led, options, tciClient), IndicatorControl, nil
+		},
+	})
+	Register(Definition{
+		Type:        TXPowerMapping,
+		Description: "Shows the peak TX power in W on the LEDs.",
+		Control:     IndicatorControl,
+		Options:     meterOptionSchemas(TXPowerMapping),
+		Check:       checkMeterOptions,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			options, err := meterOptions(m)
+			if err != nil {
+				return nil, UnknownControl, err
+			}
+			return NewTXPowerMeter(m.MidiKey(), m.TRX, led, options, tciClient), IndicatorControl, nil
+		},
+	})
+	Register(Definition{
+		Type:        SWRMapping,
+		Description: "Shows the SWR on the LEDs while transmitting.",
+		Control:     IndicatorControl,
+		Options:     meterOptionSchemas(SWRMapping),
+		Check:       checkMeterOptions,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			options, err := meterOptions(m)
+			if err != nil {
+				return nil, UnknownControl, err
+			}
+			return NewSWRMeter(m.MidiKey(), m.TRX, led, options, tciClient), IndicatorControl, nil
+		},
+	})
+}
+
+func checkMeterOptions(m Mapping) error {
+	_, err := meterOptions(m)
+	return err
+}
+
+type MeterOutput string
+
+const (
+	// ValueMeterOutput shows the meter as value of the mapping's key, e.g. as CC on a LED ring
+	ValueMeterOutput MeterOutput = "value"
+	// NotesMeterOutput shows the meter as bar on a series of LEDs, starting at the mapping's key
+	NotesMeterOutput MeterOutput = "notes"
+)
+
+type MeterOptions struct {
+	Min      float64
+	Max      float64
+	Output   MeterOutput
+	Count    int
+	Interval time.Duration
+	PeakHold time.Duration
+	Decay    float64
+}
+
+// meterOptions reads the options of a meter mapping: the range of the meter is given in options["min"] and
+// options["max"], options["output"] is either "value" or "notes". A notes output uses options["count"] LEDs.
+// The rate of the MIDI output is defined by options["interval"] in milliseconds, options["peak_hold"] defines
+// how long the peak is held in milliseconds, and options["decay"] how fast the level falls in parts of the range
+// per second. The LEDs of the bar use the keys starting at the key of the mapping, so they must not exceed key 127.
+func meterOptions(m Mapping) (MeterOptions, error) {
+	definition := Definitions[m.Type]
+	result := MeterOptions{Count: 1}
+	var err error
+
+	floatOptions := []struct {
+		name  string
+		value *float64
+	}{
+		{"min", &result.Min},
+		{"max", &result.Max},
+		{"decay", &result.Decay},
+	}
+	for _, option := range floatOptions {
+		*option.value, err = definition.FloatOption(m, option.name)
+		if err != nil {
+			return MeterOptions{}, err
+		}
+	}
+	if result.Decay <= 0 {
+		return MeterOptions{}, SchemaError{Field: "options/decay", Err: fmt.Errorf("invalid decay %v, use a value greater than 0", result.Decay)}
+	}
+	if result.Min >= result.Max {
+		return MeterOptions{}, fmt.Errorf("invalid range %v to %v", result.Min, result.Max)
+	}
+
+	output, err := definition.StringOption(m, "output")
+	if err != nil {
+		return MeterOptions{}, err
+	}
+	result.Output = MeterOutput(output)
+	if result.Output == NotesMeterOutput {
+		count, err := definition.IntOption(m, "count")
+		if err != nil {
+			return MeterOptions{}, err
+		}
+		if m.Key < 0 || int(m.Key)+count-1 > 127 {
+			return MeterOptions{}, SchemaError{Field: "options/count", Err: fmt.Errorf("the bar needs the keys %d to %d, use keys up to 127", m.Key, int(m.Key)+count-1)}
+		}
+		result.Count = count
+	}
+
+	interval, err := definition.IntOption(m, "interval")
+	if err != nil {
+		return MeterOptions{}, err
+	}
+	result.Interval = time.Duration(interval) * time.Millisecond
+	peakHold, err := definition.IntOption(m, "peak_hold")
+	if err != nil {
+		return MeterOptions{}, err
+	}
+	result.PeakHold = time.Duration(peakHold) * time.Millisecond
+
+	return result, nil
+}
+
+// meterLevel calculates the displayed level and peak of a meter with peak-hold and decay.
+// The level rises immediately and falls with the decay rate. The peak is held for the peak hold time,
+// then it falls with the decay rate, too. Level and peak are normalized to 0..1.
+type meterLevel struct {
+	min, max float64
+	peakHold time.Duration
+	decay    float64
+
+	level      float64
+	peak       float64
+	peakTime   time.Time
+	lastUpdate time.Time
+}
+
+func (m *meterLevel) update(value float64, now time.Time) {
+	m.fall(now)
+	normalized := (value - m.min) / (m.max - m.min)
+	normalized = math.Max(0, math.Min(1, normalized))
+	if normalized > m.level {
+		m.level = normalized
+	}
+	if normalized >= m.peak {
+		m.peak = normalized
+		m.peakTime = now
+	}
+}
+
+func (m *meterLevel) fall(now time.Time) {
+	if m.lastUpdate.IsZero() {
+		m.lastUpdate = now
+		return
+	}
+	delta := now.Sub(m.lastUpdate).Seconds() * m.decay
+	m.lastUpdate = now
+	m.level = math.Max(0, m.level-delta)
+	if now.Sub(m.peakTime) > m.peakHold {
+		m.peak = math.Max(m.level, m.peak-delta)
+	}
+}
+
+func (m *meterLevel) display(now time.Time) (level float64, peak float64) {
+	m.fall(now)
+	return m.level, m.peak
+}
+
+// meterLEDs returns which LEDs of a bar with the given number of LEDs are on.
+func meterLEDs(level float64, peak float64, count int) []bool {
+	result := make([]bool, count)
+	lit := int(math.Round(level * float64(count)))
+	for i := 0; i < lit && i < count; i++ {
+		result[i] = true
+	}
+	peakIndex := int(math.Round(peak*float64(count))) - 1
+	if peakIndex >= 0 && peakIndex < count {
+		result[peakIndex] = true
+	}
+	return result
+}
+
+// SensorsEnabler enables the sensor notifications of the TCI server.
+type SensorsEnabler interface {
+	SetRXSensorsEnable(bool, time.Duration) error
+	SetTXSensorsEnable(bool, time.Duration) error
+}
+
+func newMeter(key MidiKey, trx int, led LED, options MeterOptions, enabler SensorsEnabler, tx bool) *Meter {
+	result := &Meter{
+		key:     key,
+		trx:     trx,
+		led:     led,
+		options: options,
+		enabler: enabler,
+		tx:      tx,
+		level: meterLevel{
+			min:      options.Min,
+			max:      options.Max,
+			peakHold: options.PeakHold,
+			decay:    options.Decay,
+		},
+		values: make(chan float64, 100),
+		stop:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+
+	go result.run()
+
+	return result
+}
+
+// Meter shows a TCI sensor value on the LEDs. The values are written with a limited rate.
+type Meter struct {
+	key     MidiKey
+	trx     int
+	led     LED
+	options MeterOptions
+	enabler SensorsEnabler
+	tx      bool
+
+	level  meterLevel
+	values chan float64
+	stop   chan struct{}
+	closed chan struct{}
+}
+
+func (m *Meter) run() {
+	defer close(m.closed)
+	ticker := time.NewTicker(m.options.Interval)
+	defer ticker.Stop()
+
+	lastValue := -1
+	lastLEDs := make([]bool, m.options.Count)
+	first := true
+	for {
+		select {
+		case <-m.stop:
+			return
+		case value := <-m.values:
+			m.level.update(value, time.Now())
+		case now := <-ticker.C:
+			level, peak := m.level.display(now)
+			switch m.options.Output {
+			case ValueMeterOutput:
+				value := int(math.Round(level * 0x7f))
+				if value != lastValue {
+					m.led.SetValue(m.key, uint8(value))
+					lastValue = value
+				}
+			case NotesMeterOutput:
+				leds := meterLEDs(level, peak, m.options.Count)
+				for i, on := range leds {
+					if on == lastLEDs[i] && !first {
+						continue
+					}
+					m.led.SetOn(MidiKey{Channel: m.key.Channel, Key: m.key.Key + int8(i)}, on)
+				}
+				lastLEDs = leds
+			}
+			first = false
+		}
+	}
+}
+
+func (m *Meter) Close() {
+	select {
+	case <-m.stop:
+		return
+	default:
+		close(m.stop)
+		<-m.closed
+	}
+}
+
+func (m *Meter) show(value float64) {
+	select {
+	case m.values <- value:
+	default:
+		// drop the value if the meter cannot keep up
+	}
+}
+
+func (m *Meter) Connected(connected bool) {
+	if !connected {
+		return
+	}
+	var err error
+	if m.tx {
+		err = m.enabler.SetTXSensorsEnable(true, sensorsInterval)
+	} else {
+		err = m.enabler.SetRXSensorsEnable(true, sensorsInterval)
+	}
+	if err != nil {
+		log.Printf("Cannot enable the sensors: %v", err)
+	}
+}
+
+func NewSMeter(key MidiKey, trx int, led LED, options MeterOptions, enabler SensorsEnabler) *SMeter {
+	return &SMeter{newMeter(key, trx, led, options, enabler, false)}
+}
+
+// SMeter shows the RX signal strength in dBm.
+type SMeter struct {
+	*Meter
+}
+
+func (m *SMeter) SetRXSensors(trx int, dBm float64) {
+	if trx != m.trx {
+		return
+	}
+	m.show(dBm)
+}
+
+func NewTXPowerMeter(key MidiKey, trx int, led LED, options MeterOptions, enabler SensorsEnabler) *TXPowerMeter {
+	return &TXPowerMeter{newMeter(key, trx, led, options, enabler, true)}
+}
+
+// TXPowerMeter shows the peak TX power in W.
+type TXPowerMeter struct {
+	*Meter
+}
+
+func (m *TXPowerMeter) SetTXSensors(trx int, micDB float64, rmsPower float64, peakPower float64, swr float64) {
+	if trx != m.trx {
+		return
+	}
+	m.show(peakPower)
+}
+
+func NewSWRMeter(key MidiKey, trx int, led LED, options MeterOptions, enabler SensorsEnabler) *SWRMeter {
+	return &SWRMeter{newMeter(key, trx, led, options, enabler, true)}
+}
+
+// SWRMeter shows the SWR while transmitting.
+type SWRMeter struct {
+	*Meter
+}
+
+func (m *SWRMeter) SetTXSensors(trx int, micDB float64, rmsPower float64, peakPower float64, swr float64) {
+	if trx != m.trx {
+		return
+	}
+	m.show(swr)
+}
@@ -0,0 +1,77 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeterLevel(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+	m := meterLevel{min: -127, max: -33, peakHold: time.Second, decay: 1}
+
+	m.update(-33, at(0))
+	level, peak := m.display(at(0))
+	assert.Equal(t, 1.0, level, "rises immediately")
+	assert.Equal(t, 1.0, peak)
+
+	m.update(-127, at(100))
+	level, peak = m.display(at(500))
+	assert.InDelta(t, 0.5, level, 0.0001, "falls with the decay rate")
+	assert.Equal(t, 1.0, peak, "holds the peak")
+
+	level, peak = m.display(at(1250))
+	assert.Equal(t, 0.0, level)
+	assert.InDelta(t, 0.25, peak, 0.0001, "peak falls after the hold time")
+
+	m.update(0, at(1300))
+	level, _ = m.display(at(1300))
+	assert.Equal(t, 1.0, level, "clamped to the range")
+}
+
+func TestMeterLEDs(t *testing.T) {
+	tt := []struct {
+		desc     string
+		level    float64
+		peak     float64
+		count    int
+		expected []bool
+	}{
+		{
+			desc:     "empty",
+			count:    4,
+			expected: []bool{false, false, false, false},
+		},
+		{
+			desc:     "half",
+			level:    0.5,
+			peak:     0.5,
+			count:    4,
+			expected: []bool{true, true, false, false},
+		},
+		{
+			desc:     "separate peak",
+			level:    0.25,
+			peak:     1,
+			count:    4,
+			expected: []bool{true, false, false, true},
+		},
+		{
+			desc:     "full",
+			level:    1,
+			peak:     1,
+			count:    4,
+			expected: []bool{true, true, true, true},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual := meterLEDs(tc.level, tc.peak, tc.count)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package ctrl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ftl/tci/client"
+)
+
+// Migrate upgrades the given mapping to the current schema: a deprecated mapping type is replaced, aliases of
+// option values are replaced by their current values, and all values are written in their canonical spelling.
+// It returns the upgraded mapping and a description of each upgrade. Changes of the spelling are not described.
+func Migrate(m Mapping) (Mapping, []string) {
+	definition, ok := Definitions[m.Type]
+	if !ok {
+		return m, nil
+	}
+	var upgrades []string
+	if definition.ReplacedBy != "" {
+		upgrades = append(upgrades, fmt.Sprintf("the mapping type %s is replaced by %s", m.Type, definition.ReplacedBy))
+		m.Type = definition.ReplacedBy
+		definition = Definitions[m.Type]
+	}
+
+	if vfo, err := AtoVFO(strings.TrimSpace(m.VFO)); err == nil {
+		m.VFO = canonicalVFO(vfo)
+	}
+
+	if len(m.Options) == 0 {
+		return m, upgrades
+	}
+	options := definition.AllOptions()
+	result := make(map[string]string, len(m.Options))
+	names := make([]string, 0, len(m.Options))
+	for name := range m.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := m.Options[name]
+		option, ok := findOption(options, name)
+		if !ok {
+			result[name] = value
+			continue
+		}
+		canonical := option.canonicalValue(value)
+		if _, isAlias := option.Aliases[strings.ToLower(strings.TrimSpace(value))]; isAlias {
+			upgrades = append(upgrades, fmt.Sprintf("%s: the value %s of the option %s is replaced by %s", m.Type, value, name, canonical))
+		}
+		result[name] = canonical
+	}
+	m.Options = result
+
+	return m, upgrades
+}
+
+func canonicalVFO(vfo client.VFO) string {
+	if vfo == client.VFOA {
+		return "VFOA"
+	}
+	return "VFOB"
+}
+
+// canonicalValue returns the given value in its canonical spelling. Invalid values are only trimmed.
+func (o OptionSchema) canonicalValue(value string) string {
+	if canonical, ok := o.Canonical(value); ok || len(o.Values) > 0 {
+		return canonical
+	}
+	value = strings.TrimSpace(value)
+	switch o.Type {
+	case IntOptionType:
+		if i, err := strconv.Atoi(value); err == nil {
+			return strconv.Itoa(i)
+		}
+	case BoolOptionType:
+		switch strings.ToLower(value) {
+		case "on", "true", "yes", "1":
+			return "true"
+		case "off", "false", "no", "0":
+			return "false"
+		}
+	case VFOOptionType:
+		if vfo, err := AtoVFO(value); err == nil {
+			return canonicalVFO(vfo)
+		}
+	}
+	return value
+}
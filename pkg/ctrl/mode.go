@@ -11,15 +11,23 @@ import (
 const ModeMapping MappingType = "mode"
 
 func init() {
-	Factories[ModeMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		mode, ok := m.Options["mode"]
-		if !ok {
-			return nil, ButtonControl, fmt.Errorf("no mode configured. Use options[\"mode\"]=\"<mode>\" to configure the mode you want to select")
+	Register(Definition{
+		Type:        ModeMapping,
+		Description: "Selects a mode.",
+		Control:     ButtonControl,
+		Options: []OptionSchema{
+			{Name: "mode", Type: StringOptionType, Description: "the mode that is selected", Required: true, Values: Modes},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			mode, ok := m.Options["mode"]
+			if !ok {
+				return nil, ButtonControl, fmt.Errorf("no mode configured. Use options[\"mode\"]=\"<mode>\" to configure the mode you want to select")
 
-		}
-		mode = strings.TrimSpace(strings.ToLower(mode))
-		return NewModeButton(m.MidiKey(), m.TRX, client.Mode(mode), led, tciClient), ButtonControl, nil
-	}
+			}
+			mode = strings.TrimSpace(strings.ToLower(mode))
+			return NewModeButton(m.MidiKey(), m.TRX, client.Mode(mode), led, tciClient), ButtonControl, nil
+		},
+	})
 }
 
 func NewModeButton(key MidiKey, trx int, mode client.Mode, led LED, controller ModeController) *ModeButton {
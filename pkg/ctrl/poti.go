@@ -12,6 +12,7 @@ func NewPoti(key MidiKey, set func(int), valueRange ValueRange, led LED) *Poti {
 		led:           led,
 		selectedValue: make(chan int, 1000),
 		activeValue:   make(chan int, 1000),
+		stop:          make(chan struct{}),
 		closed:        make(chan struct{}),
 	}
 
@@ -27,6 +28,7 @@ type Poti struct {
 	led           LED
 	activeValue   chan int
 	selectedValue chan int
+	stop          chan struct{}
 	closed        chan struct{}
 }
 
@@ -54,19 +56,15 @@ func (s *Poti) start() {
 
 		for {
 			select {
-			case value, valid := <-s.activeValue:
-				if !valid {
-					return
-				}
+			case <-s.stop:
+				return
+			case value := <-s.activeValue:
 				activeValue = value
 				// log.Printf("poti active value: %d", activeValue)
 				if !pending {
 					selectedValue = activeValue
 				}
-			case value, valid := <-s.selectedValue:
-				if !valid {
-					return
-				}
+			case value := <-s.selectedValue:
 				selectedValue = value
 				// log.Printf("poti selectedValue: %d", selectedValue)
 
@@ -99,24 +97,43 @@ func (s *Poti) start() {
 	}()
 }
 
+// Close stops the poti. A closed poti ignores all further values, it may still be notified by the TCI client.
 func (s *Poti) Close() {
 	select {
-	case <-s.closed:
+	case <-s.stop:
 		return
 	default:
-		close(s.activeValue)
-		close(s.selectedValue)
+		close(s.stop)
 		<-s.closed
 	}
 }
 
+func (s *Poti) send(values chan<- int, value int) {
+	select {
+	case values <- value:
+	case <-s.stop:
+	}
+}
+
 func (s *Poti) Changed(value int) {
-	s.selectedValue <- Translate(s.valueRange, uint8(value))
+	s.send(s.selectedValue, Translate(s.valueRange, uint8(value)))
+}
+
+// ChangedFine handles a 14-bit value, e.g. from a pitch bend message.
+func (s *Poti) ChangedFine(value int) {
+	s.send(s.selectedValue, TranslateFine(s.valueRange, uint16(value)))
 }
 
+// SetActiveValue sends the value back to the device. Pitch bend controls get the value with 14-bit resolution.
 func (s *Poti) SetActiveValue(value int) {
-	s.activeValue <- value
-	if s.led != nil {
+	s.send(s.activeValue, value)
+	if s.led == nil {
+		return
+	}
+	if s.key.IsPitchbend() {
+		s.led.SetFineValue(s.key, ProjectFine(s.valueRange, value))
+	} else {
 		s.led.SetValue(s.key, Project(s.valueRange, value))
 	}
+	s.led.SetText(s.key, ShortValue(value))
 }
@@ -7,54 +7,174 @@ import (
 )
 
 const (
-	defaultRitXitRange = 100
-
 	EnableRITMapping MappingType = "enable_rit"
 	RITMapping       MappingType = "rit"
 	EnableXITMapping MappingType = "enable_xit"
 	XITMapping       MappingType = "xit"
+	ClearRITMapping  MappingType = "clear_rit"
+	ClearXITMapping  MappingType = "clear_xit"
+	RITXITMapping    MappingType = "rit_xit"
+)
+
+var (
+	offsetOptionSchemas = []OptionSchema{
+		{Name: "range", Type: IntOptionType, Description: "the range of the offset in Hz", Default: "100", Range: &[2]int{1, 99999}},
+	}
+	offsetEnableOptionSchemas = []OptionSchema{
+		{Name: "reset", Type: BoolOptionType, Description: "set the offset to zero when the RIT or XIT is switched off", Default: "false"},
+		{Name: "indicator_key", Type: IntOptionType, Description: "the key on the same channel of the LED ring that shows the current offset", Range: &[2]int{0, 127}},
+		{Name: "range", Type: IntOptionType, Description: "the range of the offset in Hz", Default: "100", Range: &[2]int{1, 99999}},
+	}
 )
 
 func init() {
-	Factories[EnableRITMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		reset := m.BoolOption("reset", false)
-		return NewRITEnableButton(m.MidiKey(), m.TRX, led, reset, tciClient), ButtonControl, nil
+	Register(Definition{
+		Type:        EnableRITMapping,
+		Description: "Switches the RIT on and off.",
+		Control:     ButtonControl,
+		Options:     offsetEnableOptionSchemas,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			reset, err := Definitions[m.Type].BoolOption(m, "reset")
+			if err != nil {
+				return nil, 0, err
+			}
+			indicator, err := offsetIndicatorOption(m, led)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewRITEnableButton(m.MidiKey(), m.TRX, led, reset, indicator, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         RITMapping,
+		Description:  "Controls the RIT offset in Hz.",
+		ValueControl: true,
+		Options:      offsetOptionSchemas,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			frequencyRange, err := Definitions[m.Type].IntOption(m, "range")
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewRITControl(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, frequencyRange, tciClient), controlType, nil
+		},
+	})
+	Register(Definition{
+		Type:        EnableXITMapping,
+		Description: "Switches the XIT on and off.",
+		Control:     ButtonControl,
+		Options:     offsetEnableOptionSchemas,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			reset, err := Definitions[m.Type].BoolOption(m, "reset")
+			if err != nil {
+				return nil, 0, err
+			}
+			indicator, err := offsetIndicatorOption(m, led)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewXITEnableButton(m.MidiKey(), m.TRX, led, reset, indicator, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         XITMapping,
+		Description:  "Controls the XIT offset in Hz.",
+		ValueControl: true,
+		Options:      offsetOptionSchemas,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			frequencyRange, err := Definitions[m.Type].IntOption(m, "range")
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewXITControl(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, frequencyRange, tciClient), controlType, nil
+		},
+	})
+	Register(Definition{
+		Type:        ClearRITMapping,
+		Description: "Sets the RIT offset to zero.",
+		Control:     ButtonControl,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			return NewClearRITButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:        ClearXITMapping,
+		Description: "Sets the XIT offset to zero.",
+		Control:     ButtonControl,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			return NewClearXITButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         RITXITMapping,
+		Description:  "Controls the RIT offset while RX and the XIT offset while TX.",
+		ValueControl: true,
+		Options:      offsetOptionSchemas,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			frequencyRange, err := Definitions[m.Type].IntOption(m, "range")
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewRITXITControl(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, frequencyRange, tciClient), controlType, nil
+		},
+	})
+}
+
+// offsetIndicatorOption reads the configuration of the LED ring that shows the current offset. The LED ring is
+// configured with options["indicator_key"] and uses the same channel as the button.
+func offsetIndicatorOption(m Mapping, led LED) (*offsetIndicator, error) {
+	definition := Definitions[m.Type]
+	key, set, err := definition.OptionalIntOption(m, "indicator_key")
+	if err != nil {
+		return nil, err
 	}
-	Factories[RITMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-		frequencyRange, err := m.IntOption("range", defaultRitXitRange)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewRITControl(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, frequencyRange, tciClient), controlType, nil
+	if !set {
+		return nil, nil
 	}
-	Factories[EnableXITMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		reset := m.BoolOption("reset", false)
-		return NewXITEnableButton(m.MidiKey(), m.TRX, led, reset, tciClient), ButtonControl, nil
+	frequencyRange, err := definition.IntOption(m, "range")
+	if err != nil {
+		return nil, err
 	}
-	Factories[XITMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-		frequencyRange, err := m.IntOption("range", defaultRitXitRange)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewXITControl(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, frequencyRange, tciClient), controlType, nil
+	return &offsetIndicator{
+		key:        MidiKey{Channel: m.Channel, Key: int8(key)},
+		led:        led,
+		valueRange: StaticRange{-frequencyRange, frequencyRange},
+	}, nil
+}
+
+// offsetIndicator shows the sign and magnitude of an offset on a LED ring, the center of the ring represents zero.
+type offsetIndicator struct {
+	key        MidiKey
+	led        LED
+	valueRange ValueRange
+}
+
+func (i *offsetIndicator) SetOffset(offset int) {
+	if i == nil {
+		return
 	}
+	i.led.SetValue(i.key, Project(i.valueRange, offset))
 }
 
-func NewRITEnableButton(key MidiKey, trx int, led LED, reset bool, ritEnabler RITEnabler) *RITEnableButton {
+func NewRITEnableButton(key MidiKey, trx int, led LED, reset bool, indicator *offsetIndicator, ritEnabler RITEnabler) *RITEnableButton {
 	return &RITEnableButton{
 		key:        key,
 		trx:        trx,
 		led:        led,
 		ritEnabler: ritEnabler,
 		reset:      reset,
+		indicator:  indicator,
 	}
 }
 
@@ -64,8 +184,9 @@ type RITEnableButton struct {
 	led        LED
 	ritEnabler RITEnabler
 
-	reset   bool
-	enabled bool
+	reset     bool
+	indicator *offsetIndicator
+	enabled   bool
 }
 
 type RITEnabler interface {
@@ -94,13 +215,21 @@ func (b *RITEnableButton) SetRITEnable(trx int, enabled bool) {
 	b.led.SetOn(b.key, enabled)
 }
 
-func NewXITEnableButton(key MidiKey, trx int, led LED, reset bool, xitEnabler XITEnabler) *XITEnableButton {
+func (b *RITEnableButton) SetRITOffset(trx int, offset int) {
+	if trx != b.trx {
+		return
+	}
+	b.indicator.SetOffset(offset)
+}
+
+func NewXITEnableButton(key MidiKey, trx int, led LED, reset bool, indicator *offsetIndicator, xitEnabler XITEnabler) *XITEnableButton {
 	return &XITEnableButton{
 		key:        key,
 		trx:        trx,
 		led:        led,
 		xitEnabler: xitEnabler,
 		reset:      reset,
+		indicator:  indicator,
 	}
 }
 
@@ -110,8 +239,9 @@ type XITEnableButton struct {
 	led        LED
 	xitEnabler XITEnabler
 
-	reset   bool
-	enabled bool
+	reset     bool
+	indicator *offsetIndicator
+	enabled   bool
 }
 
 type XITEnabler interface {
@@ -140,6 +270,73 @@ func (b *XITEnableButton) SetXITEnable(trx int, enabled bool) {
 	b.led.SetOn(b.key, enabled)
 }
 
+func (b *XITEnableButton) SetXITOffset(trx int, offset int) {
+	if trx != b.trx {
+		return
+	}
+	b.indicator.SetOffset(offset)
+}
+
+func NewClearRITButton(key MidiKey, trx int, led LED, controller RITController) *ClearRITButton {
+	return &ClearRITButton{
+		key:        key,
+		trx:        trx,
+		led:        led,
+		controller: controller,
+	}
+}
+
+type ClearRITButton struct {
+	key        MidiKey
+	trx        int
+	led        LED
+	controller RITController
+}
+
+func (b *ClearRITButton) Pressed() {
+	err := b.controller.SetRITOffset(b.trx, 0)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func (b *ClearRITButton) SetRITOffset(trx int, offset int) {
+	if trx != b.trx {
+		return
+	}
+	b.led.SetOn(b.key, offset != 0)
+}
+
+func NewClearXITButton(key MidiKey, trx int, led LED, controller XITController) *ClearXITButton {
+	return &ClearXITButton{
+		key:        key,
+		trx:        trx,
+		led:        led,
+		controller: controller,
+	}
+}
+
+type ClearXITButton struct {
+	key        MidiKey
+	trx        int
+	led        LED
+	controller XITController
+}
+
+func (b *ClearXITButton) Pressed() {
+	err := b.controller.SetXITOffset(b.trx, 0)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func (b *ClearXITButton) SetXITOffset(trx int, offset int) {
+	if trx != b.trx {
+		return
+	}
+	b.led.SetOn(b.key, offset != 0)
+}
+
 func NewRITControl(key MidiKey, trx int, controlType ControlType, led LED, stepSize int, reverseDirection bool, dynamicMode bool, frequencyRange int, controller RITController) *RITControl {
 	set := func(v int) {
 		err := controller.SetRITOffset(trx, v)
@@ -199,3 +396,86 @@ func (s *XITControl) SetXITOffset(trx int, offset int) {
 	}
 	s.ValueControl.SetActiveValue(offset)
 }
+
+func NewRITXITControl(key MidiKey, trx int, controlType ControlType, led LED, stepSize int, reverseDirection bool, dynamicMode bool, frequencyRange int, controller RITXITController) *RITXITControl {
+	result := &RITXITControl{
+		trx:        trx,
+		controller: controller,
+	}
+	valueRange := StaticRange{-frequencyRange, frequencyRange}
+	result.ValueControl = NewValueControl(key, controlType, result.set, valueRange, led, stepSize, reverseDirection, dynamicMode)
+	return result
+}
+
+// RITXITControl changes the RIT offset if RIT is enabled, the XIT offset if XIT is enabled, or both if both are enabled.
+// If neither RIT nor XIT is enabled, the RIT offset is changed.
+type RITXITControl struct {
+	ValueControl
+	trx        int
+	controller RITXITController
+
+	ritEnabled bool
+	xitEnabled bool
+	ritOffset  int
+	xitOffset  int
+}
+
+type RITXITController interface {
+	RITController
+	XITController
+}
+
+func (s *RITXITControl) set(offset int) {
+	if s.ritEnabled || !s.xitEnabled {
+		err := s.controller.SetRITOffset(s.trx, offset)
+		if err != nil {
+			log.Printf("Cannot change RIT offset: %v", err)
+		}
+	}
+	if s.xitEnabled {
+		err := s.controller.SetXITOffset(s.trx, offset)
+		if err != nil {
+			log.Printf("Cannot change XIT offset: %v", err)
+		}
+	}
+}
+
+func (s *RITXITControl) updateActiveValue() {
+	if s.ritEnabled || !s.xitEnabled {
+		s.ValueControl.SetActiveValue(s.ritOffset)
+	} else {
+		s.ValueControl.SetActiveValue(s.xitOffset)
+	}
+}
+
+func (s *RITXITControl) SetRITEnable(trx int, enabled bool) {
+	if trx != s.trx {
+		return
+	}
+	s.ritEnabled = enabled
+	s.updateActiveValue()
+}
+
+func (s *RITXITControl) SetXITEnable(trx int, enabled bool) {
+	if trx != s.trx {
+		return
+	}
+	s.xitEnabled = enabled
+	s.updateActiveValue()
+}
+
+func (s *RITXITControl) SetRITOffset(trx int, offset int) {
+	if trx != s.trx {
+		return
+	}
+	s.ritOffset = offset
+	s.updateActiveValue()
+}
+
+func (s *RITXITControl) SetXITOffset(trx int, offset int) {
+	if trx != s.trx {
+		return
+	}
+	s.xitOffset = offset
+	s.updateActiveValue()
+}
@@ -0,0 +1,238 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type offsetRecorder struct {
+	rit chan int
+	xit chan int
+}
+
+func newOffsetRecorder() *offsetRecorder {
+	return &offsetRecorder{rit: make(chan int, 10), xit: make(chan int, 10)}
+}
+
+func (r *offsetRecorder) SetRITOffset(trx int, offset int) error {
+	r.rit <- offset
+	return nil
+}
+
+func (r *offsetRecorder) SetXITOffset(trx int, offset int) error {
+	r.xit <- offset
+	return nil
+}
+
+type ringRecorder struct {
+	LED
+	on     map[MidiKey]bool
+	values map[MidiKey]uint8
+}
+
+func newRingRecorder() *ringRecorder {
+	return &ringRecorder{on: make(map[MidiKey]bool), values: make(map[MidiKey]uint8)}
+}
+
+func (r *ringRecorder) SetOn(key MidiKey, on bool) {
+	r.on[key] = on
+}
+
+func (r *ringRecorder) SetValue(key MidiKey, value uint8) {
+	r.values[key] = value
+}
+
+func (r *ringRecorder) SetText(key MidiKey, text string) {}
+
+func receiveOffset(t *testing.T, offsets chan int) (int, bool) {
+	t.Helper()
+	select {
+	case offset := <-offsets:
+		return offset, true
+	case <-time.After(200 * time.Millisecond):
+		return 0, false
+	}
+}
+
+func TestRITXITControl(t *testing.T) {
+	tt := []struct {
+		desc        string
+		ritEnabled  bool
+		xitEnabled  bool
+		value       int
+		expectedRIT bool
+		expectedXIT bool
+		expected    int
+	}{
+		{
+			desc:        "neither enabled, lowest value",
+			value:       0,
+			expectedRIT: true,
+			expected:    -500,
+		},
+		{
+			desc:        "RIT enabled, highest value",
+			ritEnabled:  true,
+			value:       127,
+			expectedRIT: true,
+			expected:    Translate(StaticRange{-500, 500}, 127),
+		},
+		{
+			desc:        "XIT enabled, lowest value",
+			xitEnabled:  true,
+			value:       0,
+			expectedXIT: true,
+			expected:    -500,
+		},
+		{
+			desc:        "both enabled, highest value",
+			ritEnabled:  true,
+			xitEnabled:  true,
+			value:       127,
+			expectedRIT: true,
+			expectedXIT: true,
+			expected:    Translate(StaticRange{-500, 500}, 127),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			controller := newOffsetRecorder()
+			control := NewRITXITControl(MidiKey{Channel: 1, Key: 1}, 0, PotiControl, nil, 1, false, false, 500, controller)
+			defer control.Close()
+			control.SetRITEnable(0, tc.ritEnabled)
+			control.SetXITEnable(0, tc.xitEnabled)
+			control.SetXITEnable(1, !tc.xitEnabled)
+
+			control.Changed(tc.value)
+
+			rit, ok := receiveOffset(t, controller.rit)
+			assert.Equal(t, tc.expectedRIT, ok, "RIT offset")
+			if tc.expectedRIT {
+				assert.Equal(t, tc.expected, rit)
+			}
+			xit, ok := receiveOffset(t, controller.xit)
+			assert.Equal(t, tc.expectedXIT, ok, "XIT offset")
+			if tc.expectedXIT {
+				assert.Equal(t, tc.expected, xit)
+			}
+		})
+	}
+}
+
+func TestRITXITControl_ShowsActiveOffset(t *testing.T) {
+	key := MidiKey{Channel: 1, Key: 1}
+	valueRange := StaticRange{-100, 100}
+	led := newRingRecorder()
+	control := NewRITXITControl(key, 0, PotiControl, led, 1, false, false, 100, newOffsetRecorder())
+	defer control.Close()
+
+	control.SetRITOffset(0, -50)
+	control.SetXITOffset(0, 80)
+	assert.Equal(t, Project(valueRange, -50), led.values[key], "RIT offset without XIT")
+
+	control.SetXITEnable(0, true)
+	assert.Equal(t, Project(valueRange, 80), led.values[key], "XIT offset")
+
+	control.SetRITEnable(0, true)
+	assert.Equal(t, Project(valueRange, -50), led.values[key], "RIT offset with RIT and XIT")
+
+	control.SetRITOffset(1, 20)
+	assert.Equal(t, Project(valueRange, -50), led.values[key], "offset of another TRX")
+}
+
+func TestClearRITAndXITButtons(t *testing.T) {
+	key := MidiKey{Channel: 1, Key: 1}
+	led := newRingRecorder()
+	controller := newOffsetRecorder()
+	clearRIT := NewClearRITButton(key, 0, led, controller)
+	clearXIT := NewClearXITButton(key, 0, led, controller)
+
+	clearRIT.Pressed()
+	offset, ok := receiveOffset(t, controller.rit)
+	assert.True(t, ok)
+	assert.Equal(t, 0, offset)
+	clearXIT.Pressed()
+	offset, ok = receiveOffset(t, controller.xit)
+	assert.True(t, ok)
+	assert.Equal(t, 0, offset)
+
+	clearRIT.SetRITOffset(0, -20)
+	assert.True(t, led.on[key], "RIT offset is set")
+	clearRIT.SetRITOffset(1, 0)
+	assert.True(t, led.on[key], "offset of another TRX")
+	clearRIT.SetRITOffset(0, 0)
+	assert.False(t, led.on[key], "RIT offset is cleared")
+
+	clearXIT.SetXITOffset(0, 30)
+	assert.True(t, led.on[key], "XIT offset is set")
+	clearXIT.SetXITOffset(0, 0)
+	assert.False(t, led.on[key], "XIT offset is cleared")
+}
+
+func TestOffsetIndicator(t *testing.T) {
+	tt := []struct {
+		desc     string
+		options  map[string]string
+		offset   int
+		expected uint8
+	}{
+		{
+			desc:     "center",
+			options:  map[string]string{"indicator_key": "20"},
+			offset:   0,
+			expected: 64,
+		},
+		{
+			desc:     "negative end of the default range",
+			options:  map[string]string{"indicator_key": "20"},
+			offset:   -100,
+			expected: 0,
+		},
+		{
+			desc:     "positive end of the default range",
+			options:  map[string]string{"indicator_key": "20"},
+			offset:   100,
+			expected: 127,
+		},
+		{
+			desc:     "half of the configured range",
+			options:  map[string]string{"indicator_key": "20", "range": "1000"},
+			offset:   -500,
+			expected: 32,
+		},
+		{
+			desc:     "beyond the configured range",
+			options:  map[string]string{"indicator_key": "20", "range": "1000"},
+			offset:   2000,
+			expected: 127,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			led := newRingRecorder()
+			indicator, err := offsetIndicatorOption(Mapping{Type: EnableRITMapping, Channel: 2, Key: 10, Options: tc.options}, led)
+			require.NoError(t, err)
+			require.NotNil(t, indicator)
+
+			indicator.SetOffset(tc.offset)
+
+			indicatorKey := MidiKey{Channel: 2, Key: 20}
+			assert.Equal(t, tc.expected, led.values[indicatorKey])
+			assert.Len(t, led.values, 1)
+		})
+	}
+}
+
+func TestOffsetIndicator_WithoutKey(t *testing.T) {
+	led := newRingRecorder()
+	indicator, err := offsetIndicatorOption(Mapping{Type: EnableXITMapping, Options: map[string]string{"range": "500"}}, led)
+	require.NoError(t, err)
+	assert.Nil(t, indicator)
+
+	button := NewXITEnableButton(MidiKey{Key: 1}, 0, led, false, indicator, nil)
+	assert.NotPanics(t, func() { button.SetXITOffset(0, 100) })
+	assert.Empty(t, led.values)
+}
@@ -7,41 +7,64 @@ import (
 )
 
 const (
-	MixerMapping    MappingType = "rx_mixer"
-	SetMixerMapping MappingType = "set_rx_mixer"
+	MixerMapping        MappingType = "rx_mixer"
+	MixerBalanceMapping MappingType = "rx_mixer_balance"
+	SetMixerMapping     MappingType = "set_rx_mixer"
 )
 
 func init() {
-	Factories[MixerMapping] = func(m Mapping, _ LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		return NewRXMixer(m.TRX, tciClient), PotiControl, nil
-	}
-	Factories["experimental_"+MixerMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewRXMixer2(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
-	}
-	Factories[SetMixerMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		volumeA, err := m.IntOption("volume_a", 0)
-		if err != nil {
-			return nil, 0, err
-		}
-		volumeB, err := m.IntOption("volume_b", 0)
-		if err != nil {
-			return nil, 0, err
-		}
-		balanceA, err := m.IntOption("balance_a", 0)
-		if err != nil {
-			return nil, 0, err
-		}
-		balanceB, err := m.IntOption("balance_b", 0)
-		if err != nil {
-			return nil, 0, err
-		}
-
-		return NewSetRXMixerButton(m.MidiKey(), m.TRX, led, volumeA, volumeB, balanceA, balanceB, tciClient), ButtonControl, nil
-	}
+	Register(Definition{
+		Type:        MixerMapping,
+		Description: "Controls the volumes of both RX channels with one poti, like a crossfader.",
+		Control:     PotiControl,
+		Factory: func(m Mapping, _ LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			return NewRXMixer(m.TRX, tciClient), PotiControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         MixerBalanceMapping,
+		Description:  "Controls the volumes and the balance of both RX channels with one control, like a crossfader.",
+		ValueControl: true,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewRXMixer2(m.MidiKey(), m.TRX, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
+		},
+	})
+	RegisterDeprecated("experimental_"+MixerMapping, MixerBalanceMapping)
+	Register(Definition{
+		Type:        SetMixerMapping,
+		Description: "Sets the volumes and the balance of both RX channels.",
+		Control:     ButtonControl,
+		Options: []OptionSchema{
+			{Name: "volume_a", Type: IntOptionType, Description: "the volume of RX channel A in dB", Default: "0", Range: &[2]int{-60, 0}},
+			{Name: "volume_b", Type: IntOptionType, Description: "the volume of RX channel B in dB", Default: "0", Range: &[2]int{-60, 0}},
+			{Name: "balance_a", Type: IntOptionType, Description: "the balance of RX channel A", Default: "0", Range: &[2]int{-40, 40}},
+			{Name: "balance_b", Type: IntOptionType, Description: "the balance of RX channel B", Default: "0", Range: &[2]int{-40, 40}},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			volumeA, err := Definitions[m.Type].IntOption(m, "volume_a")
+			if err != nil {
+				return nil, 0, err
+			}
+			volumeB, err := Definitions[m.Type].IntOption(m, "volume_b")
+			if err != nil {
+				return nil, 0, err
+			}
+			balanceA, err := Definitions[m.Type].IntOption(m, "balance_a")
+			if err != nil {
+				return nil, 0, err
+			}
+			balanceB, err := Definitions[m.Type].IntOption(m, "balance_b")
+			if err != nil {
+				return nil, 0, err
+			}
+
+			return NewSetRXMixerButton(m.MidiKey(), m.TRX, led, volumeA, volumeB, balanceA, balanceB, tciClient), ButtonControl, nil
+		},
+	})
 }
 
 func NewRXMixer(trx int, controller RXMixController) *RXMixer {
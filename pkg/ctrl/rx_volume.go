@@ -1,7 +1,6 @@
 package ctrl
 
 import (
-	"fmt"
 	"log"
 
 	"github.com/ftl/tci/client"
@@ -16,63 +15,93 @@ const (
 )
 
 func init() {
-	Factories[EnableRXMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		vfo, err := AtoVFO(m.VFO)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewRXChannelEnableButton(m.MidiKey(), m.TRX, vfo, led, tciClient), ButtonControl, nil
-	}
-	Factories[RXVolumeMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		vfo, err := AtoVFO(m.VFO)
-		if err != nil {
-			return nil, 0, err
-		}
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewRXVolumeControl(m.MidiKey(), m.TRX, vfo, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
-	}
-	Factories[SetRXVolumeMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		vfo, err := AtoVFO(m.VFO)
-		if err != nil {
-			return nil, 0, err
-		}
-		value, set, err := m.RequiredIntOption("volume")
-		if err != nil {
-			return nil, 0, err
-		}
-		if !set {
-			return nil, 0, fmt.Errorf("no volume configured. Use options[\"volume\"]=\"<volume in dB (-60 to 0)>\" to configure the volume")
-		}
-		return NewSetRXVolumeButton(m.MidiKey(), m.TRX, vfo, led, value, tciClient), ButtonControl, nil
-	}
-	Factories[RXBalanceMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		vfo, err := AtoVFO(m.VFO)
-		if err != nil {
-			return nil, 0, err
-		}
-		controlType, stepSize, reverseDirection, dynamicMode, err := m.ValueControlOptions(1)
-		if err != nil {
-			return nil, 0, err
-		}
-		return NewRXBalanceControl(m.MidiKey(), m.TRX, vfo, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
-	}
-	Factories[SetRXBalanceMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		vfo, err := AtoVFO(m.VFO)
-		if err != nil {
-			return nil, 0, err
-		}
-		value, set, err := m.RequiredIntOption("balance")
-		if err != nil {
-			return nil, 0, err
-		}
-		if !set {
-			return nil, 0, fmt.Errorf("no balance configured. Use options[\"balance\"]=\"<balance in the range -40 to 40>\" to configure the balance")
-		}
-		return NewSetRXBalanceButton(m.MidiKey(), m.TRX, vfo, led, value, tciClient), ButtonControl, nil
-	}
+	Register(Definition{
+		Type:        EnableRXMapping,
+		Description: "Switches the RX channel of the VFO on and off.",
+		Control:     ButtonControl,
+		VFO:         true,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			vfo, err := AtoVFO(m.VFO)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewRXChannelEnableButton(m.MidiKey(), m.TRX, vfo, led, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         RXVolumeMapping,
+		Description:  "Controls the volume of the RX channel of the VFO.",
+		ValueControl: true,
+		VFO:          true,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			vfo, err := AtoVFO(m.VFO)
+			if err != nil {
+				return nil, 0, err
+			}
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewRXVolumeControl(m.MidiKey(), m.TRX, vfo, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
+		},
+	})
+	Register(Definition{
+		Type:        SetRXVolumeMapping,
+		Description: "Sets the volume of the RX channel of the VFO.",
+		Control:     ButtonControl,
+		VFO:         true,
+		Options: []OptionSchema{
+			{Name: "volume", Type: IntOptionType, Description: "the volume in dB", Required: true, Range: &[2]int{-60, 0}},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			vfo, err := AtoVFO(m.VFO)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, err := Definitions[m.Type].IntOption(m, "volume")
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewSetRXVolumeButton(m.MidiKey(), m.TRX, vfo, led, value, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:         RXBalanceMapping,
+		Description:  "Controls the balance of the RX channel of the VFO.",
+		ValueControl: true,
+		VFO:          true,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			vfo, err := AtoVFO(m.VFO)
+			if err != nil {
+				return nil, 0, err
+			}
+			controlType, stepSize, reverseDirection, dynamicMode, err := Definitions[m.Type].ValueControlOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewRXBalanceControl(m.MidiKey(), m.TRX, vfo, controlType, led, stepSize, reverseDirection, dynamicMode, tciClient), controlType, nil
+		},
+	})
+	Register(Definition{
+		Type:        SetRXBalanceMapping,
+		Description: "Sets the balance of the RX channel of the VFO.",
+		Control:     ButtonControl,
+		VFO:         true,
+		Options: []OptionSchema{
+			{Name: "balance", Type: IntOptionType, Description: "the balance", Required: true, Range: &[2]int{-40, 40}},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			vfo, err := AtoVFO(m.VFO)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, err := Definitions[m.Type].IntOption(m, "balance")
+			if err != nil {
+				return nil, 0, err
+			}
+			return NewSetRXBalanceButton(m.MidiKey(), m.TRX, vfo, led, value, tciClient), ButtonControl, nil
+		},
+	})
 }
 
 func NewRXChannelEnableButton(key MidiKey, trx int, vfo client.VFO, led LED, rxChannelEnabler RXChannelEnabler) *RXChannelEnableButton {
@@ -0,0 +1,435 @@
+package ctrl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OptionType string
+
+const (
+	StringOptionType   OptionType = "string"
+	IntOptionType      OptionType = "int"
+	FloatOptionType    OptionType = "float"
+	BoolOptionType     OptionType = "bool"
+	DurationOptionType OptionType = "duration"
+	VFOOptionType      OptionType = "vfo"
+	ColorOptionType    OptionType = "color"
+	KeyListOptionType  OptionType = "keys"
+)
+
+// OptionSchema describes an option of a mapping. If values are given, the option must have one of these values.
+// Aliases are alternative spellings of the values that are still accepted, they map to the value that replaces them.
+// If a range is given, the value of an int option must be within this range. A prefix option matches all options
+// that start with its name followed by an underscore and one of its suffixes, e.g. offset_cw. The default value
+// is used if the option is not set.
+type OptionSchema struct {
+	Name        string
+	Type        OptionType
+	Description string
+	Default     string
+	Required    bool
+	Values      []string
+	Aliases     map[string]string
+	Range       *[2]int
+	Prefix      bool
+	Suffixes    []string
+}
+
+// Definition describes a mapping type: its name, what it does, the kind of control that is used, the fields and
+// options of the mapping, and the factory that creates the control. Value controls are either potis or encoders,
+// depending on options["control"]. A deprecated mapping type names the mapping type that replaces it in ReplacedBy.
+// Check is optional, it checks the options that depend on each other or on the key of the mapping.
+type Definition struct {
+	Type         MappingType
+	Description  string
+	Control      ControlType
+	ValueControl bool
+	VFO          bool
+	Options      []OptionSchema
+	Factory      ControlFactory
+	Check        func(m Mapping) error
+	ReplacedBy   MappingType
+}
+
+// Definitions contains the definitions of all registered mapping types.
+var Definitions = make(map[MappingType]Definition)
+
+// Register makes the given mapping type available for the configuration.
+func Register(definition Definition) {
+	if _, ok := Definitions[definition.Type]; ok {
+		panic(fmt.Sprintf("mapping type %s is already registered", definition.Type))
+	}
+	if definition.Factory == nil {
+		panic(fmt.Sprintf("mapping type %s has no factory", definition.Type))
+	}
+	Definitions[definition.Type] = definition
+}
+
+// RegisterDeprecated keeps the given deprecated name of a mapping type working. The registered mapping type
+// that replaces it must be registered before.
+func RegisterDeprecated(deprecated MappingType, replacement MappingType) {
+	definition, ok := Definitions[replacement]
+	if !ok {
+		panic(fmt.Sprintf("mapping type %s is not registered", replacement))
+	}
+	definition.Type = deprecated
+	definition.Description = fmt.Sprintf("Deprecated, use %s instead.", replacement)
+	definition.ReplacedBy = replacement
+	Register(definition)
+}
+
+// MappingTypes returns the names of all registered mapping types in alphabetical order.
+func MappingTypes() []MappingType {
+	result := make([]MappingType, 0, len(Definitions))
+	for mappingType := range Definitions {
+		result = append(result, mappingType)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+var (
+	// CommonOptionSchemas can be used with all mapping types, they configure the LED of the control.
+	CommonOptionSchemas = []OptionSchema{
+		{Name: "color_on", Type: ColorOptionType, Description: "the color of the LED while the function is on, a name from the palette or #rrggbb"},
+		{Name: "color_off", Type: ColorOptionType, Description: "the color of the LED while the function is off"},
+		{Name: "color_flashing", Type: ColorOptionType, Description: "the color of the LED while it is flashing"},
+		{Name: "animation", Type: StringOptionType, Description: "the animation that is shown instead of the flashing state", Values: animationNames()},
+		{Name: "animation_period", Type: IntOptionType, Description: "the period of the animation in milliseconds", Range: &[2]int{1, 60000}},
+		{Name: "chase_keys", Type: KeyListOptionType, Description: "the additional keys of a chase animation on the same channel, separated by commas"},
+		{Name: "touch_key", Type: IntOptionType, Description: "the key on the same channel that signals that the motor fader is touched", Range: &[2]int{0, 127}},
+		{Name: "feedback_delay", Type: IntOptionType, Description: "the time in milliseconds after the last movement until the value is sent back to the motor fader", Default: "500", Range: &[2]int{0, 60000}},
+	}
+
+	// ValueControlOptionSchemas can be used with all value controls.
+	ValueControlOptionSchemas = []OptionSchema{
+		{Name: "control", Type: StringOptionType, Description: "the kind of the control", Default: "poti", Values: []string{"poti", "encoder"}},
+		{Name: "step", Type: IntOptionType, Description: "the step size of an encoder", Default: "1", Range: &[2]int{1, 1000000}, Aliases: map[string]string{"0": "1"}},
+		DirectionOptionSchema("the direction of an encoder"),
+		SpeedOptionSchema("the speed of an encoder, a dynamic encoder takes larger steps when it is turned faster"),
+	}
+
+	// Modes contains the names of the modes that are known by ExpertSDR.
+	Modes = []string{"am", "sam", "dsb", "lsb", "usb", "cw", "nfm", "wfm", "digl", "digu", "spec", "drm"}
+)
+
+// DirectionOptionSchema describes the direction of an encoder.
+func DirectionOptionSchema(description string) OptionSchema {
+	return OptionSchema{Name: "direction", Type: StringOptionType, Description: description, Default: "normal", Values: []string{"normal", "reverse"}, Aliases: map[string]string{"default": "normal"}}
+}
+
+// SpeedOptionSchema describes the speed of an encoder.
+func SpeedOptionSchema(description string) OptionSchema {
+	return OptionSchema{Name: "speed", Type: StringOptionType, Description: description, Default: "static", Values: []string{"static", "dynamic"}, Aliases: map[string]string{"default": "static"}}
+}
+
+func animationNames() []string {
+	result := make([]string, 0, len(Animations))
+	for name := range Animations {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SchemaError is a violation of the schema of a mapping. Field is the path of the field within the mapping,
+// e.g. "vfo" or "options/step".
+type SchemaError struct {
+	Field string
+	Err   error
+}
+
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// Controls returns the kinds of controls that can be used with this mapping type.
+func (d Definition) Controls() []ControlType {
+	if d.ValueControl {
+		return []ControlType{PotiControl, EncoderControl}
+	}
+	return []ControlType{d.Control}
+}
+
+// ControlType returns the type of the control that is created for the given mapping.
+func (d Definition) ControlType(m Mapping) ControlType {
+	if !d.ValueControl {
+		return d.Control
+	}
+	if strings.ToLower(strings.TrimSpace(m.Options["control"])) == "encoder" {
+		return EncoderControl
+	}
+	return PotiControl
+}
+
+// Validate checks the given mapping against the definition and returns all violations.
+func (d Definition) Validate(m Mapping) []SchemaError {
+	var result []SchemaError
+	if m.Channel > 15 {
+		result = append(result, SchemaError{Field: "channel", Err: fmt.Errorf("invalid channel %d, use 0 to 15", m.Channel)})
+	}
+	if m.Key < PitchbendKey {
+		result = append(result, SchemaError{Field: "key", Err: fmt.Errorf("invalid key %d, use -1 to 127", m.Key)})
+	}
+	if d.VFO {
+		if _, err := AtoVFO(m.VFO); err != nil {
+			result = append(result, SchemaError{Field: "vfo", Err: err})
+		}
+	}
+
+	options := d.AllOptions()
+	for _, option := range options {
+		if option.Required && !option.Prefix {
+			if _, ok := m.Options[option.Name]; !ok {
+				result = append(result, SchemaError{Field: "options/" + option.Name, Err: fmt.Errorf("the option %s is required", option.Name)})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(m.Options))
+	for name := range m.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		option, ok := findOption(options, name)
+		if !ok {
+			result = append(result, SchemaError{Field: "options/" + name, Err: fmt.Errorf("unknown option %s", name)})
+			continue
+		}
+		err := option.Validate(m.Options[name])
+		if err != nil {
+			result = append(result, SchemaError{Field: "options/" + name, Err: err})
+		}
+	}
+
+	if len(result) == 0 && d.Check != nil {
+		err := d.Check(m)
+		var schemaErr SchemaError
+		if errors.As(err, &schemaErr) {
+			result = append(result, schemaErr)
+		} else if err != nil {
+			result = append(result, SchemaError{Field: "options", Err: err})
+		}
+	}
+
+	return result
+}
+
+// AllOptions returns the options of this mapping type, including the options of the LED and of value controls.
+func (d Definition) AllOptions() []OptionSchema {
+	result := append([]OptionSchema{}, d.Options...)
+	if d.ValueControl {
+		result = append(result, ValueControlOptionSchemas...)
+	}
+	return append(result, CommonOptionSchemas...)
+}
+
+// IntOption returns the value of the given int option of the mapping, or the default value of the option. The value
+// is checked against the range of the option. It is an error if the option has neither a value nor a default value.
+func (d Definition) IntOption(m Mapping, name string) (int, error) {
+	value, set, err := d.OptionalIntOption(m, name)
+	if err == nil && !set {
+		err = fmt.Errorf("the option %s is required", name)
+	}
+	return value, err
+}
+
+// OptionalIntOption returns the value of the given int option of the mapping, or the default value of the option.
+// It returns false if the option has neither a value nor a default value.
+func (d Definition) OptionalIntOption(m Mapping, name string) (int, bool, error) {
+	value, set, err := d.optionValue(m, name)
+	if err != nil || !set {
+		return 0, false, err
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return result, true, nil
+}
+
+// FloatOption returns the value of the given float option of the mapping, or the default value of the option.
+func (d Definition) FloatOption(m Mapping, name string) (float64, error) {
+	value, set, err := d.optionValue(m, name)
+	if err != nil {
+		return 0, err
+	}
+	if !set {
+		return 0, fmt.Errorf("the option %s is required", name)
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// BoolOption returns the value of the given bool option of the mapping, or the default value of the option.
+func (d Definition) BoolOption(m Mapping, name string) (bool, error) {
+	value, _, err := d.optionValue(m, name)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// StringOption returns the value of the given option of the mapping, or the default value of the option. If the
+// option has a list of values, the value is returned in the spelling of this list. It returns an empty string if
+// the option has neither a value nor a default value.
+func (d Definition) StringOption(m Mapping, name string) (string, error) {
+	value, _, err := d.optionValue(m, name)
+	return value, err
+}
+
+func (d Definition) optionValue(m Mapping, name string) (string, bool, error) {
+	option, ok := findOption(d.AllOptions(), name)
+	if !ok {
+		return "", false, fmt.Errorf("%s has no option %s", d.Type, name)
+	}
+	value, ok := m.Options[name]
+	if !ok {
+		value = option.Default
+	}
+	value = strings.TrimSpace(value)
+	if !ok && value == "" {
+		return "", false, nil
+	}
+	err := option.Validate(value)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	value, _ = option.Canonical(value)
+	return value, true, nil
+}
+
+// ValueControlOptions reads the kind of the control from options["control"], the step size of an encoder from
+// options["step"], and the direction and the speed of an encoder.
+func (d Definition) ValueControlOptions(m Mapping) (controlType ControlType, stepSize int, reverseDirection bool, dynamicMode bool, err error) {
+	controlType = d.ControlType(m)
+	stepSize, err = d.IntOption(m, "step")
+	if err != nil {
+		return
+	}
+	reverseDirection, dynamicMode, err = d.EncoderOptions(m)
+	return
+}
+
+// EncoderOptions reads the direction and the speed of an encoder from options["direction"] and options["speed"].
+func (d Definition) EncoderOptions(m Mapping) (reverseDirection bool, dynamicMode bool, err error) {
+	direction, err := d.StringOption(m, "direction")
+	if err != nil {
+		return false, false, err
+	}
+	speed, err := d.StringOption(m, "speed")
+	if err != nil {
+		return false, false, err
+	}
+	return direction == "reverse", speed == "dynamic", nil
+}
+
+func findOption(options []OptionSchema, name string) (OptionSchema, bool) {
+	for _, option := range options {
+		if option.Name == name {
+			return option, true
+		}
+		if option.Prefix && strings.HasPrefix(name, option.Name+"_") && contains(option.Suffixes, strings.TrimPrefix(name, option.Name+"_")) {
+			return option, true
+		}
+	}
+	return OptionSchema{}, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the given value of the option.
+func (o OptionSchema) Validate(value string) error {
+	value, _ = o.Canonical(value)
+	switch o.Type {
+	case IntOptionType:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		if o.Range != nil && (i < o.Range[0] || i > o.Range[1]) {
+			return fmt.Errorf("%d is out of range, use %d to %d", i, o.Range[0], o.Range[1])
+		}
+	case FloatOptionType:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case BoolOptionType:
+		switch strings.ToLower(value) {
+		case "on", "off", "true", "false", "yes", "no", "1", "0":
+		default:
+			return fmt.Errorf("%q is not a boolean value, use true or false", value)
+		}
+	case DurationOptionType:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%q is not a duration, use e.g. 5s", value)
+		}
+	case VFOOptionType:
+		if _, err := AtoVFO(value); err != nil {
+			return err
+		}
+	case ColorOptionType:
+		if _, err := ParseColor(value); err != nil {
+			return err
+		}
+	case KeyListOptionType:
+		for _, field := range strings.Split(value, ",") {
+			key, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || key < 0 || key > 127 {
+				return fmt.Errorf("%q is not a valid key", field)
+			}
+		}
+	}
+
+	if len(o.Values) == 0 {
+		return nil
+	}
+	if _, ok := o.Canonical(value); ok {
+		return nil
+	}
+	return fmt.Errorf("invalid value %q, use one of %s", value, strings.Join(o.Values, ", "))
+}
+
+// Canonical returns the spelling of the given value as it is listed in the values of the option. An alias is
+// replaced by its value.
+func (o OptionSchema) Canonical(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	for _, allowed := range o.Values {
+		if strings.EqualFold(value, allowed) {
+			return allowed, true
+		}
+	}
+	for alias, allowed := range o.Aliases {
+		if strings.EqualFold(value, alias) {
+			return allowed, true
+		}
+	}
+	return value, false
+}
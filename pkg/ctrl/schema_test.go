@@ -0,0 +1,258 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefinitions(t *testing.T) {
+	for _, mappingType := range MappingTypes() {
+		definition := Definitions[mappingType]
+		assert.NotEmpty(t, definition.Description, "no description for %s", mappingType)
+		for _, option := range definition.AllOptions() {
+			assert.NotEmpty(t, option.Description, "no description for option %s of %s", option.Name, mappingType)
+			if option.Default != "" {
+				assert.NoError(t, option.Validate(option.Default), "invalid default of option %s of %s", option.Name, mappingType)
+			}
+		}
+	}
+}
+
+func TestDefinition_Validate(t *testing.T) {
+	tt := []struct {
+		desc     string
+		mapping  Mapping
+		expected []string
+	}{
+		{
+			desc:    "valid button",
+			mapping: Mapping{Type: MOXMapping, Channel: 1, Key: 12, Options: map[string]string{"color_on": "red"}},
+		},
+		{
+			desc:    "valid value control",
+			mapping: Mapping{Type: RXVolumeMapping, VFO: "VFOB", Options: map[string]string{"control": "Encoder", "step": "2", "direction": "reverse"}},
+		},
+		{
+			desc:    "zero step for the default step",
+			mapping: Mapping{Type: VolumeMapping, Options: map[string]string{"control": "encoder", "step": "0"}},
+		},
+		{
+			desc:    "prefix option",
+			mapping: Mapping{Type: EnableSplitMapping, Options: map[string]string{"offset": "1000", "offset_ssb": "5000"}},
+		},
+		{
+			desc:     "unknown prefix option",
+			mapping:  Mapping{Type: EnableSplitMapping, Options: map[string]string{"offset_foo": "1000"}},
+			expected: []string{"options/offset_foo"},
+		},
+		{
+			desc:     "invalid channel",
+			mapping:  Mapping{Type: MOXMapping, Channel: 16},
+			expected: []string{"channel"},
+		},
+		{
+			desc:     "invalid VFO",
+			mapping:  Mapping{Type: EnableRXMapping, VFO: "VFOC"},
+			expected: []string{"vfo"},
+		},
+		{
+			desc:     "missing required option",
+			mapping:  Mapping{Type: ModeMapping},
+			expected: []string{"options/mode"},
+		},
+		{
+			desc:     "unknown option",
+			mapping:  Mapping{Type: MOXMapping, Options: map[string]string{"colour_on": "red"}},
+			expected: []string{"options/colour_on"},
+		},
+		{
+			desc:     "value control option on button",
+			mapping:  Mapping{Type: MuteMapping, Options: map[string]string{"step": "1"}},
+			expected: []string{"options/step"},
+		},
+		{
+			desc:     "wrong types",
+			mapping:  Mapping{Type: SendCWMapping, Options: map[string]string{"repeat": "often", "color_on": "mauve", "animation_period": "fast"}},
+			expected: []string{"options/animation_period", "options/color_on", "options/repeat"},
+		},
+		{
+			desc:     "out of range",
+			mapping:  Mapping{Type: CWPaddleMapping, Options: map[string]string{"paddle": "dit", "weight": "80"}},
+			expected: []string{"options/weight"},
+		},
+		{
+			desc:     "invalid value",
+			mapping:  Mapping{Type: ModeMapping, Options: map[string]string{"mode": "ssb"}},
+			expected: []string{"options/mode"},
+		},
+		{
+			desc:    "meter bar up to key 127",
+			mapping: Mapping{Type: SMeterMapping, Key: 120, Options: map[string]string{"output": "notes", "count": "8"}},
+		},
+		{
+			desc:     "meter bar beyond key 127",
+			mapping:  Mapping{Type: SMeterMapping, Key: 100, Options: map[string]string{"output": "notes", "count": "50"}},
+			expected: []string{"options/count"},
+		},
+		{
+			desc:     "meter without decay",
+			mapping:  Mapping{Type: TXPowerMapping, Options: map[string]string{"decay": "-1"}},
+			expected: []string{"options/decay"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			errs := Definitions[tc.mapping.Type].Validate(tc.mapping)
+			actual := make([]string, 0, len(errs))
+			for _, err := range errs {
+				actual = append(actual, err.Field)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestDefinition_ControlType(t *testing.T) {
+	assert.Equal(t, ButtonControl, Definitions[MOXMapping].ControlType(Mapping{Type: MOXMapping}))
+	assert.Equal(t, PotiControl, Definitions[VolumeMapping].ControlType(Mapping{Type: VolumeMapping}))
+	assert.Equal(t, EncoderControl, Definitions[VolumeMapping].ControlType(Mapping{Type: VolumeMapping, Options: map[string]string{"control": "encoder"}}))
+	assert.Equal(t, EncoderControl, Definitions[VFOMapping].ControlType(Mapping{Type: VFOMapping}))
+}
+
+func TestMigrate(t *testing.T) {
+	tt := []struct {
+		desc     string
+		mapping  Mapping
+		expected Mapping
+		upgrades int
+	}{
+		{
+			desc:     "current mapping",
+			mapping:  Mapping{Type: MOXMapping, Channel: 1, Key: 12},
+			expected: Mapping{Type: MOXMapping, Channel: 1, Key: 12},
+		},
+		{
+			desc:     "deprecated mapping type",
+			mapping:  Mapping{Type: "experimental_rx_mixer", Options: map[string]string{"control": "encoder"}},
+			expected: Mapping{Type: MixerBalanceMapping, Options: map[string]string{"control": "encoder"}},
+			upgrades: 1,
+		},
+		{
+			desc:     "aliases",
+			mapping:  Mapping{Type: VFOMapping, VFO: "a", Options: map[string]string{"direction": "default", "speed": "Default", "step": " 5"}},
+			expected: Mapping{Type: VFOMapping, VFO: "VFOA", Options: map[string]string{"direction": "normal", "speed": "static", "step": "5"}},
+			upgrades: 2,
+		},
+		{
+			desc:     "zero step",
+			mapping:  Mapping{Type: VolumeMapping, Options: map[string]string{"control": "encoder", "step": "0"}},
+			expected: Mapping{Type: VolumeMapping, Options: map[string]string{"control": "encoder", "step": "1"}},
+			upgrades: 1,
+		},
+		{
+			desc:     "spelling",
+			mapping:  Mapping{Type: ModeMapping, Options: map[string]string{"mode": "CW", "color_on": "Red"}},
+			expected: Mapping{Type: ModeMapping, Options: map[string]string{"mode": "cw", "color_on": "Red"}},
+		},
+		{
+			desc:     "bool option",
+			mapping:  Mapping{Type: EnableRITMapping, Options: map[string]string{"reset": "Yes"}},
+			expected: Mapping{Type: EnableRITMapping, Options: map[string]string{"reset": "true"}},
+		},
+		{
+			desc:     "vfo option",
+			mapping:  Mapping{Type: SyncVFOFrequencyMapping, VFO: "B", Options: map[string]string{"src_vfo": "a", "unknown": " x "}},
+			expected: Mapping{Type: SyncVFOFrequencyMapping, VFO: "VFOB", Options: map[string]string{"src_vfo": "VFOA", "unknown": " x "}},
+		},
+		{
+			desc:     "paddle",
+			mapping:  Mapping{Type: CWPaddleMapping, Options: map[string]string{"paddle": "dot", "keyer": "a"}},
+			expected: Mapping{Type: CWPaddleMapping, Options: map[string]string{"paddle": "dit", "keyer": "iambic_a"}},
+			upgrades: 2,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, upgrades := Migrate(tc.mapping)
+			assert.Equal(t, tc.expected, actual)
+			assert.Len(t, upgrades, tc.upgrades)
+		})
+	}
+}
+
+func TestDefinition_IntOption(t *testing.T) {
+	tt := []struct {
+		desc     string
+		mapping  Mapping
+		name     string
+		expected int
+		set      bool
+		invalid  bool
+	}{
+		{
+			desc:     "value",
+			mapping:  Mapping{Type: VFOMapping, Options: map[string]string{"step": "50"}},
+			name:     "step",
+			expected: 50,
+			set:      true,
+		},
+		{
+			desc:     "default",
+			mapping:  Mapping{Type: VFOMapping},
+			name:     "step",
+			expected: 10,
+			set:      true,
+		},
+		{
+			desc:     "zero step",
+			mapping:  Mapping{Type: VFOMapping, Options: map[string]string{"step": "0"}},
+			name:     "step",
+			expected: 10,
+			set:      true,
+		},
+		{
+			desc:    "out of range",
+			mapping: Mapping{Type: CWPaddleMapping, Options: map[string]string{"paddle": "dit", "weight": "80"}},
+			name:    "weight",
+			invalid: true,
+		},
+		{
+			desc:    "no value and no default",
+			mapping: Mapping{Type: CWPaddleMapping, Options: map[string]string{"paddle": "dit"}},
+			name:    "weight",
+		},
+		{
+			desc:     "prefix option",
+			mapping:  Mapping{Type: EnableSplitMapping, Options: map[string]string{"offset_cw": "500"}},
+			name:     "offset_cw",
+			expected: 500,
+			set:      true,
+		},
+		{
+			desc:    "unknown option",
+			mapping: Mapping{Type: MOXMapping},
+			name:    "step",
+			invalid: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			definition := Definitions[tc.mapping.Type]
+			actual, set, err := definition.OptionalIntOption(tc.mapping, tc.name)
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.set, set)
+
+			_, err = definition.IntOption(tc.mapping, tc.name)
+			assert.Equal(t, !tc.set, err != nil)
+		})
+	}
+}
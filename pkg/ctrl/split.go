@@ -3,6 +3,7 @@ package ctrl
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/ftl/tci/client"
 )
@@ -10,50 +11,111 @@ import (
 const (
 	EnableSplitMapping      MappingType = "enable_split"
 	SyncVFOFrequencyMapping MappingType = "sync_vfo_frequency"
+	ListenTXMapping         MappingType = "listen_tx"
+
+	splitOffsetOption = "offset"
 )
 
 func init() {
-	Factories[EnableSplitMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		return NewSplitEnableButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
-	}
-	Factories[SyncVFOFrequencyMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		vfo, err := AtoVFO(m.VFO)
-		if err != nil {
-			return nil, 0, err
-		}
+	Register(Definition{
+		Type:        EnableSplitMapping,
+		Description: "Switches split operation on and off, VFO B is placed at the configured offset from VFO A.",
+		Control:     ButtonControl,
+		Options: []OptionSchema{
+			{Name: splitOffsetOption, Type: IntOptionType, Description: "the offset of VFO B from VFO A in Hz, without any offset VFO B is not moved"},
+			{Name: splitOffsetOption, Type: IntOptionType, Description: "the offset in Hz that is used only in the given mode, e.g. offset_cw, offset_ssb for LSB and USB", Prefix: true, Suffixes: splitOffsetModes()},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			offsets, err := splitOffsets(m)
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			return NewSplitEnableButton(m.MidiKey(), m.TRX, led, offsets, tciClient, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:        ListenTXMapping,
+		Description: "Moves VFO A to the frequency of VFO B while the button is held.",
+		Control:     ButtonControl,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			return NewListenTXButton(m.MidiKey(), m.TRX, led, tciClient, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:        SyncVFOFrequencyMapping,
+		Description: "Copies the frequency of the source VFO to the VFO.",
+		Control:     ButtonControl,
+		VFO:         true,
+		Options: []OptionSchema{
+			{Name: "src_trx", Type: IntOptionType, Description: "the TRX of the source VFO", Required: true},
+			{Name: "src_vfo", Type: VFOOptionType, Description: "the source VFO", Required: true},
+			{Name: "offset", Type: IntOptionType, Description: "the offset in Hz that is added to the frequency", Default: "0"},
+		},
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			vfo, err := AtoVFO(m.VFO)
+			if err != nil {
+				return nil, 0, err
+			}
 
-		srcTRX, set, err := m.RequiredIntOption("src_trx")
-		if err != nil {
-			return nil, ButtonControl, fmt.Errorf("invalid source TRX: %w", err)
-		}
-		if !set {
-			return nil, ButtonControl, fmt.Errorf("no source TRX configured. Use options[\"src_trx\"]=\"<source TRX>\" to configure the source TRX")
-		}
+			definition := Definitions[m.Type]
+			srcTRX, err := definition.IntOption(m, "src_trx")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			srcVFOStr, err := definition.StringOption(m, "src_vfo")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
+			if srcVFOStr == "" {
+				return nil, ButtonControl, fmt.Errorf("no source VFO configured. Use options[\"src_vfo\"]=\"<source VFO>\" to configure the source VFO")
+			}
+			srcVFO, err := AtoVFO(srcVFOStr)
+			if err != nil {
+				return nil, ButtonControl, fmt.Errorf("invalid source VFO %s: %w", srcVFOStr, err)
+			}
+			offset, err := definition.IntOption(m, "offset")
+			if err != nil {
+				return nil, ButtonControl, err
+			}
 
-		srcVFOStr, ok := m.Options["src_vfo"]
-		if !ok {
-			return nil, ButtonControl, fmt.Errorf("no source VFO configured. Use options[\"src_vfo\"]=\"<source VFO>\" to configure the source VFO")
-		}
-		srcVFO, err := AtoVFO(srcVFOStr)
-		if err != nil {
-			return nil, ButtonControl, fmt.Errorf("invalid source VFO %s: %w", srcVFOStr, err)
-		}
+			return NewSyncVFOFrequencyButton(srcTRX, srcVFO, m.TRX, vfo, offset, tciClient, tciClient), ButtonControl, nil
+		},
+	})
+}
 
-		offset, err := m.IntOption("offset", 0)
+// splitOffsetModes returns the modes that can have their own offset: all modes, and ssb for LSB and USB.
+func splitOffsetModes() []string {
+	return append(append([]string{}, Modes...), "ssb")
+}
+
+// splitOffsets reads the offsets of VFO B from the options. options["offset"] is used for all modes,
+// options["offset_<mode>"] is used only for the given mode, options["offset_ssb"] is used for LSB and USB.
+func splitOffsets(m Mapping) (map[client.Mode]int, error) {
+	definition := Definitions[m.Type]
+	result := make(map[client.Mode]int)
+	for name := range m.Options {
+		if name != splitOffsetOption && !strings.HasPrefix(name, splitOffsetOption+"_") {
+			continue
+		}
+		// unknown modes are reported by IntOption
+		offset, err := definition.IntOption(m, name)
 		if err != nil {
-			return nil, ButtonControl, fmt.Errorf("invalid offset: %w", err)
+			return nil, err
 		}
-
-		return NewSyncVFOFrequencyButton(srcTRX, srcVFO, m.TRX, vfo, offset, tciClient, tciClient), ButtonControl, nil
+		mode := strings.TrimPrefix(strings.TrimPrefix(name, splitOffsetOption), "_")
+		result[client.Mode(strings.ToLower(mode))] = offset
 	}
+	return result, nil
 }
 
-func NewSplitEnableButton(key MidiKey, trx int, led LED, splitEnabler SplitEnabler) *SplitEnableButton {
+func NewSplitEnableButton(key MidiKey, trx int, led LED, offsets map[client.Mode]int, splitEnabler SplitEnabler, provider VFOFrequencyProvider) *SplitEnableButton {
 	return &SplitEnableButton{
 		key:          key,
 		trx:          trx,
 		led:          led,
 		splitEnabler: splitEnabler,
+		provider:     provider,
+		offsets:      offsets,
 	}
 }
 
@@ -62,21 +124,62 @@ type SplitEnableButton struct {
 	trx          int
 	led          LED
 	splitEnabler SplitEnabler
+	provider     VFOFrequencyProvider
+
+	offsets map[client.Mode]int
 
 	enabled bool
+	mode    client.Mode
 }
 
 type SplitEnabler interface {
 	SetSplitEnable(int, bool) error
+	SetVFOFrequency(trx int, vfo client.VFO, frequency int) error
 }
 
 func (b *SplitEnableButton) Pressed() {
-	err := b.splitEnabler.SetSplitEnable(b.trx, !b.enabled)
+	enable := !b.enabled
+	if enable {
+		b.placeVFOB()
+	}
+	err := b.splitEnabler.SetSplitEnable(b.trx, enable)
 	if err != nil {
 		log.Print(err)
 	}
 }
 
+// placeVFOB moves VFO B to the configured offset from VFO A. The mode is set per TRX, therefore VFO B
+// always uses the same mode as VFO A.
+func (b *SplitEnableButton) placeVFOB() {
+	offset, ok := b.offsets[b.mode]
+	if !ok && (b.mode == client.ModeLSB || b.mode == client.ModeUSB) {
+		offset, ok = b.offsets["ssb"]
+	}
+	if !ok {
+		offset, ok = b.offsets[""]
+	}
+	if !ok {
+		return
+	}
+
+	frequency, err := b.provider.VFOFrequency(b.trx, client.VFOA)
+	if err != nil {
+		log.Printf("Cannot read VFO frequency: %v", err)
+		return
+	}
+	err = b.splitEnabler.SetVFOFrequency(b.trx, client.VFOB, frequency+offset)
+	if err != nil {
+		log.Printf("Cannot write VFO frequency: %v", err)
+	}
+}
+
+func (b *SplitEnableButton) SetMode(trx int, mode client.Mode) {
+	if trx != b.trx {
+		return
+	}
+	b.mode = mode
+}
+
 func (b *SplitEnableButton) SetSplitEnable(trx int, enabled bool) {
 	if trx != b.trx {
 		return
@@ -124,3 +227,64 @@ func (b *SyncVFOFrequencyButton) Pressed() {
 		log.Printf("Cannot write VFO frequency: %v", err)
 	}
 }
+
+func NewListenTXButton(key MidiKey, trx int, led LED, controller VFOFrequencyController, provider VFOFrequencyProvider) *ListenTXButton {
+	return &ListenTXButton{
+		key:        key,
+		trx:        trx,
+		led:        led,
+		controller: controller,
+		provider:   provider,
+	}
+}
+
+// ListenTXButton moves the RX VFO (VFO A) temporarily to the frequency of the TX VFO (VFO B) while it is held.
+type ListenTXButton struct {
+	key        MidiKey
+	trx        int
+	led        LED
+	controller VFOFrequencyController
+	provider   VFOFrequencyProvider
+
+	listening   bool
+	rxFrequency int
+}
+
+func (b *ListenTXButton) Pressed() {
+	if b.listening {
+		return
+	}
+
+	rxFrequency, err := b.provider.VFOFrequency(b.trx, client.VFOA)
+	if err != nil {
+		log.Printf("Cannot read VFO frequency: %v", err)
+		return
+	}
+	txFrequency, err := b.provider.VFOFrequency(b.trx, client.VFOB)
+	if err != nil {
+		log.Printf("Cannot read VFO frequency: %v", err)
+		return
+	}
+
+	err = b.controller.SetVFOFrequency(b.trx, client.VFOA, txFrequency)
+	if err != nil {
+		log.Printf("Cannot write VFO frequency: %v", err)
+		return
+	}
+	b.listening = true
+	b.rxFrequency = rxFrequency
+	b.led.SetOn(b.key, true)
+}
+
+func (b *ListenTXButton) Released() {
+	if !b.listening {
+		return
+	}
+
+	err := b.controller.SetVFOFrequency(b.trx, client.VFOA, b.rxFrequency)
+	if err != nil {
+		log.Printf("Cannot write VFO frequency: %v", err)
+	}
+	b.listening = false
+	b.led.SetOn(b.key, false)
+}
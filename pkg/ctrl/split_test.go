@@ -0,0 +1,187 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/ftl/tci/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type vfoRecorder struct {
+	frequencies map[client.VFO]int
+	split       []bool
+}
+
+func newVFORecorder(vfoA, vfoB int) *vfoRecorder {
+	return &vfoRecorder{frequencies: map[client.VFO]int{client.VFOA: vfoA, client.VFOB: vfoB}}
+}
+
+func (r *vfoRecorder) VFOFrequency(trx int, vfo client.VFO) (int, error) {
+	return r.frequencies[vfo], nil
+}
+
+func (r *vfoRecorder) SetVFOFrequency(trx int, vfo client.VFO, frequency int) error {
+	r.frequencies[vfo] = frequency
+	return nil
+}
+
+func (r *vfoRecorder) SetSplitEnable(trx int, enabled bool) error {
+	r.split = append(r.split, enabled)
+	return nil
+}
+
+type onRecorder struct {
+	LED
+	on map[MidiKey]bool
+}
+
+func (r *onRecorder) SetOn(key MidiKey, on bool) {
+	r.on[key] = on
+}
+
+func TestSplitOffsets(t *testing.T) {
+	tt := []struct {
+		desc     string
+		options  map[string]string
+		expected map[client.Mode]int
+		invalid  bool
+	}{
+		{
+			desc:     "no offset",
+			expected: map[client.Mode]int{},
+		},
+		{
+			desc:     "offset for all modes",
+			options:  map[string]string{"offset": "1000"},
+			expected: map[client.Mode]int{"": 1000},
+		},
+		{
+			desc:     "offsets per mode",
+			options:  map[string]string{"offset": "1000", "offset_cw": "-500", "offset_ssb": "5000", "color_on": "red"},
+			expected: map[client.Mode]int{"": 1000, client.ModeCW: -500, "ssb": 5000},
+		},
+		{
+			desc:    "unknown mode",
+			options: map[string]string{"offset_foo": "1000"},
+			invalid: true,
+		},
+		{
+			desc:    "invalid offset",
+			options: map[string]string{"offset_cw": "far"},
+			invalid: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, err := splitOffsets(Mapping{Type: EnableSplitMapping, Options: tc.options})
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestSplitEnableButton(t *testing.T) {
+	tt := []struct {
+		desc     string
+		offsets  map[client.Mode]int
+		mode     client.Mode
+		enabled  bool
+		expected int
+	}{
+		{
+			desc:     "no offset",
+			offsets:  map[client.Mode]int{},
+			mode:     client.ModeCW,
+			expected: 7010000,
+		},
+		{
+			desc:     "offset for all modes",
+			offsets:  map[client.Mode]int{"": 1000},
+			mode:     client.ModeCW,
+			expected: 7001000,
+		},
+		{
+			desc:     "offset of the mode",
+			offsets:  map[client.Mode]int{"": 1000, client.ModeCW: 500},
+			mode:     client.ModeCW,
+			expected: 7000500,
+		},
+		{
+			desc:     "ssb offset for LSB",
+			offsets:  map[client.Mode]int{"": 1000, "ssb": 5000},
+			mode:     client.ModeLSB,
+			expected: 7005000,
+		},
+		{
+			desc:     "ssb offset for USB",
+			offsets:  map[client.Mode]int{"ssb": 5000},
+			mode:     client.ModeUSB,
+			expected: 7005000,
+		},
+		{
+			desc:     "USB offset before ssb offset",
+			offsets:  map[client.Mode]int{client.ModeUSB: 3000, "ssb": 5000},
+			mode:     client.ModeUSB,
+			expected: 7003000,
+		},
+		{
+			desc:     "ssb offset not for CW",
+			offsets:  map[client.Mode]int{"ssb": 5000},
+			mode:     client.ModeCW,
+			expected: 7010000,
+		},
+		{
+			desc:     "disable split",
+			offsets:  map[client.Mode]int{"": 1000},
+			mode:     client.ModeCW,
+			enabled:  true,
+			expected: 7010000,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			trx := newVFORecorder(7000000, 7010000)
+			led := &onRecorder{on: make(map[MidiKey]bool)}
+			key := MidiKey{Channel: 1, Key: 1}
+			button := NewSplitEnableButton(key, 0, led, tc.offsets, trx, trx)
+			button.SetMode(0, tc.mode)
+			button.SetSplitEnable(0, tc.enabled)
+			button.SetMode(1, client.ModeAM)
+
+			button.Pressed()
+
+			assert.Equal(t, []bool{!tc.enabled}, trx.split)
+			assert.Equal(t, tc.expected, trx.frequencies[client.VFOB])
+			assert.Equal(t, 7000000, trx.frequencies[client.VFOA])
+			assert.Equal(t, tc.enabled, led.on[key])
+		})
+	}
+}
+
+func TestListenTXButton(t *testing.T) {
+	trx := newVFORecorder(7000000, 7010000)
+	led := &onRecorder{on: make(map[MidiKey]bool)}
+	key := MidiKey{Channel: 1, Key: 1}
+	button := NewListenTXButton(key, 0, led, trx, trx)
+
+	button.Pressed()
+	assert.Equal(t, 7010000, trx.frequencies[client.VFOA])
+	assert.True(t, led.on[key])
+
+	// pressing again while listening keeps the original RX frequency
+	button.Pressed()
+	button.Released()
+	assert.Equal(t, 7000000, trx.frequencies[client.VFOA])
+	assert.Equal(t, 7010000, trx.frequencies[client.VFOB])
+	assert.False(t, led.on[key])
+
+	// a release without press does not move VFO A
+	trx.frequencies[client.VFOA] = 7020000
+	button.Released()
+	assert.Equal(t, 7020000, trx.frequencies[client.VFOA])
+}
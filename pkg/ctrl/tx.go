@@ -12,12 +12,22 @@ const (
 )
 
 func init() {
-	Factories[MOXMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		return NewMOXButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
-	}
-	Factories[TuneMapping] = func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		return NewTuneButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
-	}
+	Register(Definition{
+		Type:        MOXMapping,
+		Description: "Switches the transmitter on and off.",
+		Control:     ButtonControl,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			return NewMOXButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
+		},
+	})
+	Register(Definition{
+		Type:        TuneMapping,
+		Description: "Switches the tune mode on and off.",
+		Control:     ButtonControl,
+		Factory: func(m Mapping, led LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			return NewTuneButton(m.MidiKey(), m.TRX, led, tciClient), ButtonControl, nil
+		},
+	})
 }
 
 func NewMOXButton(key MidiKey, trx int, led LED, enabler MOXEnabler) *MOXButton {
@@ -91,5 +101,5 @@ func (b *TuneButton) SetTune(trx int, ptt bool) {
 		return
 	}
 	b.enabled = ptt
-	b.led.SetOn(b.key, ptt)
+	b.led.SetFlashing(b.key, ptt)
 }
@@ -1,9 +1,7 @@
 package ctrl
 
 import (
-	"fmt"
 	"log"
-	"strings"
 
 	"github.com/ftl/tci/client"
 )
@@ -11,31 +9,34 @@ import (
 const VFOMapping MappingType = "vfo"
 
 func init() {
-	Factories[VFOMapping] = func(m Mapping, _ LED, tciClient *client.Client) (interface{}, ControlType, error) {
-		vfo, err := AtoVFO(m.VFO)
-		if err != nil {
-			return nil, 0, err
-		}
-		reverseDirection := false
-		directionStr := m.Options["direction"]
-		if strings.ToLower(directionStr) == "reverse" {
-			reverseDirection = true
-		}
-		dynamicMode := false
-		modeStr := m.Options["speed"]
-		if strings.ToLower(modeStr) == "dynamic" {
-			dynamicMode = true
-		}
-		stepSize, err := m.IntOption("step", 10)
-		if err != nil {
-			return nil, ButtonControl, fmt.Errorf("the step size is invalid: %v", err)
-		}
-		if stepSize == 0 {
-			stepSize = 10
-		}
+	Register(Definition{
+		Type:        VFOMapping,
+		Description: "Tunes the frequency of the VFO.",
+		Control:     EncoderControl,
+		VFO:         true,
+		Options: []OptionSchema{
+			{Name: "step", Type: IntOptionType, Description: "the tuning step in Hz", Default: "10", Range: &[2]int{1, 1000000}, Aliases: map[string]string{"0": "10"}},
+			DirectionOptionSchema("the tuning direction"),
+			SpeedOptionSchema("the tuning speed, a dynamic VFO takes larger steps when it is turned faster"),
+		},
+		Factory: func(m Mapping, _ LED, tciClient *client.Client) (interface{}, ControlType, error) {
+			vfo, err := AtoVFO(m.VFO)
+			if err != nil {
+				return nil, 0, err
+			}
+			definition := Definitions[m.Type]
+			reverseDirection, dynamicMode, err := definition.EncoderOptions(m)
+			if err != nil {
+				return nil, 0, err
+			}
+			stepSize, err := definition.IntOption(m, "step")
+			if err != nil {
+				return nil, 0, err
+			}
 
-		return NewVFOEncoder(m.TRX, vfo, stepSize, reverseDirection, dynamicMode, tciClient), EncoderControl, nil
-	}
+			return NewVFOEncoder(m.TRX, vfo, stepSize, reverseDirection, dynamicMode, tciClient), EncoderControl, nil
+		},
+	})
 }
 
 func NewVFOEncoder(trx int, vfo client.VFO, stepSize int, reverseDirection bool, dynamicMode bool, controller VFOFrequencyController) *VFOEncoder {
@@ -0,0 +1,180 @@
+// Package display shows the TCI state on displays of the MIDI device, e.g. the frequency and the mode.
+package display
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ftl/tci/client"
+)
+
+const defaultInterval = 100 * time.Millisecond
+
+// Config describes a display of the given device: the template is rendered with the state of the given TRX
+// and encoded with the encoder. The display is updated at most every interval milliseconds.
+type Config struct {
+	Name     string            `json:"name"`
+	Device   string            `json:"device,omitempty"`
+	Encoder  string            `json:"encoder"`
+	Template string            `json:"template"`
+	TRX      int               `json:"trx"`
+	Interval int               `json:"interval,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+}
+
+// Writer writes the raw MIDI messages that show the content of a display.
+type Writer interface {
+	SetDisplay(name string, messages [][]byte)
+}
+
+// Validate checks the encoder, the options and the template of the display.
+func (c Config) Validate() error {
+	_, _, err := c.parse()
+	return err
+}
+
+func (c Config) parse() (Encoder, Template, error) {
+	newEncoder, ok := Encoders[c.Encoder]
+	if !ok {
+		return nil, Template{}, fmt.Errorf("unknown display encoder %s", c.Encoder)
+	}
+	encoder, err := newEncoder(c.Options)
+	if err != nil {
+		return nil, Template{}, fmt.Errorf("invalid options of display %s: %w", c.Name, err)
+	}
+	template, err := ParseTemplate(c.Template)
+	if err != nil {
+		return nil, Template{}, err
+	}
+	return encoder, template, nil
+}
+
+// New returns a new display with the given configuration.
+func New(config Config, writer Writer) (*Display, error) {
+	encoder, template, err := config.parse()
+	if err != nil {
+		return nil, err
+	}
+	interval := defaultInterval
+	if config.Interval > 0 {
+		interval = time.Duration(config.Interval) * time.Millisecond
+	}
+	name := config.Name
+	if name == "" {
+		name = config.Encoder
+	}
+
+	result := &Display{
+		name:     name,
+		trx:      config.TRX,
+		template: template,
+		encoder:  encoder,
+		writer:   writer,
+		interval: interval,
+		dirty:    true,
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go result.run()
+
+	return result, nil
+}
+
+// Display renders the template whenever the state changes and writes the result to the device. The updates
+// are throttled to the display's interval.
+type Display struct {
+	name     string
+	trx      int
+	template Template
+	encoder  Encoder
+	writer   Writer
+	interval time.Duration
+
+	mutex  sync.Mutex
+	state  State
+	dirty  bool
+	closed chan struct{}
+	done   chan struct{}
+}
+
+func (d *Display) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	lastText := ""
+	first := true
+	for {
+		select {
+		case <-d.closed:
+			return
+		case <-ticker.C:
+			d.mutex.Lock()
+			dirty := d.dirty
+			state := d.state
+			d.dirty = false
+			d.mutex.Unlock()
+			if !dirty {
+				continue
+			}
+
+			text := d.template.Render(state)
+			if text == lastText && !first {
+				continue
+			}
+			d.writer.SetDisplay(d.name, d.encoder.Encode(text))
+			lastText = text
+			first = false
+		}
+	}
+}
+
+func (d *Display) Close() {
+	select {
+	case <-d.closed:
+		return
+	default:
+		close(d.closed)
+		<-d.done
+	}
+}
+
+func (d *Display) update(trx int, update func(*State)) {
+	if trx != d.trx {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	update(&d.state)
+	d.dirty = true
+}
+
+func (d *Display) SetVFOFrequency(trx int, vfo client.VFO, frequency int) {
+	if vfo != client.VFOA && vfo != client.VFOB {
+		return
+	}
+	d.update(trx, func(s *State) {
+		s.Frequencies[vfo] = frequency
+	})
+}
+
+func (d *Display) SetMode(trx int, mode client.Mode) {
+	d.update(trx, func(s *State) {
+		s.Mode = string(mode)
+	})
+}
+
+func (d *Display) SetRXFilterBand(trx int, min, max int) {
+	d.update(trx, func(s *State) {
+		s.FilterMin = min
+		s.FilterMax = max
+	})
+}
+
+func (d *Display) SetTX(trx int, ptt bool) {
+	d.update(trx, func(s *State) {
+		s.TX = ptt
+	})
+}
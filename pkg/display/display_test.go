@@ -0,0 +1,54 @@
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	state := State{
+		Frequencies: [2]int{14074500, 14080000},
+		Mode:        "cw",
+		FilterMin:   -250,
+		FilterMax:   250,
+		TX:          true,
+	}
+	tt := []struct {
+		template string
+		expected string
+	}{
+		{"{vfo_a_mhz} {mode} {filter_width}", "14.0745 CW 500"},
+		{"{vfo_a}", "14.074.500"},
+		{"B:{vfo_b_khz} {tx}", "B:14080.00 TX"},
+		{"{{{vfo_a_hz}}}", "{14074500}"},
+		{"{filter_low}/{filter_high}", "-250/250"},
+		{"plain text", "plain text"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.template, func(t *testing.T) {
+			template, err := ParseTemplate(tc.template)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, template.Render(state))
+		})
+	}
+}
+
+func TestParseTemplate_Errors(t *testing.T) {
+	_, err := ParseTemplate("{unknown}")
+	assert.Error(t, err)
+	_, err = ParseTemplate("{vfo_a")
+	assert.Error(t, err)
+}
+
+func TestSysExEncoder(t *testing.T) {
+	encoder, err := newSysExEncoder(map[string]string{"sysex": "F0 00 20 29 {text} F7", "width": "4"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{0xF0, 0x00, 0x20, 0x29, 'C', 'W', ' ', ' ', 0xF7}}, encoder.Encode("CW"))
+	assert.Equal(t, [][]byte{{0xF0, 0x00, 0x20, 0x29, '1', '4', '.', '0', 0xF7}}, encoder.Encode("14.074"))
+
+	_, err = newSysExEncoder(map[string]string{"sysex": "F0 00 20 29 F7"})
+	assert.Error(t, err, "missing {text}")
+	_, err = newSysExEncoder(map[string]string{"sysex": "F0 80 {text} F7"})
+	assert.Error(t, err, "invalid data byte")
+}
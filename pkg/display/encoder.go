@@ -0,0 +1,143 @@
+package display
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ftl/midi2tci/pkg/mcu"
+)
+
+// Encoder encodes a text as raw MIDI messages for a specific display.
+type Encoder interface {
+	Encode(text string) [][]byte
+}
+
+type EncoderFunc func(text string) [][]byte
+
+func (f EncoderFunc) Encode(text string) [][]byte {
+	return f(text)
+}
+
+// EncoderFactory creates an encoder with the given options.
+type EncoderFactory func(options map[string]string) (Encoder, error)
+
+// Encoders contains the factories of all available encoders.
+var Encoders = map[string]EncoderFactory{
+	"sysex":        newSysExEncoder,
+	"mcu_timecode": newMCUTimecodeEncoder,
+	"mcu_strip":    newMCUStripEncoder,
+	"mcu_lcd":      newMCULCDEncoder,
+}
+
+// newSysExEncoder returns an encoder that sends the text in a SysEx message. The message is given in options["sysex"]
+// as hex bytes, the placeholder {text} is replaced by the ASCII characters of the text. If options["width"] is set,
+// the text is padded or cut to this width.
+func newSysExEncoder(options map[string]string) (Encoder, error) {
+	template := strings.Fields(options["sysex"])
+	if len(template) > 0 && strings.EqualFold(template[0], "F0") {
+		template = template[1:]
+	}
+	if len(template) > 0 && strings.EqualFold(template[len(template)-1], "F7") {
+		template = template[:len(template)-1]
+	}
+	if len(template) == 0 {
+		return nil, fmt.Errorf("missing sysex option")
+	}
+	hasText := false
+	for _, field := range template {
+		if strings.EqualFold(field, "{text}") {
+			hasText = true
+			continue
+		}
+		value, err := strconv.ParseUint(field, 16, 8)
+		if err != nil || value > 0x7f {
+			return nil, fmt.Errorf("invalid data byte %s in SysEx template", field)
+		}
+	}
+	if !hasText {
+		return nil, fmt.Errorf("missing {text} in SysEx template")
+	}
+	width, err := intOption(options, "width", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncoderFunc(func(text string) [][]byte {
+		if width > 0 {
+			text = fmt.Sprintf("%-*s", width, text)
+			text = text[:width]
+		}
+		result := []byte{0xF0}
+		for _, field := range template {
+			if strings.EqualFold(field, "{text}") {
+				result = append(result, asciiBytes(text)...)
+				continue
+			}
+			value, _ := strconv.ParseUint(field, 16, 8)
+			result = append(result, byte(value))
+		}
+		return [][]byte{append(result, 0xF7)}
+	}), nil
+}
+
+func newMCUTimecodeEncoder(map[string]string) (Encoder, error) {
+	return EncoderFunc(mcu.Timecode), nil
+}
+
+// newMCUStripEncoder returns an encoder for one row (options["row"], 0 or 1) of the scribble strip of the
+// channel strip options["strip"] (0 to 7).
+func newMCUStripEncoder(options map[string]string) (Encoder, error) {
+	strip, err := intOption(options, "strip", 0)
+	if err != nil {
+		return nil, err
+	}
+	row, err := intOption(options, "row", 0)
+	if err != nil {
+		return nil, err
+	}
+	if strip < 0 || strip >= mcu.Strips || row < 0 || row > 1 {
+		return nil, fmt.Errorf("invalid scribble strip %d/%d", strip, row)
+	}
+	return EncoderFunc(func(text string) [][]byte {
+		return [][]byte{mcu.SysEx(mcu.ScribbleStrip(strip, row, text))}
+	}), nil
+}
+
+// newMCULCDEncoder returns an encoder for a whole row (options["row"], 0 or 1) of the MCU LCD.
+func newMCULCDEncoder(options map[string]string) (Encoder, error) {
+	row, err := intOption(options, "row", 0)
+	if err != nil {
+		return nil, err
+	}
+	if row < 0 || row > 1 {
+		return nil, fmt.Errorf("invalid LCD row %d", row)
+	}
+	return EncoderFunc(func(text string) [][]byte {
+		return [][]byte{mcu.SysEx(mcu.LCD(row, text))}
+	}), nil
+}
+
+func intOption(options map[string]string, name string, defaultValue int) (int, error) {
+	str, ok := options[name]
+	if !ok {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return value, nil
+}
+
+func asciiBytes(text string) []byte {
+	result := make([]byte, len(text))
+	for i := range result {
+		c := text[i]
+		if c < 0x20 || c > 0x7e {
+			c = ' '
+		}
+		result[i] = c
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// State is the TCI state that is shown on a display.
+type State struct {
+	Frequencies [2]int
+	Mode        string
+	FilterMin   int
+	FilterMax   int
+	TX          bool
+}
+
+// Variables contains the variables that can be used in the templates, with the function that formats the
+// variable's value.
+var Variables = map[string]func(State) string{
+	"vfo_a":        func(s State) string { return dottedFrequency(s.Frequencies[0]) },
+	"vfo_a_hz":     func(s State) string { return fmt.Sprintf("%d", s.Frequencies[0]) },
+	"vfo_a_khz":    func(s State) string { return fmt.Sprintf("%.2f", float64(s.Frequencies[0])/1000) },
+	"vfo_a_mhz":    func(s State) string { return fmt.Sprintf("%.4f", float64(s.Frequencies[0])/1000000) },
+	"vfo_b":        func(s State) string { return dottedFrequency(s.Frequencies[1]) },
+	"vfo_b_hz":     func(s State) string { return fmt.Sprintf("%d", s.Frequencies[1]) },
+	"vfo_b_khz":    func(s State) string { return fmt.Sprintf("%.2f", float64(s.Frequencies[1])/1000) },
+	"vfo_b_mhz":    func(s State) string { return fmt.Sprintf("%.4f", float64(s.Frequencies[1])/1000000) },
+	"mode":         func(s State) string { return strings.ToUpper(s.Mode) },
+	"filter_width": func(s State) string { return fmt.Sprintf("%d", s.FilterMax-s.FilterMin) },
+	"filter_low":   func(s State) string { return fmt.Sprintf("%d", s.FilterMin) },
+	"filter_high":  func(s State) string { return fmt.Sprintf("%d", s.FilterMax) },
+	"tx": func(s State) string {
+		if s.TX {
+			return "TX"
+		}
+		return "RX"
+	},
+}
+
+// VariableNames returns the names of all variables that can be used in the templates.
+func VariableNames() []string {
+	result := make([]string, 0, len(Variables))
+	for name := range Variables {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// dottedFrequency formats the frequency in Hz as MHz.kHz.Hz.
+func dottedFrequency(frequency int) string {
+	if frequency < 0 {
+		frequency = 0
+	}
+	return fmt.Sprintf("%d.%03d.%03d", frequency/1000000, (frequency/1000)%1000, frequency%1000)
+}
+
+// Template is a text with placeholders like {vfo_a_mhz} that are replaced by the current state.
+type Template struct {
+	parts []templatePart
+}
+
+type templatePart struct {
+	text     string
+	variable func(State) string
+}
+
+// ParseTemplate parses the given template text. Literal braces are written as {{ and }}.
+func ParseTemplate(text string) (Template, error) {
+	var result Template
+	var literal strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '{' && i+1 < len(text) && text[i+1] == '{':
+			literal.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(text) && text[i+1] == '}':
+			literal.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end == -1 {
+				return Template{}, fmt.Errorf("missing } in template %q", text)
+			}
+			name := strings.TrimSpace(text[i+1 : i+end])
+			variable, ok := Variables[strings.ToLower(name)]
+			if !ok {
+				return Template{}, fmt.Errorf("unknown variable {%s} in template %q", name, text)
+			}
+			if literal.Len() > 0 {
+				result.parts = append(result.parts, templatePart{text: literal.String()})
+				literal.Reset()
+			}
+			result.parts = append(result.parts, templatePart{variable: variable})
+			i += end
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() > 0 {
+		result.parts = append(result.parts, templatePart{text: literal.String()})
+	}
+	return result, nil
+}
+
+// Render returns the text of the template for the given state.
+func (t Template) Render(state State) string {
+	var result strings.Builder
+	for _, part := range t.parts {
+		if part.variable != nil {
+			result.WriteString(part.variable(state))
+		} else {
+			result.WriteString(part.text)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,169 @@
+// Package hardware contains the profiles of known MIDI controllers. A profile gives the controls of a device
+// a name, so that mappings can reference the controls by name instead of channel and key.
+package hardware
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/sequence"
+)
+
+//go:embed profiles/*.json
+var profileFiles embed.FS
+
+type MessageType string
+
+const (
+	NoteMessage          MessageType = "note"
+	ControlChangeMessage MessageType = "cc"
+	PitchbendMessage     MessageType = "pitchbend"
+)
+
+// Control is a named control of a device. Control changes and pitch bends come from a poti or an encoder,
+// given in Control. If a motor fader has a touch sensor, the key of the touch sensor is given in TouchKey.
+type Control struct {
+	Name     string      `json:"name"`
+	Message  MessageType `json:"message"`
+	Channel  byte        `json:"channel"`
+	Key      int8        `json:"key"`
+	Control  string      `json:"control,omitempty"`
+	TouchKey *int8       `json:"touch_key,omitempty"`
+}
+
+func (c Control) MidiKey() ctrl.MidiKey {
+	return ctrl.MidiKey{Channel: c.Channel, Key: c.Key}
+}
+
+// Type returns the type of the control.
+func (c Control) Type() ctrl.ControlType {
+	switch {
+	case c.Message == NoteMessage:
+		return ctrl.ButtonControl
+	case c.Control == "encoder":
+		return ctrl.EncoderControl
+	default:
+		return ctrl.PotiControl
+	}
+}
+
+// Profile describes a MIDI controller: its controls, the LED profile and the MIDI sequences that are sent
+// to the device.
+type Profile struct {
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	Indicators         string            `json:"indicators,omitempty"`
+	InitSequence       sequence.Sequence `json:"init_sequence,omitempty"`
+	ConnectSequence    sequence.Sequence `json:"connect_sequence,omitempty"`
+	DisconnectSequence sequence.Sequence `json:"disconnect_sequence,omitempty"`
+	Controls           []Control         `json:"controls"`
+}
+
+// Control returns the control with the given name.
+func (p Profile) Control(name string) (Control, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, control := range p.Controls {
+		if control.Name == name {
+			return control, true
+		}
+	}
+	return Control{}, false
+}
+
+var profiles = loadProfiles()
+
+func loadProfiles() map[string]Profile {
+	entries, err := profileFiles.ReadDir("profiles")
+	if err != nil {
+		panic(err)
+	}
+	result := make(map[string]Profile, len(entries))
+	for _, entry := range entries {
+		data, err := profileFiles.ReadFile(path.Join("profiles", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var profile Profile
+		err = json.Unmarshal(data, &profile)
+		if err != nil {
+			panic(fmt.Sprintf("invalid device profile %s: %v", entry.Name(), err))
+		}
+		result[profile.Name] = profile
+	}
+	return result
+}
+
+var profileAliases = map[string]string{
+	"default": "starlight",
+	"mackie":  "mcu",
+	"x-touch": "mcu",
+}
+
+// Get returns the profile with the given name.
+func Get(name string) (Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := profileAliases[name]; ok {
+		name = alias
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown device profile %s, use one of %s", name, strings.Join(Names(), ", "))
+	}
+	return profile, nil
+}
+
+// Names returns the names of all profiles in alphabetical order.
+func Names() []string {
+	result := make([]string, 0, len(profiles))
+	for name := range profiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Resolve sets the channel and the key of the given mapping from the named control. If the mapping type has a value
+// control, the options of the mapping are completed with the kind of the control and the touch key of a motor fader.
+func (p Profile) Resolve(m ctrl.Mapping) (ctrl.Mapping, error) {
+	control, ok := p.Control(m.Control)
+	if !ok {
+		return m, fmt.Errorf("%s has no control %s", p.Name, m.Control)
+	}
+	definition, ok := ctrl.Definitions[m.Type]
+	if !ok {
+		return m, fmt.Errorf("unknown mapping type %s", m.Type)
+	}
+	// indicators only use the LEDs of the control
+	compatible := definition.Control == ctrl.IndicatorControl
+	for _, controlType := range definition.Controls() {
+		compatible = compatible || controlType == control.Type()
+	}
+	if !compatible {
+		return m, fmt.Errorf("%s is a %s, %s needs a %s", control.Name, control.Type(), m.Type, definition.Controls()[0])
+	}
+
+	m.Channel = control.Channel
+	m.Key = control.Key
+	if !definition.ValueControl {
+		return m, nil
+	}
+	options := make(map[string]string, len(m.Options)+2)
+	for name, value := range m.Options {
+		options[name] = value
+	}
+	if _, ok := options["control"]; !ok && control.Type() == ctrl.EncoderControl {
+		options["control"] = "encoder"
+	}
+	if _, ok := options["touch_key"]; !ok && control.TouchKey != nil {
+		options["touch_key"] = fmt.Sprint(*control.TouchKey)
+	}
+	if len(options) > 0 {
+		m.Options = options
+	}
+	return m, nil
+}
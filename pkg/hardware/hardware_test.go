@@ -0,0 +1,112 @@
+package hardware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+	"github.com/ftl/midi2tci/pkg/led"
+)
+
+func TestProfiles(t *testing.T) {
+	require.NotEmpty(t, Names())
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			profile, err := Get(name)
+			require.NoError(t, err)
+			assert.NotEmpty(t, profile.Description)
+			assert.NotEmpty(t, profile.Controls)
+
+			_, err = led.BuiltinProfile(profile.Indicators)
+			assert.NoError(t, err)
+
+			controls := make(map[string]bool)
+			for _, control := range profile.Controls {
+				assert.False(t, controls[control.Name], "duplicate control %s", control.Name)
+				controls[control.Name] = true
+				assert.Contains(t, []MessageType{NoteMessage, ControlChangeMessage, PitchbendMessage}, control.Message, control.Name)
+				assert.Equal(t, control.Message == PitchbendMessage, control.MidiKey().IsPitchbend(), control.Name)
+			}
+		})
+	}
+}
+
+func TestGet_Alias(t *testing.T) {
+	profile, err := Get("X-Touch")
+	require.NoError(t, err)
+	assert.Equal(t, "mcu", profile.Name)
+
+	_, err = Get("unknown")
+	assert.Error(t, err)
+}
+
+func TestProfile_Resolve(t *testing.T) {
+	starlight, err := Get("starlight")
+	require.NoError(t, err)
+	mcu, err := Get("mcu")
+	require.NoError(t, err)
+
+	tt := []struct {
+		desc     string
+		profile  Profile
+		mapping  ctrl.Mapping
+		expected ctrl.Mapping
+		invalid  bool
+	}{
+		{
+			desc:     "button",
+			profile:  starlight,
+			mapping:  ctrl.Mapping{Type: ctrl.MOXMapping, Control: "deck_a.play"},
+			expected: ctrl.Mapping{Type: ctrl.MOXMapping, Control: "deck_a.play", Channel: 1, Key: 7},
+		},
+		{
+			desc:     "jog wheel",
+			profile:  starlight,
+			mapping:  ctrl.Mapping{Type: ctrl.VFOMapping, Control: "Deck_B.Jog", VFO: "VFOB"},
+			expected: ctrl.Mapping{Type: ctrl.VFOMapping, Control: "Deck_B.Jog", Channel: 2, Key: 10, VFO: "VFOB"},
+		},
+		{
+			desc:     "encoder for a value control",
+			profile:  mcu,
+			mapping:  ctrl.Mapping{Type: ctrl.VolumeMapping, Control: "strip_2.vpot"},
+			expected: ctrl.Mapping{Type: ctrl.VolumeMapping, Control: "strip_2.vpot", Channel: 0, Key: 17, Options: map[string]string{"control": "encoder"}},
+		},
+		{
+			desc:     "motor fader with touch key",
+			profile:  mcu,
+			mapping:  ctrl.Mapping{Type: ctrl.VolumeMapping, Control: "strip_1.fader", Options: map[string]string{"feedback_delay": "200"}},
+			expected: ctrl.Mapping{Type: ctrl.VolumeMapping, Control: "strip_1.fader", Channel: 0, Key: -1, Options: map[string]string{"feedback_delay": "200", "touch_key": "104"}},
+		},
+		{
+			desc:     "indicator on a button",
+			profile:  starlight,
+			mapping:  ctrl.Mapping{Type: ctrl.SMeterMapping, Control: "deck_a.pad_1"},
+			expected: ctrl.Mapping{Type: ctrl.SMeterMapping, Control: "deck_a.pad_1", Channel: 6, Key: 0},
+		},
+		{
+			desc:    "unknown control",
+			profile: starlight,
+			mapping: ctrl.Mapping{Type: ctrl.MOXMapping, Control: "deck_c.play"},
+			invalid: true,
+		},
+		{
+			desc:    "poti for a button",
+			profile: starlight,
+			mapping: ctrl.Mapping{Type: ctrl.MOXMapping, Control: "crossfader"},
+			invalid: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, err := tc.profile.Resolve(tc.mapping)
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
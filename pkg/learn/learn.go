@@ -0,0 +1,243 @@
+// Package learn detects the controls of a MIDI device from the messages they send, to build mappings
+// without knowing the channels and keys of the controls.
+package learn
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+type MessageType int
+
+const (
+	NoteMessage MessageType = iota
+	ControlChangeMessage
+	PitchbendMessage
+)
+
+// Event is a MIDI message that was received from the device. The value is the velocity of a note,
+// the value of a control change, or the 14-bit value of a pitch bend.
+type Event struct {
+	Type    MessageType
+	Channel byte
+	Key     int8
+	Value   int
+}
+
+// minControlChanges is the number of control change messages that are needed to tell potis and encoders apart.
+const minControlChanges = 3
+
+var (
+	ErrNoEvents      = errors.New("no MIDI messages received")
+	ErrTooFewEvents  = errors.New("too few MIDI messages, turn or move the control slowly in both directions")
+	ErrSwitchControl = errors.New("the control sends on/off control change messages, this kind of button is not supported")
+)
+
+// Control is a control of the MIDI device. The encoding is only set for encoders. If a motor fader
+// has a touch sensor, the key of the touch sensor is given in TouchKey.
+type Control struct {
+	Type     ctrl.ControlType
+	Channel  byte
+	Key      int8
+	Encoding ctrl.EncoderEncoding
+	TouchKey *int8
+}
+
+func (c Control) MidiKey() ctrl.MidiKey {
+	return ctrl.MidiKey{Channel: c.Channel, Key: c.Key}
+}
+
+func (c Control) String() string {
+	switch {
+	case c.MidiKey().IsPitchbend():
+		return fmt.Sprintf("pitch bend %s on channel %d", c.Type, c.Channel)
+	case c.Type == ctrl.EncoderControl:
+		return fmt.Sprintf("%s on channel %d, key %d (%s)", c.Type, c.Channel, c.Key, c.Encoding)
+	default:
+		return fmt.Sprintf("%s on channel %d, key %d", c.Type, c.Channel, c.Key)
+	}
+}
+
+// Mapping returns a mapping of the given type for this control.
+func (c Control) Mapping(mappingType ctrl.MappingType) (ctrl.Mapping, error) {
+	definition, ok := ctrl.Definitions[mappingType]
+	if !ok {
+		return ctrl.Mapping{}, fmt.Errorf("unknown mapping type %s", mappingType)
+	}
+	compatible := false
+	for _, controlType := range definition.Controls() {
+		compatible = compatible || controlType == c.Type
+	}
+	if !compatible {
+		return ctrl.Mapping{}, fmt.Errorf("%s cannot be used with a %s", mappingType, c.Type)
+	}
+
+	result := ctrl.Mapping{
+		Type:    mappingType,
+		Channel: c.Channel,
+		Key:     c.Key,
+		Options: make(map[string]string),
+	}
+	if definition.ValueControl && c.Type == ctrl.EncoderControl {
+		result.Options["control"] = "encoder"
+	}
+	if c.TouchKey != nil {
+		result.Options["touch_key"] = strconv.Itoa(int(*c.TouchKey))
+	}
+	if len(result.Options) == 0 {
+		result.Options = nil
+	}
+	return result, nil
+}
+
+// Capture waits until the first event is received and collects all events until no further event is received
+// for the given quiet time. Events that are already waiting in the channel are discarded.
+func Capture(events <-chan Event, quiet time.Duration, cancel <-chan struct{}) ([]Event, bool) {
+	drain(events)
+
+	var result []Event
+	select {
+	case event := <-events:
+		result = append(result, event)
+	case <-cancel:
+		return nil, false
+	}
+
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+	for {
+		select {
+		case event := <-events:
+			result = append(result, event)
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(quiet)
+		case <-timer.C:
+			return result, true
+		case <-cancel:
+			return nil, false
+		}
+	}
+}
+
+func drain(events <-chan Event) {
+	for {
+		select {
+		case <-events:
+		default:
+			return
+		}
+	}
+}
+
+// Detect finds out which kind of control sent the given events. If the events come from several keys, the key
+// with the most events is used, e.g. a motor fader that sends a touch note before it sends its values.
+func Detect(events []Event) (Control, error) {
+	if len(events) == 0 {
+		return Control{}, ErrNoEvents
+	}
+
+	type source struct {
+		messageType MessageType
+		key         ctrl.MidiKey
+	}
+	values := make(map[source][]int)
+	var sources []source
+	for _, event := range events {
+		s := source{messageType: event.Type, key: ctrl.MidiKey{Channel: event.Channel, Key: event.Key}}
+		if _, ok := values[s]; !ok {
+			sources = append(sources, s)
+		}
+		values[s] = append(values[s], event.Value)
+	}
+	control := sources[0]
+	for _, s := range sources[1:] {
+		if len(values[s]) > len(values[control]) {
+			control = s
+		}
+	}
+
+	result := Control{Channel: control.key.Channel, Key: control.key.Key}
+	switch control.messageType {
+	case NoteMessage:
+		result.Type = ctrl.ButtonControl
+		return result, nil
+	case PitchbendMessage:
+		result.Type = ctrl.PotiControl
+		result.Key = ctrl.PitchbendKey
+		for _, s := range sources {
+			if s.messageType == NoteMessage {
+				key := s.key.Key
+				result.TouchKey = &key
+				break
+			}
+		}
+		return result, nil
+	}
+
+	controlType, encoding, err := detectControlChange(values[control])
+	if err != nil {
+		return Control{}, err
+	}
+	result.Type = controlType
+	result.Encoding = encoding
+	return result, nil
+}
+
+// detectControlChange tells potis and encoders apart. A poti sends a new value only if the value changes, it never
+// jumps far. An encoder repeats the same value while it is turned slowly and jumps when the direction changes.
+func detectControlChange(values []int) (ctrl.ControlType, ctrl.EncoderEncoding, error) {
+	if len(values) < minControlChanges {
+		return ctrl.UnknownControl, "", ErrTooFewEvents
+	}
+
+	switchLike := true
+	repeats := 0
+	maxJump := 0
+	for i, value := range values {
+		switchLike = switchLike && (value == 0 || value == 0x7f)
+		if i == 0 {
+			continue
+		}
+		jump := value - values[i-1]
+		if jump < 0 {
+			jump = -jump
+		}
+		if jump == 0 {
+			repeats++
+		}
+		if jump > maxJump {
+			maxJump = jump
+		}
+	}
+	if switchLike {
+		return ctrl.UnknownControl, "", ErrSwitchControl
+	}
+	if repeats*3 < len(values)-1 && maxJump < 0x30 {
+		return ctrl.PotiControl, "", nil
+	}
+
+	return ctrl.EncoderControl, detectEncoding(values), nil
+}
+
+func detectEncoding(values []int) ctrl.EncoderEncoding {
+	offset := true
+	signMagnitude := false
+	for _, value := range values {
+		offset = offset && value >= 0x30 && value <= 0x50
+		signMagnitude = signMagnitude || (value > 0x40 && value < 0x60)
+	}
+	switch {
+	case offset:
+		return ctrl.OffsetEncoding
+	case signMagnitude:
+		return ctrl.SignMagnitudeEncoding
+	default:
+		return ctrl.TwosComplementEncoding
+	}
+}
@@ -0,0 +1,148 @@
+package learn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func ccEvents(channel byte, key int8, values ...int) []Event {
+	result := make([]Event, len(values))
+	for i, value := range values {
+		result[i] = Event{Type: ControlChangeMessage, Channel: channel, Key: key, Value: value}
+	}
+	return result
+}
+
+func TestDetect(t *testing.T) {
+	touchKey := int8(104)
+	tt := []struct {
+		desc     string
+		events   []Event
+		expected Control
+		err      error
+	}{
+		{
+			desc:   "no events",
+			events: nil,
+			err:    ErrNoEvents,
+		},
+		{
+			desc:     "button",
+			events:   []Event{{Type: NoteMessage, Channel: 1, Key: 12, Value: 127}},
+			expected: Control{Type: ctrl.ButtonControl, Channel: 1, Key: 12},
+		},
+		{
+			desc:     "poti",
+			events:   ccEvents(0, 7, 60, 61, 62, 63, 64, 63, 62, 61),
+			expected: Control{Type: ctrl.PotiControl, Channel: 0, Key: 7},
+		},
+		{
+			desc:     "offset encoder",
+			events:   ccEvents(2, 10, 0x41, 0x41, 0x41, 0x42, 0x3f, 0x3f, 0x3f),
+			expected: Control{Type: ctrl.EncoderControl, Channel: 2, Key: 10, Encoding: ctrl.OffsetEncoding},
+		},
+		{
+			desc:     "sign magnitude encoder",
+			events:   ccEvents(2, 10, 0x01, 0x01, 0x01, 0x02, 0x41, 0x41, 0x41),
+			expected: Control{Type: ctrl.EncoderControl, Channel: 2, Key: 10, Encoding: ctrl.SignMagnitudeEncoding},
+		},
+		{
+			desc:     "twos complement encoder",
+			events:   ccEvents(2, 10, 0x01, 0x01, 0x01, 0x02, 0x7f, 0x7f, 0x7f),
+			expected: Control{Type: ctrl.EncoderControl, Channel: 2, Key: 10, Encoding: ctrl.TwosComplementEncoding},
+		},
+		{
+			desc:   "too few control changes",
+			events: ccEvents(0, 7, 60, 61),
+			err:    ErrTooFewEvents,
+		},
+		{
+			desc:   "switch",
+			events: ccEvents(0, 7, 127, 0, 127, 0),
+			err:    ErrSwitchControl,
+		},
+		{
+			desc: "motor fader with touch sensor",
+			events: []Event{
+				{Type: NoteMessage, Channel: 0, Key: 104, Value: 127},
+				{Type: PitchbendMessage, Channel: 0, Key: ctrl.PitchbendKey, Value: 0x2000},
+				{Type: PitchbendMessage, Channel: 0, Key: ctrl.PitchbendKey, Value: 0x2100},
+				{Type: PitchbendMessage, Channel: 0, Key: ctrl.PitchbendKey, Value: 0x2200},
+			},
+			expected: Control{Type: ctrl.PotiControl, Channel: 0, Key: ctrl.PitchbendKey, TouchKey: &touchKey},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, err := Detect(tc.events)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestControl_Mapping(t *testing.T) {
+	touchKey := int8(104)
+	tt := []struct {
+		desc        string
+		control     Control
+		mappingType ctrl.MappingType
+		expected    ctrl.Mapping
+		invalid     bool
+	}{
+		{
+			desc:        "button",
+			control:     Control{Type: ctrl.ButtonControl, Channel: 1, Key: 12},
+			mappingType: ctrl.MOXMapping,
+			expected:    ctrl.Mapping{Type: ctrl.MOXMapping, Channel: 1, Key: 12},
+		},
+		{
+			desc:        "encoder",
+			control:     Control{Type: ctrl.EncoderControl, Channel: 1, Key: 10, Encoding: ctrl.OffsetEncoding},
+			mappingType: ctrl.VFOMapping,
+			expected:    ctrl.Mapping{Type: ctrl.VFOMapping, Channel: 1, Key: 10},
+		},
+		{
+			desc:        "encoder as value control",
+			control:     Control{Type: ctrl.EncoderControl, Channel: 1, Key: 10, Encoding: ctrl.OffsetEncoding},
+			mappingType: ctrl.VolumeMapping,
+			expected:    ctrl.Mapping{Type: ctrl.VolumeMapping, Channel: 1, Key: 10, Options: map[string]string{"control": "encoder"}},
+		},
+		{
+			desc:        "motor fader",
+			control:     Control{Type: ctrl.PotiControl, Channel: 0, Key: ctrl.PitchbendKey, TouchKey: &touchKey},
+			mappingType: ctrl.VolumeMapping,
+			expected:    ctrl.Mapping{Type: ctrl.VolumeMapping, Channel: 0, Key: ctrl.PitchbendKey, Options: map[string]string{"touch_key": "104"}},
+		},
+		{
+			desc:        "incompatible control",
+			control:     Control{Type: ctrl.ButtonControl, Channel: 1, Key: 12},
+			mappingType: ctrl.VolumeMapping,
+			invalid:     true,
+		},
+		{
+			desc:        "unknown mapping type",
+			control:     Control{Type: ctrl.ButtonControl, Channel: 1, Key: 12},
+			mappingType: "unknown",
+			invalid:     true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual, err := tc.control.Mapping(tc.mappingType)
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
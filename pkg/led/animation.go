@@ -0,0 +1,172 @@
+package led
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gitlab.com/gomidi/midi/writer"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+const (
+	// frameInterval is the time between two frames of the animations.
+	frameInterval = 25 * time.Millisecond
+	// pulseSteps is the number of brightness steps of a pulsing LED.
+	pulseSteps = 8
+
+	defaultBlinkPeriod    = 500 * time.Millisecond
+	defaultMaxMessageRate = 200
+)
+
+// frame is the state of a single LED in one frame of an animation. The level is the brightness
+// in pulseSteps steps, it is only used if the LED can show colors.
+type frame struct {
+	on    bool
+	level uint8
+}
+
+type runningAnimation struct {
+	animation ctrl.Animation
+	start     time.Time
+}
+
+// keys returns the keys of the LEDs that are used by the animation.
+func (a runningAnimation) keys(key ctrl.MidiKey) []ctrl.MidiKey {
+	if a.animation.Pattern != ctrl.ChaseAnimation {
+		return []ctrl.MidiKey{key}
+	}
+	return append([]ctrl.MidiKey{key}, a.animation.Keys...)
+}
+
+// animationFrames returns the frames of the given number of LEDs at the given time since the start of the animation.
+func animationFrames(animation ctrl.Animation, ledCount int, elapsed time.Duration) []frame {
+	result := make([]frame, ledCount)
+	period := animation.Period
+	if period <= 0 {
+		period = defaultBlinkPeriod
+	}
+	phase := float64(elapsed%period) / float64(period)
+
+	switch animation.Pattern {
+	case ctrl.BlinkAnimation:
+		if phase < 0.5 {
+			for i := range result {
+				result[i] = frame{on: true, level: pulseSteps}
+			}
+		}
+	case ctrl.PulseAnimation:
+		// LEDs without colors show a heartbeat, colored LEDs fade in and out
+		on := phase < 0.1 || (phase >= 0.2 && phase < 0.3)
+		level := uint8(math.Round(pulseSteps * (0.5 - 0.5*math.Cos(2*math.Pi*phase))))
+		for i := range result {
+			result[i] = frame{on: on, level: level}
+		}
+	case ctrl.ChaseAnimation:
+		if ledCount > 0 {
+			result[int(phase*float64(ledCount))%ledCount] = frame{on: true, level: pulseSteps}
+		}
+	}
+	return result
+}
+
+// SetAnimation shows the given animation on the LED of the given key. An inactive animation stops the
+// current animation, the LED keeps its last state until it is set to a new state.
+func (c *Controller) SetAnimation(key ctrl.MidiKey, animation ctrl.Animation) {
+	c.commands <- func(w writer.ChannelWriter) {
+		c.stopAnimation(w, key)
+		if !animation.Active() {
+			return
+		}
+		c.animations[key] = &runningAnimation{
+			animation: animation,
+			start:     time.Now(),
+		}
+	}
+}
+
+// stopAnimation stops the animation of the given key and switches off the additional LEDs of the animation.
+// It must only be called from the controller's goroutine.
+func (c *Controller) stopAnimation(w writer.ChannelWriter, key ctrl.MidiKey) {
+	running, ok := c.animations[key]
+	if !ok {
+		return
+	}
+	delete(c.animations, key)
+	for i, animatedKey := range running.keys(key) {
+		delete(c.frames, animatedKey)
+		if i == 0 {
+			continue
+		}
+		channel, outputKey := c.address(animatedKey)
+		c.write(w, channel, outputKey, ledState{kind: outputState, output: c.output(animatedKey, false, false)})
+	}
+}
+
+// animate writes the next frame of all running animations. Only LEDs that change their state are written,
+// the number of messages per frame is limited. LEDs that are skipped are written with one of the next frames.
+// It must only be called from the controller's goroutine.
+func (c *Controller) animate(w writer.ChannelWriter, now time.Time) {
+	type update struct {
+		key       ctrl.MidiKey
+		frame     frame
+		animation ctrl.Animation
+	}
+	updates := make([]update, 0, len(c.animations))
+	for key, running := range c.animations {
+		keys := running.keys(key)
+		frames := animationFrames(running.animation, len(keys), now.Sub(running.start))
+		for i, animatedKey := range keys {
+			f := frames[i]
+			if c.colored(animatedKey, running.animation) {
+				f.on = f.level > 0
+			} else {
+				f.level = 0
+			}
+			lastFrame, written := c.frames[animatedKey]
+			if written && lastFrame == f {
+				continue
+			}
+			updates = append(updates, update{key: animatedKey, frame: f, animation: running.animation})
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		if updates[i].key.Channel != updates[j].key.Channel {
+			return updates[i].key.Channel < updates[j].key.Channel
+		}
+		return updates[i].key.Key < updates[j].key.Key
+	})
+
+	if len(updates) > c.maxMessagesPerFrame {
+		updates = updates[:c.maxMessagesPerFrame]
+	}
+	for _, u := range updates {
+		c.writeFrame(w, u.key, u.frame, u.animation)
+		c.frames[u.key] = u.frame
+	}
+}
+
+// colored indicates if the LED of the given key shows the animation in color.
+func (c *Controller) colored(key ctrl.MidiKey, animation ctrl.Animation) bool {
+	return animation.Color != nil && c.colorOutput(key) != nil
+}
+
+// writeFrame writes the given frame of an animation. If the LED shows the animation in color,
+// the color is dimmed to the frame's brightness.
+func (c *Controller) writeFrame(w writer.ChannelWriter, key ctrl.MidiKey, f frame, animation ctrl.Animation) {
+	channel, outputKey := c.address(key)
+	if !c.colored(key, animation) {
+		c.write(w, channel, outputKey, ledState{kind: outputState, output: c.output(key, f.on, false)})
+		return
+	}
+	c.write(w, channel, outputKey, ledState{kind: colorState, colorOutput: *c.colorOutput(key), color: dimmed(*animation.Color, f.level)})
+}
+
+// dimmed returns the given color with the brightness of the given level.
+func dimmed(color ctrl.Color, level uint8) ctrl.Color {
+	scale := func(v uint8) uint8 {
+		return uint8(uint(v) * uint(level) / pulseSteps)
+	}
+	return ctrl.Color{R: scale(color.R), G: scale(color.G), B: scale(color.B)}
+}
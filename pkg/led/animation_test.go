@@ -0,0 +1,83 @@
+package led
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func TestAnimationFrames(t *testing.T) {
+	on := frame{on: true, level: pulseSteps}
+	tt := []struct {
+		desc      string
+		animation ctrl.Animation
+		ledCount  int
+		elapsed   time.Duration
+		expected  []frame
+	}{
+		{
+			desc:      "blink on",
+			animation: ctrl.Animation{Pattern: ctrl.BlinkAnimation, Period: 500 * time.Millisecond},
+			ledCount:  1,
+			elapsed:   1100 * time.Millisecond,
+			expected:  []frame{on},
+		},
+		{
+			desc:      "blink off",
+			animation: ctrl.Animation{Pattern: ctrl.BlinkAnimation, Period: 500 * time.Millisecond},
+			ledCount:  1,
+			elapsed:   1300 * time.Millisecond,
+			expected:  []frame{{}},
+		},
+		{
+			desc:      "blink with default period",
+			animation: ctrl.Animation{Pattern: ctrl.BlinkAnimation},
+			ledCount:  1,
+			elapsed:   300 * time.Millisecond,
+			expected:  []frame{{}},
+		},
+		{
+			desc:      "pulse heartbeat",
+			animation: ctrl.Animation{Pattern: ctrl.PulseAnimation, Period: 1000 * time.Millisecond},
+			ledCount:  1,
+			elapsed:   250 * time.Millisecond,
+			expected:  []frame{{on: true, level: 4}},
+		},
+		{
+			desc:      "pulse at full brightness",
+			animation: ctrl.Animation{Pattern: ctrl.PulseAnimation, Period: 1000 * time.Millisecond},
+			ledCount:  1,
+			elapsed:   500 * time.Millisecond,
+			expected:  []frame{{on: false, level: pulseSteps}},
+		},
+		{
+			desc:      "chase",
+			animation: ctrl.Animation{Pattern: ctrl.ChaseAnimation, Period: 800 * time.Millisecond},
+			ledCount:  4,
+			elapsed:   1000 * time.Millisecond,
+			expected:  []frame{{}, on, {}, {}},
+		},
+		{
+			desc:      "no animation",
+			animation: ctrl.Animation{},
+			ledCount:  2,
+			elapsed:   100 * time.Millisecond,
+			expected:  []frame{{}, {}},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual := animationFrames(tc.animation, tc.ledCount, tc.elapsed)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestDimmed(t *testing.T) {
+	assert.Equal(t, ctrl.Color{R: 0x7f, G: 0x40, B: 0x00}, dimmed(ctrl.Color{R: 0xff, G: 0x80, B: 0x00}, pulseSteps/2))
+	assert.Equal(t, ctrl.Black, dimmed(ctrl.White, 0))
+	assert.Equal(t, ctrl.White, dimmed(ctrl.White, pulseSteps))
+}
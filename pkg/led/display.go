@@ -0,0 +1,89 @@
+package led
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+
+	"gitlab.com/gomidi/midi/writer"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+// TextDisplay encodes the text that belongs to a key as raw MIDI messages for a named display of the device.
+type TextDisplay interface {
+	EncodeText(key ctrl.MidiKey, text string) (name string, messages [][]byte, ok bool)
+}
+
+// SetTextDisplay sets the display that shows the texts of the keys. It must be called before any text is set.
+func (c *Controller) SetTextDisplay(display TextDisplay) {
+	c.textDisplay = display
+}
+
+// SetText shows the text that belongs to the given key, if the device has a display for it.
+func (c *Controller) SetText(key ctrl.MidiKey, text string) {
+	if c.textDisplay == nil {
+		return
+	}
+	name, messages, ok := c.textDisplay.EncodeText(key, text)
+	if !ok {
+		return
+	}
+	c.SetDisplay(name, messages)
+}
+
+// SetDisplay writes the given raw MIDI messages to the named display of the device. The messages are only
+// written if the display does not already show them.
+func (c *Controller) SetDisplay(name string, messages [][]byte) {
+	c.commands <- func(w writer.ChannelWriter) {
+		if last, ok := c.displays[name]; ok && equalMessages(last, messages) {
+			return
+		}
+		c.displays[name] = messages
+		writeRaw(w, messages)
+	}
+}
+
+// resyncDisplays writes the content of all displays again. It must only be called from the controller's goroutine.
+func (c *Controller) resyncDisplays(w writer.ChannelWriter) {
+	names := make([]string, 0, len(c.displays))
+	for name := range c.displays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeRaw(w, c.displays[name])
+	}
+}
+
+func equalMessages(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeRaw(w writer.ChannelWriter, messages [][]byte) {
+	for _, message := range messages {
+		err := w.Write(rawMessage(message))
+		if err != nil {
+			log.Printf("Cannot write display content: %v", err)
+		}
+	}
+}
+
+type rawMessage []byte
+
+func (m rawMessage) String() string {
+	return fmt.Sprintf("raw MIDI message: % 2X", []byte(m))
+}
+
+func (m rawMessage) Raw() []byte {
+	return []byte(m)
+}
@@ -0,0 +1,269 @@
+package led
+
+import (
+	"log"
+	"time"
+
+	"gitlab.com/gomidi/midi/writer"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+// NewController returns a new LED controller that writes to the given MIDI writer, according to the given profile.
+func NewController(w writer.ChannelWriter, profile Profile) *Controller {
+	result := &Controller{
+		w:        w,
+		profile:  profile,
+		keys:     make(map[ctrl.MidiKey]KeyProfile),
+		commands: make(chan func(writer.ChannelWriter)),
+		closed:   make(chan struct{}),
+
+		blinkPeriod:         defaultBlinkPeriod,
+		maxMessagesPerFrame: defaultMaxMessageRate * int(frameInterval) / int(time.Second),
+		animations:          make(map[ctrl.MidiKey]*runningAnimation),
+		frames:              make(map[ctrl.MidiKey]frame),
+		states:              make(map[ctrl.MidiKey]ledState),
+		displays:            make(map[string][][]byte),
+	}
+	for _, key := range profile.Keys {
+		result.keys[key.MidiKey()] = key
+	}
+	if profile.BlinkPeriod > 0 {
+		result.blinkPeriod = time.Duration(profile.BlinkPeriod) * time.Millisecond
+	}
+	if profile.MaxMessageRate > 0 {
+		result.maxMessagesPerFrame = profile.MaxMessageRate * int(frameInterval) / int(time.Second)
+	}
+	if result.maxMessagesPerFrame < 1 {
+		result.maxMessagesPerFrame = 1
+	}
+
+	go func() {
+		defer close(result.closed)
+		ticker := time.NewTicker(frameInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case command, ok := <-result.commands:
+				if !ok {
+					return
+				}
+				command(result.w)
+			case now := <-ticker.C:
+				result.animate(result.w, now)
+			}
+		}
+	}()
+
+	return result
+}
+
+type Controller struct {
+	w        writer.ChannelWriter
+	profile  Profile
+	keys     map[ctrl.MidiKey]KeyProfile
+	commands chan func(writer.ChannelWriter)
+	closed   chan struct{}
+
+	blinkPeriod         time.Duration
+	maxMessagesPerFrame int
+	animations          map[ctrl.MidiKey]*runningAnimation
+	frames              map[ctrl.MidiKey]frame
+	states              map[ctrl.MidiKey]ledState
+	displays            map[string][][]byte
+	textDisplay         TextDisplay
+}
+
+func (c *Controller) Close() {
+	select {
+	case <-c.closed:
+		return
+	default:
+		close(c.commands)
+		<-c.closed
+	}
+}
+
+func (c *Controller) SetOn(key ctrl.MidiKey, on bool) {
+	output := c.output(key, on, false)
+	c.commands <- func(w writer.ChannelWriter) {
+		c.stopAnimation(w, key)
+		channel, outputKey := c.address(key)
+		c.write(w, channel, outputKey, ledState{kind: outputState, output: output})
+	}
+}
+
+// SetFlashing lets the LED flash. If the device cannot let this LED flash, the LED blinks with
+// the blink period of the profile.
+func (c *Controller) SetFlashing(key ctrl.MidiKey, on bool) {
+	if on && !c.canFlash(key) {
+		c.SetAnimation(key, ctrl.Animation{Pattern: ctrl.BlinkAnimation, Period: c.blinkPeriod})
+		return
+	}
+	output := c.output(key, on, true)
+	c.commands <- func(w writer.ChannelWriter) {
+		c.stopAnimation(w, key)
+		channel, outputKey := c.address(key)
+		c.write(w, channel, outputKey, ledState{kind: outputState, output: output})
+	}
+}
+
+func (c *Controller) SetValue(key ctrl.MidiKey, value uint8) {
+	output := c.profile.Value
+	if keyProfile, ok := c.keys[key]; ok && keyProfile.Value != nil {
+		output = *keyProfile.Value
+	}
+	c.commands <- func(w writer.ChannelWriter) {
+		c.stopAnimation(w, key)
+		channel, outputKey := c.address(key)
+		c.write(w, channel, outputKey, ledState{kind: valueState, valueOutput: output, value: value})
+	}
+}
+
+// SetFineValue sends a 14-bit value back to the device. Pitch bend keys get a pitch bend message if the
+// profile has motor faders, all other keys get the value with 7-bit resolution.
+func (c *Controller) SetFineValue(key ctrl.MidiKey, value uint16) {
+	if !key.IsPitchbend() {
+		c.SetValue(key, uint8(value>>7))
+		return
+	}
+	if !c.profile.MotorFaders {
+		return
+	}
+	c.commands <- func(w writer.ChannelWriter) {
+		c.write(w, key.Channel, key.Key, ledState{kind: pitchbendState, fineValue: value})
+	}
+}
+
+// SetColor sets the color of the LED. If the device cannot show colors on this LED, the LED is switched on
+// for any color but black.
+func (c *Controller) SetColor(key ctrl.MidiKey, color ctrl.Color) {
+	output := c.colorOutput(key)
+	if output == nil {
+		c.SetOn(key, !color.IsBlack())
+		return
+	}
+	c.commands <- func(w writer.ChannelWriter) {
+		c.stopAnimation(w, key)
+		channel, outputKey := c.address(key)
+		c.write(w, channel, outputKey, ledState{kind: colorState, colorOutput: *output, color: color})
+	}
+}
+
+// address returns the channel and key of the LED that belongs to the given input key.
+func (c *Controller) address(key ctrl.MidiKey) (byte, int8) {
+	keyProfile, ok := c.keys[key]
+	if !ok {
+		return key.Channel, key.Key
+	}
+	channel := key.Channel
+	if keyProfile.OutputChannel != nil {
+		channel = *keyProfile.OutputChannel
+	}
+	outputKey := key.Key
+	if keyProfile.OutputKey != nil {
+		outputKey = *keyProfile.OutputKey
+	}
+	return channel, outputKey
+}
+
+// canFlash indicates if the device can let the LED of the given key flash.
+func (c *Controller) canFlash(key ctrl.MidiKey) bool {
+	keyProfile, ok := c.keys[key]
+	return c.profile.Flashing != nil || (ok && keyProfile.Flashing != nil)
+}
+
+func (c *Controller) colorOutput(key ctrl.MidiKey) *ColorOutput {
+	if keyProfile, ok := c.keys[key]; ok && keyProfile.Color != nil {
+		return keyProfile.Color
+	}
+	return c.profile.Color
+}
+
+func (c *Controller) output(key ctrl.MidiKey, on bool, flashing bool) Output {
+	keyProfile, hasKeyProfile := c.keys[key]
+	switch {
+	case !on:
+		if hasKeyProfile && keyProfile.Off != nil {
+			return *keyProfile.Off
+		}
+		return c.profile.Off
+	case flashing && hasKeyProfile && keyProfile.Flashing != nil:
+		return *keyProfile.Flashing
+	case flashing && c.profile.Flashing != nil:
+		return *c.profile.Flashing
+	default:
+		if hasKeyProfile && keyProfile.On != nil {
+			return *keyProfile.On
+		}
+		return c.profile.On
+	}
+}
+
+func writeOutput(w writer.ChannelWriter, channel byte, key int8, output Output) {
+	var err error
+	w.SetChannel(channel)
+	switch output.Message {
+	case NoteOnMessage:
+		err = writer.NoteOn(w, uint8(key), output.Value)
+	case NoteOffMessage:
+		err = writer.NoteOff(w, uint8(key))
+	case CCMessage:
+		err = writer.ControlChange(w, uint8(key), output.Value)
+	case SysExMessage:
+		err = writer.SysEx(w, buildSysEx(output.SysEx, sysExParams{channel: channel, key: key, value: output.Value}))
+	}
+	if err != nil {
+		log.Printf("Cannot write LED state: %v", err)
+	}
+}
+
+func writeValue(w writer.ChannelWriter, channel byte, key int8, output ValueOutput, value uint8) {
+	value = output.scale(value)
+
+	var err error
+	w.SetChannel(channel)
+	switch output.Message {
+	case NoteOnMessage:
+		err = writer.NoteOn(w, uint8(key), value)
+	case CCMessage:
+		err = writer.ControlChange(w, uint8(key), value)
+	case SysExMessage:
+		err = writer.SysEx(w, buildSysEx(output.SysEx, sysExParams{channel: channel, key: key, value: value}))
+	}
+	if err != nil {
+		log.Printf("Cannot write LED value: %v", err)
+	}
+}
+
+func writePitchbend(w writer.ChannelWriter, channel byte, value uint16) {
+	w.SetChannel(channel)
+	err := writer.Pitchbend(w, int16(value)-0x2000)
+	if err != nil {
+		log.Printf("Cannot write fader value: %v", err)
+	}
+}
+
+func writeColor(w writer.ChannelWriter, channel byte, key int8, output ColorOutput, color ctrl.Color) {
+	var err error
+	w.SetChannel(channel)
+	if output.OffFirst {
+		err = writer.NoteOff(w, uint8(key))
+		if err != nil {
+			log.Printf("Cannot write LED color: %v", err)
+		}
+	}
+
+	value := output.encode(color)
+	switch output.Message {
+	case NoteOnMessage:
+		err = writer.NoteOn(w, uint8(key), value)
+	case CCMessage:
+		err = writer.ControlChange(w, uint8(key), value)
+	case SysExMessage:
+		err = writer.SysEx(w, buildSysEx(output.SysEx, sysExParams{channel: channel, key: key, value: value, color: color}))
+	}
+	if err != nil {
+		log.Printf("Cannot write LED color: %v", err)
+	}
+}
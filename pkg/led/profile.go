@@ -0,0 +1,383 @@
+package led
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+type MessageType string
+
+const (
+	NoMessage      MessageType = "none"
+	NoteOnMessage  MessageType = "note_on"
+	NoteOffMessage MessageType = "note_off"
+	CCMessage      MessageType = "cc"
+	SysExMessage   MessageType = "sysex"
+)
+
+// Output describes the MIDI message that is used to show a state of a LED.
+type Output struct {
+	Message MessageType `json:"message"`
+	Value   uint8       `json:"value,omitempty"`
+	SysEx   string      `json:"sysex,omitempty"`
+}
+
+// ValueOutput describes the MIDI message that is used to show a value on a LED ring.
+// The 7-bit value is shifted to the right by Shift bits, or divided into Steps steps, then Offset is added and
+// the result is clamped to the range Min to Max.
+type ValueOutput struct {
+	Message MessageType `json:"message"`
+	Shift   uint8       `json:"shift,omitempty"`
+	Steps   uint8       `json:"steps,omitempty"`
+	Offset  uint8       `json:"offset,omitempty"`
+	Min     uint8       `json:"min,omitempty"`
+	Max     uint8       `json:"max,omitempty"`
+	SysEx   string      `json:"sysex,omitempty"`
+}
+
+type ColorEncoding string
+
+const (
+	// RGB232Encoding encodes the color as 0rrgggbb in the velocity or value of the message.
+	RGB232Encoding ColorEncoding = "rgb232"
+)
+
+// ColorOutput describes the MIDI message that is used to set the color of a LED. SysEx messages
+// get the color components as 7-bit values through the placeholders {r}, {g} and {b}.
+// If OffFirst is set, the LED is switched off before the color is changed.
+type ColorOutput struct {
+	Message  MessageType   `json:"message"`
+	Encoding ColorEncoding `json:"encoding,omitempty"`
+	SysEx    string        `json:"sysex,omitempty"`
+	OffFirst bool          `json:"off_first,omitempty"`
+}
+
+// encode encodes the given color as 7-bit value.
+func (o ColorOutput) encode(color ctrl.Color) uint8 {
+	switch o.Encoding {
+	case RGB232Encoding:
+		return (color.R>>6)<<5 | (color.G>>5)<<2 | (color.B >> 6)
+	default:
+		if color.IsBlack() {
+			return 0x00
+		}
+		return 0x7f
+	}
+}
+
+// KeyProfile overrides the profile for a single input key. The LED of the key may be addressed with
+// a different channel and key than the input.
+type KeyProfile struct {
+	Channel       byte         `json:"channel"`
+	Key           int8         `json:"key"`
+	OutputChannel *byte        `json:"output_channel,omitempty"`
+	OutputKey     *int8        `json:"output_key,omitempty"`
+	On            *Output      `json:"on,omitempty"`
+	Off           *Output      `json:"off,omitempty"`
+	Flashing      *Output      `json:"flashing,omitempty"`
+	Value         *ValueOutput `json:"value,omitempty"`
+	Color         *ColorOutput `json:"color,omitempty"`
+}
+
+func (p KeyProfile) MidiKey() ctrl.MidiKey {
+	return ctrl.MidiKey{Channel: p.Channel, Key: p.Key}
+}
+
+// Profile describes how the LEDs of a MIDI device are controlled. If the device cannot let the LEDs flash,
+// they blink with the given period in milliseconds. The number of MIDI messages that are sent for
+// animations is limited to MaxMessageRate messages per second. If the device has motor faders, the values
+// of pitch bend controls are sent back as pitch bend messages. The encoder encoding defines how the encoders of
+// the device send their turns. Devices that speak a specific protocol, like "mcu", get additional support
+// for their displays.
+type Profile struct {
+	Name            string               `json:"name,omitempty"`
+	On              Output               `json:"on"`
+	Off             Output               `json:"off"`
+	Flashing        *Output              `json:"flashing,omitempty"`
+	Value           ValueOutput          `json:"value"`
+	Color           *ColorOutput         `json:"color,omitempty"`
+	Keys            []KeyProfile         `json:"keys,omitempty"`
+	BlinkPeriod     int                  `json:"blink_period,omitempty"`
+	MaxMessageRate  int                  `json:"max_message_rate,omitempty"`
+	MotorFaders     bool                 `json:"motor_faders,omitempty"`
+	EncoderEncoding ctrl.EncoderEncoding `json:"encoder_encoding,omitempty"`
+	Protocol        Protocol             `json:"protocol,omitempty"`
+}
+
+type Protocol string
+
+const (
+	NoProtocol  Protocol = ""
+	MCUProtocol Protocol = "mcu"
+)
+
+const DefaultProfile = "starlight"
+
+var builtinProfiles = map[string]Profile{
+	// the behavior of the Hercules DJControl Starlight, the color of the base LEDs is encoded in the velocity
+	DefaultProfile: {
+		Name:  DefaultProfile,
+		On:    Output{Message: NoteOnMessage, Value: 0x7f},
+		Off:   Output{Message: NoteOffMessage},
+		Value: ValueOutput{Message: NoMessage},
+		Keys: []KeyProfile{
+			{Channel: 1, Key: 0x23, Color: &starlightBaseColor},
+			{Channel: 2, Key: 0x23, Color: &starlightBaseColor},
+		},
+	},
+	// the Behringer CMD PL-1 uses velocity 1 for on and 2 for flashing, the LED rings of the
+	// rotary encoders have 15 LEDs
+	"pl-1": {
+		Name:     "pl-1",
+		On:       Output{Message: NoteOnMessage, Value: 0x01},
+		Off:      Output{Message: NoteOffMessage},
+		Flashing: &Output{Message: NoteOnMessage, Value: 0x02},
+		Value:    ValueOutput{Message: CCMessage, Shift: 3, Min: 0x01, Max: 0x0F},
+	},
+	// the Numark DJ2GO2 Touch switches the LEDs off with a note on message of velocity 0
+	"dj2go2": {
+		Name:  "dj2go2",
+		On:    Output{Message: NoteOnMessage, Value: 0x7f},
+		Off:   Output{Message: NoteOnMessage, Value: 0x00},
+		Value: ValueOutput{Message: NoMessage},
+	},
+	// Mackie Control Universal: the V-Pots send their turns in sign-magnitude encoding on CC 0x10 to 0x17,
+	// their LED rings are addressed through CC 0x30 to 0x37 and show the value as single dot in 11 steps
+	"mcu": {
+		Name:            "mcu",
+		On:              Output{Message: NoteOnMessage, Value: 0x7f},
+		Off:             Output{Message: NoteOnMessage, Value: 0x00},
+		Flashing:        &Output{Message: NoteOnMessage, Value: 0x01},
+		Value:           ValueOutput{Message: CCMessage, Steps: 11, Offset: 0x01},
+		Keys:            mcuVPotRings(),
+		MotorFaders:     true,
+		EncoderEncoding: ctrl.SignMagnitudeEncoding,
+		Protocol:        MCUProtocol,
+	},
+}
+
+func mcuVPotRings() []KeyProfile {
+	result := make([]KeyProfile, 8)
+	for i := range result {
+		outputKey := int8(0x30 + i)
+		result[i] = KeyProfile{Channel: 0, Key: int8(0x10 + i), OutputKey: &outputKey}
+	}
+	return result
+}
+
+var starlightBaseColor = ColorOutput{Message: NoteOnMessage, Encoding: RGB232Encoding, OffFirst: true}
+
+var profileAliases = map[string]string{
+	"":             DefaultProfile,
+	"default":      DefaultProfile,
+	"cmd-pl-1":     "pl-1",
+	"dj2go2-touch": "dj2go2",
+	"mackie":       "mcu",
+	"x-touch":      "mcu",
+}
+
+// BuiltinProfile returns the built-in profile with the given name.
+func BuiltinProfile(name string) (Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := profileAliases[name]; ok {
+		name = alias
+	}
+	profile, ok := builtinProfiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown LED profile %s", name)
+	}
+	return profile, nil
+}
+
+// BuiltinProfileNames returns the names of all built-in profiles.
+func BuiltinProfileNames() []string {
+	result := make([]string, 0, len(builtinProfiles))
+	for name := range builtinProfiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// scale projects the given 7-bit value onto the value range of the output.
+func (o ValueOutput) scale(value uint8) uint8 {
+	if o.Steps > 0 {
+		value = uint8(uint(value&0x7f) * uint(o.Steps) / 0x80)
+	} else {
+		value = value >> o.Shift
+	}
+	value += o.Offset
+	if value < o.Min {
+		value = o.Min
+	}
+	if o.Max > 0 && value > o.Max {
+		value = o.Max
+	}
+	return value
+}
+
+// Validate checks if all message types and SysEx templates of the profile are valid.
+func (p Profile) Validate() error {
+	if p.BlinkPeriod < 0 {
+		return fmt.Errorf("invalid blink period %d", p.BlinkPeriod)
+	}
+	if p.MaxMessageRate < 0 {
+		return fmt.Errorf("invalid max message rate %d", p.MaxMessageRate)
+	}
+	switch p.EncoderEncoding {
+	case "", ctrl.OffsetEncoding, ctrl.SignMagnitudeEncoding, ctrl.TwosComplementEncoding:
+	default:
+		return fmt.Errorf("unknown encoder encoding %s", p.EncoderEncoding)
+	}
+	switch p.Protocol {
+	case NoProtocol, MCUProtocol:
+	default:
+		return fmt.Errorf("unknown protocol %s", p.Protocol)
+	}
+
+	outputs := map[string]*Output{
+		"on":       &p.On,
+		"off":      &p.Off,
+		"flashing": p.Flashing,
+	}
+	values := map[string]*ValueOutput{
+		"value": &p.Value,
+	}
+	colors := map[string]*ColorOutput{
+		"color": p.Color,
+	}
+	for _, key := range p.Keys {
+		prefix := fmt.Sprintf("key %d/%d ", key.Channel, key.Key)
+		outputs[prefix+"on"] = key.On
+		outputs[prefix+"off"] = key.Off
+		outputs[prefix+"flashing"] = key.Flashing
+		values[prefix+"value"] = key.Value
+		colors[prefix+"color"] = key.Color
+	}
+
+	for name, output := range outputs {
+		if output == nil {
+			continue
+		}
+		err := validateMessage(output.Message, output.SysEx)
+		if err != nil {
+			return fmt.Errorf("invalid %s output: %w", name, err)
+		}
+	}
+	for name, output := range values {
+		if output == nil {
+			continue
+		}
+		err := validateMessage(output.Message, output.SysEx)
+		if err != nil {
+			return fmt.Errorf("invalid %s output: %w", name, err)
+		}
+	}
+	for name, output := range colors {
+		if output == nil {
+			continue
+		}
+		if output.Encoding != "" && output.Encoding != RGB232Encoding {
+			return fmt.Errorf("invalid %s output: unknown color encoding %s", name, output.Encoding)
+		}
+		err := validateMessage(output.Message, output.SysEx)
+		if err != nil {
+			return fmt.Errorf("invalid %s output: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateMessage(message MessageType, sysex string) error {
+	switch message {
+	case "", NoMessage, NoteOnMessage, NoteOffMessage, CCMessage:
+		return nil
+	case SysExMessage:
+		_, err := parseSysExTemplate(sysex)
+		return err
+	default:
+		return fmt.Errorf("unknown message type %s", message)
+	}
+}
+
+// parseSysExTemplate parses a SysEx template like "F0 00 20 {key} {value} F7". The bytes are given in hex,
+// the placeholders {channel}, {key}, {value}, {r}, {g} and {b} are replaced when the message is sent.
+// The leading F0 and the trailing F7 are optional.
+func parseSysExTemplate(template string) ([]string, error) {
+	fields := strings.Fields(template)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "F0") {
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && strings.EqualFold(fields[len(fields)-1], "F7") {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty SysEx template")
+	}
+
+	for _, field := range fields {
+		if strings.HasPrefix(field, "{") {
+			if !isSysExPlaceholder(field) {
+				return nil, fmt.Errorf("unknown placeholder %s in SysEx template", field)
+			}
+			continue
+		}
+		value, err := strconv.ParseUint(field, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte %s in SysEx template", field)
+		}
+		if value > 0x7f {
+			return nil, fmt.Errorf("invalid data byte %s in SysEx template", field)
+		}
+	}
+	return fields, nil
+}
+
+func isSysExPlaceholder(field string) bool {
+	switch strings.ToLower(field) {
+	case "{channel}", "{key}", "{value}", "{r}", "{g}", "{b}":
+		return true
+	default:
+		return false
+	}
+}
+
+type sysExParams struct {
+	channel byte
+	key     int8
+	value   uint8
+	color   ctrl.Color
+}
+
+// buildSysEx builds the data of a SysEx message without the leading F0 and the trailing F7.
+func buildSysEx(template string, params sysExParams) []byte {
+	fields, err := parseSysExTemplate(template)
+	if err != nil {
+		return nil
+	}
+	result := make([]byte, len(fields))
+	for i, field := range fields {
+		switch strings.ToLower(field) {
+		case "{channel}":
+			result[i] = params.channel & 0x0F
+		case "{key}":
+			result[i] = byte(params.key) & 0x7F
+		case "{value}":
+			result[i] = params.value & 0x7F
+		case "{r}":
+			result[i] = params.color.R >> 1
+		case "{g}":
+			result[i] = params.color.G >> 1
+		case "{b}":
+			result[i] = params.color.B >> 1
+		default:
+			b, _ := strconv.ParseUint(field, 16, 8)
+			result[i] = byte(b)
+		}
+	}
+	return result
+}
@@ -0,0 +1,176 @@
+package led
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func TestValueOutput_Scale(t *testing.T) {
+	pl1, err := BuiltinProfile("pl-1")
+	assert.NoError(t, err)
+	mcu, err := BuiltinProfile("mcu")
+	assert.NoError(t, err)
+
+	tt := []struct {
+		desc     string
+		output   ValueOutput
+		value    uint8
+		expected uint8
+	}{
+		{
+			desc:     "unscaled",
+			output:   ValueOutput{Message: CCMessage},
+			value:    0x42,
+			expected: 0x42,
+		},
+		{
+			desc:     "pl-1 min",
+			output:   pl1.Value,
+			value:    0,
+			expected: 0x01,
+		},
+		{
+			desc:     "pl-1 center",
+			output:   pl1.Value,
+			value:    0x40,
+			expected: 0x08,
+		},
+		{
+			desc:     "pl-1 max",
+			output:   pl1.Value,
+			value:    0x7f,
+			expected: 0x0F,
+		},
+		{
+			desc:     "mcu min",
+			output:   mcu.Value,
+			value:    0,
+			expected: 0x01,
+		},
+		{
+			desc:     "mcu center",
+			output:   mcu.Value,
+			value:    0x40,
+			expected: 0x06,
+		},
+		{
+			desc:     "mcu max",
+			output:   mcu.Value,
+			value:    0x7f,
+			expected: 0x0B,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual := tc.output.scale(tc.value)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestBuildSysEx(t *testing.T) {
+	tt := []struct {
+		desc     string
+		template string
+		valid    bool
+		expected []byte
+	}{
+		{
+			desc:     "with frame",
+			template: "F0 00 20 29 {channel} {key} {value} F7",
+			valid:    true,
+			expected: []byte{0x00, 0x20, 0x29, 0x02, 0x24, 0x7f},
+		},
+		{
+			desc:     "color",
+			template: "F0 00 {key} {r} {g} {b} F7",
+			valid:    true,
+			expected: []byte{0x00, 0x24, 0x00, 0x00, 0x00},
+		},
+		{
+			desc:     "without frame",
+			template: "00 {value}",
+			valid:    true,
+			expected: []byte{0x00, 0x7f},
+		},
+		{
+			desc:     "unknown placeholder",
+			template: "00 {color}",
+		},
+		{
+			desc:     "status byte",
+			template: "00 90 01",
+		},
+		{
+			desc:     "empty",
+			template: "F0 F7",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := parseSysExTemplate(tc.template)
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			actual := buildSysEx(tc.template, sysExParams{channel: 2, key: 0x24, value: 0x7f})
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestColorOutput_Encode(t *testing.T) {
+	tt := []struct {
+		desc     string
+		output   ColorOutput
+		color    ctrl.Color
+		expected uint8
+	}{
+		{
+			desc:     "rgb232 red",
+			output:   starlightBaseColor,
+			color:    ctrl.Red,
+			expected: 0x60,
+		},
+		{
+			desc:     "rgb232 green",
+			output:   starlightBaseColor,
+			color:    ctrl.Green,
+			expected: 0x1c,
+		},
+		{
+			desc:     "rgb232 blue",
+			output:   starlightBaseColor,
+			color:    ctrl.Blue,
+			expected: 0x03,
+		},
+		{
+			desc:     "rgb232 white",
+			output:   starlightBaseColor,
+			color:    ctrl.White,
+			expected: 0x7f,
+		},
+		{
+			desc:     "no encoding",
+			output:   ColorOutput{Message: NoteOnMessage},
+			color:    ctrl.Orange,
+			expected: 0x7f,
+		},
+		{
+			desc:     "no encoding black",
+			output:   ColorOutput{Message: NoteOnMessage},
+			color:    ctrl.Black,
+			expected: 0x00,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual := tc.output.encode(tc.color)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
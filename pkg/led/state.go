@@ -0,0 +1,77 @@
+package led
+
+import (
+	"sort"
+
+	"gitlab.com/gomidi/midi/writer"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+type stateKind int
+
+const (
+	outputState stateKind = iota
+	valueState
+	colorState
+	pitchbendState
+)
+
+// ledState is the last state that was written to a LED. It is used to skip redundant writes and
+// to repaint the LEDs.
+type ledState struct {
+	kind        stateKind
+	output      Output
+	valueOutput ValueOutput
+	value       uint8
+	fineValue   uint16
+	colorOutput ColorOutput
+	color       ctrl.Color
+}
+
+func (s ledState) write(w writer.ChannelWriter, channel byte, key int8) {
+	switch s.kind {
+	case outputState:
+		writeOutput(w, channel, key, s.output)
+	case valueState:
+		writeValue(w, channel, key, s.valueOutput, s.value)
+	case colorState:
+		writeColor(w, channel, key, s.colorOutput, s.color)
+	case pitchbendState:
+		writePitchbend(w, channel, s.fineValue)
+	}
+}
+
+// write writes the given state to the LED with the given output address, if the LED does not already show this state.
+// It must only be called from the controller's goroutine.
+func (c *Controller) write(w writer.ChannelWriter, channel byte, key int8, state ledState) {
+	address := ctrl.MidiKey{Channel: channel, Key: key}
+	if last, ok := c.states[address]; ok && last == state {
+		return
+	}
+	c.states[address] = state
+	state.write(w, channel, key)
+}
+
+// Resync writes the current state of all LEDs again, e.g. after the device was reset or reconnected.
+func (c *Controller) Resync() {
+	c.commands <- func(w writer.ChannelWriter) {
+		addresses := make([]ctrl.MidiKey, 0, len(c.states))
+		for address := range c.states {
+			addresses = append(addresses, address)
+		}
+		sort.Slice(addresses, func(i, j int) bool {
+			if addresses[i].Channel != addresses[j].Channel {
+				return addresses[i].Channel < addresses[j].Channel
+			}
+			return addresses[i].Key < addresses[j].Key
+		})
+		for _, address := range addresses {
+			c.states[address].write(w, address.Channel, address.Key)
+		}
+		c.resyncDisplays(w)
+
+		// the animations are repainted with the next frame
+		c.frames = make(map[ctrl.MidiKey]frame)
+	}
+}
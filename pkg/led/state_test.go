@@ -0,0 +1,43 @@
+package led
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/gomidi/midi"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+type recordingWriter struct {
+	channel  uint8
+	messages []string
+}
+
+func (w *recordingWriter) Channel() uint8      { return w.channel }
+func (w *recordingWriter) SetChannel(no uint8) { w.channel = no }
+func (w *recordingWriter) Write(msg midi.Message) error {
+	w.messages = append(w.messages, msg.String())
+	return nil
+}
+
+func TestController_SkipsRedundantWritesAndResyncs(t *testing.T) {
+	w := &recordingWriter{}
+	profile, _ := BuiltinProfile("dj2go2")
+	controller := NewController(w, profile)
+
+	key := ctrl.MidiKey{Channel: 1, Key: 5}
+	controller.SetOn(key, true)
+	controller.SetOn(key, true)
+	controller.SetOn(ctrl.MidiKey{Channel: 0, Key: 7}, false)
+	controller.SetOn(key, true)
+	controller.Resync()
+	controller.Close()
+
+	assert.Equal(t, []string{
+		"channel.NoteOn channel 1 key 5 velocity 127",
+		"channel.NoteOn channel 0 key 7 velocity 0",
+		"channel.NoteOn channel 0 key 7 velocity 0",
+		"channel.NoteOn channel 1 key 5 velocity 127",
+	}, w.messages)
+}
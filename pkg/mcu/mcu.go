@@ -0,0 +1,140 @@
+// Package mcu implements the parts of the Mackie Control Universal (MCU) protocol that are used to show
+// information on the displays of a MCU control surface.
+package mcu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+const (
+	// Strips is the number of channel strips of a MCU control surface.
+	Strips = 8
+	// StripWidth is the number of characters of a scribble strip row.
+	StripWidth = 7
+	// TimecodeDigits is the number of digits of the timecode display.
+	TimecodeDigits = 10
+
+	// the CC number of the rightmost timecode digit
+	timecodeCC = 0x40
+	// the CC number of the first V-Pot
+	vpotCC = 0x10
+)
+
+// sysExHeader is the header of all SysEx messages to a MCU device, without the leading F0.
+var sysExHeader = []byte{0x00, 0x00, 0x66, 0x14}
+
+// StripOf returns the channel strip of the given input key. Faders send pitch bend messages on the channel of
+// their strip, V-Pots send CC 0x10 to 0x17 on channel 0.
+func StripOf(key ctrl.MidiKey) (int, bool) {
+	if key.IsPitchbend() {
+		if key.Channel < Strips {
+			return int(key.Channel), true
+		}
+		return 0, false
+	}
+	if key.Channel == 0 && key.Key >= vpotCC && key.Key < vpotCC+Strips {
+		return int(key.Key - vpotCC), true
+	}
+	return 0, false
+}
+
+// ScribbleStrip returns the data of the SysEx message that shows the given text in the given row (0 or 1) of the
+// scribble strip of the given channel strip. The text is centered and cut to the width of the strip.
+func ScribbleStrip(strip int, row int, text string) []byte {
+	offset := byte(row*Strips*StripWidth + strip*StripWidth)
+	result := append([]byte{}, sysExHeader...)
+	result = append(result, 0x12, offset)
+	return append(result, stripText(text)...)
+}
+
+// LCD returns the data of the SysEx message that shows the given text left aligned in the given row (0 or 1)
+// of the whole LCD.
+func LCD(row int, text string) []byte {
+	offset := byte(row * Strips * StripWidth)
+	if len(text) > Strips*StripWidth {
+		text = text[:Strips*StripWidth]
+	}
+	text = fmt.Sprintf("%-*s", Strips*StripWidth, text)
+
+	result := append([]byte{}, sysExHeader...)
+	result = append(result, 0x12, offset)
+	for i := 0; i < len(text); i++ {
+		result = append(result, asciiByte(text[i]))
+	}
+	return result
+}
+
+func stripText(text string) []byte {
+	text = strings.TrimSpace(text)
+	if len(text) > StripWidth {
+		text = text[:StripWidth]
+	}
+	padding := StripWidth - len(text)
+	text = strings.Repeat(" ", padding/2) + text + strings.Repeat(" ", padding-padding/2)
+
+	result := make([]byte, StripWidth)
+	for i := range result {
+		result[i] = asciiByte(text[i])
+	}
+	return result
+}
+
+func asciiByte(c byte) byte {
+	if c < 0x20 || c > 0x7e {
+		return ' '
+	}
+	return c
+}
+
+// Timecode returns the raw CC messages that show the given text right aligned on the timecode display.
+// Dots are shown as decimal point of the preceding digit.
+func Timecode(text string) [][]byte {
+	digits := make([]byte, 0, TimecodeDigits)
+	for i := len(text) - 1; i >= 0 && len(digits) < TimecodeDigits; i-- {
+		dot := false
+		if text[i] == '.' && i > 0 {
+			dot = true
+			i--
+		}
+		digits = append(digits, segmentCode(text[i], dot))
+	}
+	for len(digits) < TimecodeDigits {
+		digits = append(digits, segmentCode(' ', false))
+	}
+
+	result := make([][]byte, TimecodeDigits)
+	for i, digit := range digits {
+		result[i] = []byte{0xB0, byte(timecodeCC + i), digit}
+	}
+	return result
+}
+
+// segmentCode returns the 7-segment character code of the given character.
+func segmentCode(c byte, dot bool) byte {
+	var result byte
+	switch {
+	case c >= '0' && c <= '9', c == ' ', c == '-':
+		result = c
+	case c >= 'a' && c <= 'z':
+		result = c - 'a' + 0x01
+	case c >= 'A' && c <= 'Z':
+		result = c - 'A' + 0x01
+	default:
+		result = ' '
+	}
+	if dot {
+		result |= 0x40
+	}
+	return result
+}
+
+// SysEx returns the complete SysEx message with the given data.
+func SysEx(data []byte) []byte {
+	result := make([]byte, 0, len(data)+2)
+	result = append(result, 0xF0)
+	result = append(result, data...)
+	return append(result, 0xF7)
+}
@@ -0,0 +1,77 @@
+package mcu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+func TestStripOf(t *testing.T) {
+	tt := []struct {
+		desc          string
+		key           ctrl.MidiKey
+		expectedStrip int
+		expectedOK    bool
+	}{
+		{
+			desc:          "fader",
+			key:           ctrl.MidiKey{Channel: 3, Key: ctrl.PitchbendKey},
+			expectedStrip: 3,
+			expectedOK:    true,
+		},
+		{
+			desc:       "master fader",
+			key:        ctrl.MidiKey{Channel: 8, Key: ctrl.PitchbendKey},
+			expectedOK: false,
+		},
+		{
+			desc:          "v-pot",
+			key:           ctrl.MidiKey{Channel: 0, Key: 0x15},
+			expectedStrip: 5,
+			expectedOK:    true,
+		},
+		{
+			desc:       "other control",
+			key:        ctrl.MidiKey{Channel: 0, Key: 0x3c},
+			expectedOK: false,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			strip, ok := StripOf(tc.key)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedStrip, strip)
+		})
+	}
+}
+
+func TestScribbleStrip(t *testing.T) {
+	assert.Equal(t, []byte{0x00, 0x00, 0x66, 0x14, 0x12, 0x0E, ' ', ' ', 'r', 'i', 't', ' ', ' '}, ScribbleStrip(2, 0, "rit"))
+	assert.Equal(t, []byte{0x00, 0x00, 0x66, 0x14, 0x12, 0x38 + 0x07, 'r', 'x', '_', 'v', 'o', 'l', 'u'}, ScribbleStrip(1, 1, "rx_volume"))
+}
+
+func TestTimecode(t *testing.T) {
+	actual := Timecode("14.074.500")
+	expected := [][]byte{
+		{0xB0, 0x40, '0'},
+		{0xB0, 0x41, '0'},
+		{0xB0, 0x42, '5'},
+		{0xB0, 0x43, '4' | 0x40},
+		{0xB0, 0x44, '7'},
+		{0xB0, 0x45, '0'},
+		{0xB0, 0x46, '4' | 0x40},
+		{0xB0, 0x47, '1'},
+		{0xB0, 0x48, ' '},
+		{0xB0, 0x49, ' '},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestLCD(t *testing.T) {
+	actual := LCD(1, "14.074.500 CW")
+	assert.Equal(t, []byte{0x00, 0x00, 0x66, 0x14, 0x12, 0x38, '1', '4', '.', '0'}, actual[:10])
+	assert.Equal(t, 6+Strips*StripWidth, len(actual))
+	assert.Equal(t, byte(' '), actual[len(actual)-1])
+}
@@ -0,0 +1,46 @@
+package mcu
+
+import (
+	"fmt"
+
+	"github.com/ftl/midi2tci/pkg/ctrl"
+)
+
+// Display shows raw MIDI messages on a named display of the device.
+type Display interface {
+	SetDisplay(name string, messages [][]byte)
+}
+
+// NewSurface returns a MCU surface that uses the given display.
+func NewSurface(display Display) *Surface {
+	return &Surface{
+		display: display,
+	}
+}
+
+// Surface shows the names and values of the mapped controls on the scribble strips.
+type Surface struct {
+	display Display
+}
+
+// Assign shows the given label in the upper row of the scribble strip that belongs to the given key.
+func (s *Surface) Assign(key ctrl.MidiKey, label string) {
+	strip, ok := StripOf(key)
+	if !ok {
+		return
+	}
+	s.display.SetDisplay(stripDisplayName(strip, 0), [][]byte{SysEx(ScribbleStrip(strip, 0, label))})
+}
+
+// EncodeText encodes the value text of the given key for the lower row of the scribble strip.
+func (s *Surface) EncodeText(key ctrl.MidiKey, text string) (string, [][]byte, bool) {
+	strip, ok := StripOf(key)
+	if !ok {
+		return "", nil, false
+	}
+	return stripDisplayName(strip, 1), [][]byte{SysEx(ScribbleStrip(strip, 1, text))}, true
+}
+
+func stripDisplayName(strip int, row int) string {
+	return fmt.Sprintf("strip %d/%d", strip, row)
+}
@@ -0,0 +1,342 @@
+// Package sequence contains the MIDI sequences that are sent to a device, e.g. when the device is opened or when
+// the connection to the TCI server changes. In the configuration, each step of a sequence is either an array of
+// raw bytes or a string in a readable notation:
+//
+//	note_on ch1 0x24 127
+//	note_off ch1 0x24
+//	cc ch0 3 64
+//	program ch0 5
+//	pitchbend ch0 8192
+//	sysex F0 00 20 32 F7
+//	raw B0 03 40
+//	delay 50ms
+//
+// Channels are given as ch0 to ch15, numbers are decimal or hexadecimal with the prefix 0x. The bytes of sysex
+// and raw are always hexadecimal.
+package sequence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sequence is a sequence of MIDI messages and delays.
+type Sequence []Step
+
+// Step is either a MIDI message or a delay. A step that cannot be parsed or that contains an invalid MIDI message
+// keeps the problem, it must not be sent, see Valid.
+type Step struct {
+	Message []byte
+	Delay   time.Duration
+
+	text    string
+	problem string
+}
+
+// Message returns a step that sends the given raw MIDI message.
+func Message(raw ...byte) Step {
+	result := Step{Message: raw}
+	if err := checkMessages(raw); err != nil {
+		result.problem = err.Error()
+	}
+	return result
+}
+
+// Delay returns a step that waits for the given duration.
+func Delay(d time.Duration) Step {
+	return Step{Delay: d}
+}
+
+// Valid indicates if the step can be sent.
+func (s Step) Valid() bool {
+	return s.problem == ""
+}
+
+// Problem returns the reason why the step is invalid, or nil if the step is valid.
+func (s Step) Problem() error {
+	if s.problem == "" {
+		return nil
+	}
+	return fmt.Errorf("%s", s.problem)
+}
+
+// Parse parses the readable notation of a step.
+func Parse(s string) (Step, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Step{}, fmt.Errorf("empty step")
+	}
+	command := strings.ToLower(fields[0])
+	args := fields[1:]
+
+	switch command {
+	case "delay":
+		if len(args) != 1 {
+			return Step{}, fmt.Errorf("delay needs a duration, e.g. delay 50ms")
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d < 0 {
+			return Step{}, fmt.Errorf("%s is not a valid duration, use e.g. 50ms", args[0])
+		}
+		return Delay(d), nil
+	case "sysex":
+		data, err := parseHex(args)
+		if err != nil {
+			return Step{}, err
+		}
+		if len(data) == 0 || data[0] != 0xF0 {
+			data = append([]byte{0xF0}, data...)
+		}
+		if data[len(data)-1] != 0xF7 {
+			data = append(data, 0xF7)
+		}
+		return checked(data)
+	case "raw":
+		data, err := parseHex(args)
+		if err != nil {
+			return Step{}, err
+		}
+		return checked(data)
+	}
+
+	command, ok := commandAliases[command]
+	if !ok {
+		return Step{}, fmt.Errorf("unknown command %s, use note_on, note_off, cc, program, pitchbend, sysex, raw or delay", fields[0])
+	}
+	spec := channelCommands[command]
+	if len(args) < spec.minArgs || len(args) > len(spec.args) {
+		return Step{}, fmt.Errorf("%s needs %s", command, strings.Join(spec.args, ", "))
+	}
+	channel, err := parseChannel(args[0])
+	if err != nil {
+		return Step{}, err
+	}
+	values := make([]int, len(spec.args)-1)
+	for i, arg := range args[1:] {
+		value, err := strconv.ParseInt(arg, 0, 32)
+		max := int64(0x7F)
+		if command == "pitchbend" {
+			max = 0x3FFF
+		}
+		if err != nil || value < 0 || value > max {
+			return Step{}, fmt.Errorf("invalid %s %s, use 0 to %d", spec.args[i+1], arg, max)
+		}
+		values[i] = int(value)
+	}
+
+	status := spec.status | channel
+	switch command {
+	case "program":
+		return checked([]byte{status, byte(values[0])})
+	case "pitchbend":
+		return checked([]byte{status, byte(values[0] & 0x7F), byte(values[0] >> 7)})
+	default:
+		return checked([]byte{status, byte(values[0]), byte(values[1])})
+	}
+}
+
+type channelCommand struct {
+	status  byte
+	args    []string
+	minArgs int
+}
+
+var channelCommands = map[string]channelCommand{
+	"note_on":   {status: 0x90, args: []string{"channel", "key", "velocity"}, minArgs: 3},
+	"note_off":  {status: 0x80, args: []string{"channel", "key", "velocity"}, minArgs: 2},
+	"cc":        {status: 0xB0, args: []string{"channel", "controller", "value"}, minArgs: 3},
+	"program":   {status: 0xC0, args: []string{"channel", "program"}, minArgs: 2},
+	"pitchbend": {status: 0xE0, args: []string{"channel", "value"}, minArgs: 2},
+}
+
+var commandAliases = map[string]string{
+	"note_on":        "note_on",
+	"noteon":         "note_on",
+	"note_off":       "note_off",
+	"noteoff":        "note_off",
+	"cc":             "cc",
+	"control_change": "cc",
+	"program":        "program",
+	"program_change": "program",
+	"pitchbend":      "pitchbend",
+	"pitch_bend":     "pitchbend",
+}
+
+func checked(data []byte) (Step, error) {
+	err := checkMessages(data)
+	if err != nil {
+		return Step{}, err
+	}
+	return Step{Message: data}, nil
+}
+
+func parseChannel(s string) (byte, error) {
+	value, err := strconv.ParseInt(strings.TrimPrefix(strings.ToLower(s), "ch"), 0, 8)
+	if err != nil || value < 0 || value > 15 {
+		return 0, fmt.Errorf("invalid channel %s, use ch0 to ch15", s)
+	}
+	return byte(value), nil
+}
+
+func parseHex(fields []string) ([]byte, error) {
+	result := make([]byte, 0, len(fields))
+	for _, field := range fields {
+		value, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(field), "0x"), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte %s, use hexadecimal bytes like F0", field)
+		}
+		result = append(result, byte(value))
+	}
+	return result, nil
+}
+
+// checkMessages checks that the given data consists of complete MIDI messages, each with a valid status byte.
+func checkMessages(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("empty MIDI message")
+	}
+	for i := 0; i < len(data); {
+		status := data[i]
+		if status < 0x80 {
+			return fmt.Errorf("byte %d: %02X is not a status byte", i, status)
+		}
+		if status == 0xF0 {
+			end := i + 1
+			for end < len(data) && data[end] < 0x80 {
+				end++
+			}
+			if end == len(data) || data[end] != 0xF7 {
+				return fmt.Errorf("byte %d: the SysEx message is not terminated with F7", i)
+			}
+			i = end + 1
+			continue
+		}
+		length := messageLength(status)
+		if length == 0 {
+			return fmt.Errorf("byte %d: %02X is not a valid status byte", i, status)
+		}
+		if i+length > len(data) {
+			return fmt.Errorf("byte %d: the message needs %d bytes", i, length)
+		}
+		for j := i + 1; j < i+length; j++ {
+			if data[j] > 0x7F {
+				return fmt.Errorf("byte %d: %02X is not a data byte", j, data[j])
+			}
+		}
+		i += length
+	}
+	return nil
+}
+
+// messageLength returns the length of a message with the given status byte, including the status byte.
+func messageLength(status byte) int {
+	switch {
+	case status >= 0x80 && status < 0xC0, status >= 0xE0 && status < 0xF0, status == 0xF2:
+		return 3
+	case status >= 0xC0 && status < 0xE0, status == 0xF1, status == 0xF3:
+		return 2
+	case status == 0xF6, status >= 0xF8 && status != 0xF9 && status != 0xFD:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// String returns the step in the readable notation.
+func (s Step) String() string {
+	if s.problem != "" && s.text != "" {
+		return s.text
+	}
+	if s.Message == nil {
+		return fmt.Sprintf("delay %s", s.Delay)
+	}
+	m := s.Message
+	if checkMessages(m) == nil {
+		channel := m[0] & 0x0F
+		switch {
+		case len(m) == 3 && m[0]&0xF0 == 0x90:
+			return fmt.Sprintf("note_on ch%d 0x%02X %d", channel, m[1], m[2])
+		case len(m) == 3 && m[0]&0xF0 == 0x80 && m[2] == 0:
+			return fmt.Sprintf("note_off ch%d 0x%02X", channel, m[1])
+		case len(m) == 3 && m[0]&0xF0 == 0x80:
+			return fmt.Sprintf("note_off ch%d 0x%02X %d", channel, m[1], m[2])
+		case len(m) == 3 && m[0]&0xF0 == 0xB0:
+			return fmt.Sprintf("cc ch%d %d %d", channel, m[1], m[2])
+		case len(m) == 2 && m[0]&0xF0 == 0xC0:
+			return fmt.Sprintf("program ch%d %d", channel, m[1])
+		case len(m) == 3 && m[0]&0xF0 == 0xE0:
+			return fmt.Sprintf("pitchbend ch%d %d", channel, int(m[1])|int(m[2])<<7)
+		case m[0] == 0xF0 && m[len(m)-1] == 0xF7 && !containsStatus(m[1:len(m)-1]):
+			return fmt.Sprintf("sysex % X", m)
+		}
+	}
+	return fmt.Sprintf("raw % X", m)
+}
+
+func containsStatus(data []byte) bool {
+	for _, b := range data {
+		if b > 0x7F {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks all steps of the sequence. It returns the index of the first invalid step and its problem.
+func (s Sequence) Validate() (int, error) {
+	for i, step := range s {
+		if err := step.Problem(); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// UnmarshalJSON reads the steps of the sequence. Each step is either an array of bytes, a string in the readable
+// notation, or a base64 encoded string of bytes. Steps that cannot be parsed are kept with their problem, so that
+// the problems can be reported by Validate with the index of the step.
+func (s *Sequence) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	err := json.Unmarshal(data, &items)
+	if err != nil {
+		return err
+	}
+	result := make(Sequence, 0, len(items))
+	for _, item := range items {
+		var text string
+		if err := json.Unmarshal(item, &text); err != nil {
+			var raw []byte
+			err = json.Unmarshal(item, &raw)
+			if err != nil {
+				return err
+			}
+			result = append(result, Message(raw...))
+			continue
+		}
+
+		step, err := Parse(text)
+		if err != nil {
+			if raw, decodeErr := base64.StdEncoding.DecodeString(text); decodeErr == nil && len(raw) > 0 && raw[0] > 0x7F {
+				step = Message(raw...)
+			} else {
+				step = Step{text: text, problem: err.Error()}
+			}
+		}
+		result = append(result, step)
+	}
+	*s = result
+	return nil
+}
+
+// MarshalJSON writes the steps of the sequence in the readable notation.
+func (s Sequence) MarshalJSON() ([]byte, error) {
+	steps := make([]string, len(s))
+	for i, step := range s {
+		steps[i] = step.String()
+	}
+	return json.Marshal(steps)
+}
@@ -0,0 +1,98 @@
+package sequence
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		value    string
+		expected Step
+		invalid  bool
+	}{
+		{value: "note_on ch1 0x24 127", expected: Message(0x91, 0x24, 0x7F)},
+		{value: "NOTE_OFF ch1 36", expected: Message(0x81, 0x24, 0x00)},
+		{value: "note_off ch1 36 64", expected: Message(0x81, 0x24, 0x40)},
+		{value: "cc ch0 3 64", expected: Message(0xB0, 0x03, 0x40)},
+		{value: "program ch15 5", expected: Message(0xCF, 0x05)},
+		{value: "pitchbend ch2 8192", expected: Message(0xE2, 0x00, 0x40)},
+		{value: "sysex F0 00 20 32 F7", expected: Message(0xF0, 0x00, 0x20, 0x32, 0xF7)},
+		{value: "sysex 00 20 32", expected: Message(0xF0, 0x00, 0x20, 0x32, 0xF7)},
+		{value: "raw B0 03 40 90 24 7F", expected: Message(0xB0, 0x03, 0x40, 0x90, 0x24, 0x7F)},
+		{value: "delay 50ms", expected: Delay(50 * time.Millisecond)},
+		{value: "", invalid: true},
+		{value: "note_on ch1 0x24", invalid: true},
+		{value: "note_on ch16 0x24 127", invalid: true},
+		{value: "cc ch0 3 128", invalid: true},
+		{value: "pitchbend ch0 16384", invalid: true},
+		{value: "sysex 00 80 F7", invalid: true},
+		{value: "raw 03 40", invalid: true},
+		{value: "raw B0 03", invalid: true},
+		{value: "delay soon", invalid: true},
+		{value: "aftertouch ch0 1", invalid: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := Parse(tc.value)
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestStep_String(t *testing.T) {
+	for _, value := range []string{
+		"note_on ch1 0x24 127",
+		"note_off ch1 0x24",
+		"cc ch0 3 64",
+		"program ch15 5",
+		"pitchbend ch2 8192",
+		"sysex F0 00 20 32 F7",
+		"raw B0 03 40 90 24 7F",
+		"delay 50ms",
+	} {
+		t.Run(value, func(t *testing.T) {
+			step, err := Parse(value)
+			require.NoError(t, err)
+			assert.Equal(t, value, step.String())
+		})
+	}
+}
+
+func TestSequence_JSON(t *testing.T) {
+	var sequence Sequence
+	err := json.Unmarshal([]byte(`[[176, 3, 64], "kAB/", "note_on ch0 0x24 127", "delay 1s", [3, 64], "unknown"]`), &sequence)
+	require.NoError(t, err)
+
+	require.Len(t, sequence, 6)
+	assert.Equal(t, []byte{0xB0, 0x03, 0x40}, sequence[0].Message)
+	assert.Equal(t, []byte{0x90, 0x00, 0x7F}, sequence[1].Message)
+	assert.Equal(t, []byte{0x90, 0x24, 0x7F}, sequence[2].Message)
+	assert.Equal(t, time.Second, sequence[3].Delay)
+
+	step, err := sequence.Validate()
+	assert.Equal(t, 4, step)
+	assert.EqualError(t, err, "byte 0: 03 is not a status byte")
+
+	for i, s := range sequence {
+		assert.Equal(t, i < 4, s.Valid(), "step %d", i)
+		assert.Equal(t, i < 4, s.Problem() == nil, "step %d", i)
+	}
+
+	step, err = sequence[5:].Validate()
+	assert.Equal(t, 0, step)
+	assert.Error(t, err)
+
+	data, err := json.Marshal(sequence)
+	require.NoError(t, err)
+	assert.Equal(t, `["cc ch0 3 64","note_on ch0 0x00 127","note_on ch0 0x24 127","delay 1s","raw 03 40","unknown"]`, string(data))
+}